@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/runningwild/jig/graph"
+)
+
+// conflicts lists the conflicts in the named files, or in every file in the current frontier if
+// there are none.  With --json it writes a list of graph.ConflictReports instead, for other programs
+// to read.  Line numbers are those of the files that resolve -w writes with -style git or diff3.
+func conflicts(r graph.Repo, v graph.View, args []string) error {
+	asJSON := len(args) > 0 && (args[0] == "--json" || args[0] == "-json")
+	if asJSON {
+		args = args[1:]
+	}
+	name, err := v.CurrentFrontier()
+	if err != nil {
+		return err
+	}
+	f, err := v.GetFrontier(name)
+	if err != nil {
+		return err
+	}
+	reports := []*graph.ConflictReport{}
+	if len(args) == 0 {
+		all, err := graph.ListConflicts(r, f, "", renderer.NeedsBase())
+		if err != nil {
+			return err
+		}
+		reports = append(reports, all...)
+	}
+	for _, path := range args {
+		report, err := graph.ReportConflicts(r, f, filepath.ToSlash(filepath.Clean(path)), renderer.NeedsBase())
+		if err != nil {
+			return err
+		}
+		reports = append(reports, report)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(reports)
+	}
+	for _, report := range reports {
+		if len(report.Conflicts) == 0 {
+			fmt.Printf("%s: no conflicts\n", report.Path)
+		}
+		for _, c := range report.Conflicts {
+			fmt.Printf("%s:%d-%d  %s conflict  %s\n", report.Path, c.StartLine, c.EndLine, c.Kind, c.ID)
+			for i, g := range c.Groups {
+				if g.Deleted {
+					fmt.Printf("  version %d deletes the file\n", i+1)
+				} else {
+					fmt.Printf("  version %d, lines %d-%d\n", i+1, g.StartLine, g.EndLine)
+				}
+				for _, commit := range g.Commits {
+					fmt.Printf("    %s %s %s\n", commit.Hash, commit.Author, strings.SplitN(commit.Message, "\n", 2)[0])
+				}
+			}
+		}
+	}
+	return nil
+}
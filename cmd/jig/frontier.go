@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/runningwild/jig/graph"
+)
+
+// frontier manages the frontiers in the view, much like branches in other version control systems.
+func frontier(r graph.Repo, v graph.View, args []string) error {
+	if len(args) == 0 {
+		return listFrontiers(v)
+	}
+	nargs := map[string]int{"list": 0, "create": 1, "delete": 1, "rename": 2, "switch": 1, "describe": 2}
+	want, ok := nargs[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+	if args[0] == "create" && len(args) > 2 {
+		// The rest of the arguments are the description.
+		want = len(args) - 1
+	}
+	if len(args)-1 != want {
+		return fmt.Errorf("%s takes %d arguments", args[0], want)
+	}
+	switch args[0] {
+	case "list":
+		return listFrontiers(v)
+	case "create":
+		if err := v.CreateFrontier(args[1]); err != nil {
+			return err
+		}
+		if len(args) > 2 {
+			return v.DescribeFrontier(args[1], strings.Join(args[2:], " "))
+		}
+		return nil
+	case "delete":
+		return v.DeleteFrontier(args[1])
+	case "rename":
+		return v.RenameFrontier(args[1], args[2])
+	case "switch":
+		return v.ChangeFrontiers(args[1])
+	case "describe":
+		return v.DescribeFrontier(args[1], args[2])
+	}
+	return nil
+}
+
+func listFrontiers(v graph.View) error {
+	current, err := v.CurrentFrontier()
+	if err != nil {
+		return err
+	}
+	return listFrontierInfos(v, func(info graph.FrontierInfo) {
+		marker := " "
+		if info.Name == current {
+			marker = "*"
+		}
+		created := "-"
+		if !info.Created.IsZero() {
+			created = info.Created.Local().Format(time.RFC3339)
+		}
+		kind := "frontier"
+		if info.Tag {
+			kind = "tag"
+		}
+		fmt.Printf("%s %s\t%s\t%s\t%d heads\t%s\n", marker, info.Name, kind, created, len(info.Heads), info.Description)
+	})
+}
+
+// listFrontierInfos calls fn with every frontier in v, in order of name.
+func listFrontierInfos(v graph.View, fn func(info graph.FrontierInfo)) error {
+	var start string
+	buf := make([]graph.FrontierInfo, 100)
+	for {
+		n, err := v.ListFrontiers(start, buf)
+		if err != nil {
+			return err
+		}
+		for _, info := range buf[0:n] {
+			fn(info)
+		}
+		if n < len(buf) {
+			return nil
+		}
+		start = buf[n-1].Name + "\x00"
+	}
+}
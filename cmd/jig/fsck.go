@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/runningwild/jig/graph"
+)
+
+func fsck(r graph.Repo, v graph.View, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("fsck takes no arguments")
+	}
+	errs := graph.Check(r)
+	for _, err := range errs {
+		fmt.Printf("%v\n", err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("found %d problems", len(errs))
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/runningwild/jig/graph"
+)
+
+func gc(r graph.Repo, v graph.View, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("gc takes no arguments")
+	}
+	stats, err := graph.GC(r)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Removed %d nodes, %d contents, %d refs, and %d reverse deps\n", stats.Nodes, stats.Contents, stats.Refs, stats.ReverseDeps)
+	return nil
+}
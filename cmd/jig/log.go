@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/runningwild/jig/graph"
+)
+
+// log prints commits, newest first.  With no arguments it prints every commit the current frontier
+// observes, with a frontier it prints every commit that frontier observes, and with a..b it prints
+// the commits that b observes and a doesn't.
+func log(r graph.Repo, v graph.View, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("log takes at most one frontier or range")
+	}
+	var from, to string
+	if len(args) == 1 {
+		to = args[0]
+		if i := strings.Index(to, ".."); i >= 0 {
+			from, to = to[0:i], to[i+2:]
+		}
+	}
+	if to == "" {
+		current, err := v.CurrentFrontier()
+		if err != nil {
+			return err
+		}
+		to = current
+	}
+	b, err := v.GetFrontier(to)
+	if err != nil {
+		return err
+	}
+	var a graph.Frontier = graph.CommitSetOf(r, nil)
+	if from != "" {
+		if a, err = v.GetFrontier(from); err != nil {
+			return err
+		}
+	}
+	commits, err := graph.FrontierDelta(r, b, a)
+	if err != nil {
+		return err
+	}
+	for i := len(commits) - 1; i >= 0; i-- {
+		fmt.Printf("commit %s\n", commits[i])
+		md := r.GetCommitMetadata(commits[i])
+		if md == nil {
+			fmt.Printf("\n")
+			continue
+		}
+		if md.Author != "" {
+			fmt.Printf("Author: %s\n", md.Author)
+		}
+		if md.Timestamp != 0 {
+			fmt.Printf("Date:   %s\n", time.Unix(md.Timestamp, 0).Format(time.RFC1123Z))
+		}
+		fmt.Printf("\n")
+		for _, line := range strings.Split(md.Message, "\n") {
+			fmt.Printf("    %s\n", line)
+		}
+		fmt.Printf("\n")
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/runningwild/jig/graph"
+)
+
+func ls(r graph.Repo, v graph.View, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("ls takes at most one directory")
+	}
+	var dir string
+	if len(args) == 1 {
+		dir = args[0]
+	}
+	name, err := v.CurrentFrontier()
+	if err != nil {
+		return err
+	}
+	f, err := v.GetFrontier(name)
+	if err != nil {
+		return err
+	}
+	entries, err := graph.ReadDir(r, f, dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Dir {
+			fmt.Printf("%s/\n", entry.Name)
+		} else {
+			fmt.Printf("%s\n", entry.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/runningwild/jig/filerepo"
+	"github.com/runningwild/jig/graph"
+)
+
+var (
+	dir   = flag.String("dir", ".jig", "directory containing the repo and view")
+	diff3 = flag.Bool("diff3", false, "show what conflicts looked like before the conflicting commits, same as -style diff3")
+	style = flag.String("style", "git", "how conflicts are written to files: git, diff3 or side-by-side")
+)
+
+// renderer writes conflicts the way -style and -diff3 ask.
+var renderer graph.ConflictRenderer
+
+type command struct {
+	usage string
+	run   func(r graph.Repo, v graph.View, args []string) error
+}
+
+var commands = map[string]command{
+	"conflicts":    {"conflicts [--json] [file...]", conflicts},
+	"frontier":     {"frontier [list|create <name> [description]|delete <name>|rename <old> <new>|switch <name>|describe <name> <description>]", frontier},
+	"fsck":         {"fsck", fsck},
+	"gc":           {"gc", gc},
+	"ls":           {"ls [dir]", ls},
+	"log":          {"log [frontier | a..b]", log},
+	"merge":        {"merge <frontier>", merge},
+	"migrate-hash": {"migrate-hash <algorithm>", migrateHash},
+	"pick":         {"pick [-y] <commit>", pick},
+	"reflog":       {"reflog [frontier] | reflog restore <frontier> <index>", reflog},
+	"resolve":      {"resolve [-w [-ours|-theirs <frontier>]] [-m message] <file>...", resolve},
+	"stash":        {"stash [[push] [-m message] [file...] | list | pop [index] | drop [index]]", stash},
+	"tag":          {"tag [<name> [frontier]]", tag},
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	switch {
+	case *diff3 || *style == "diff3":
+		renderer = graph.Diff3Renderer{}
+	case *style == "git":
+		renderer = graph.GitRenderer{}
+	case *style == "side-by-side":
+		renderer = graph.SideBySideRenderer{}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown conflict style %q\n", *style)
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+	r, err := filerepo.Make(*dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open repo: %v\n", err)
+		os.Exit(1)
+	}
+	v, err := filerepo.MakeView(*dir, r)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open view: %v\n", err)
+		os.Exit(1)
+	}
+	if err := cmd.run(r, v, flag.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "jig %s: %v\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: jig [flags] <command> [args]\n\nflags:\n")
+	flag.PrintDefaults()
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(os.Stderr, "\ncommands:\n")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  jig %s\n", commands[name].usage)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/runningwild/jig/graph"
+)
+
+// merge merges another frontier into the current one and reports on the files that changed.
+func merge(r graph.Repo, v graph.View, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("merge takes exactly one frontier")
+	}
+	report, err := v.MergeFrontier(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Merged %d commits from %s\n", len(report.Commits), args[0])
+	for _, path := range report.Clean {
+		fmt.Printf("  clean       %s\n", path)
+	}
+	for _, path := range report.Deleted {
+		fmt.Printf("  deleted     %s\n", path)
+	}
+	for _, path := range report.Conflicted {
+		fmt.Printf("  conflicted  %s\n", path)
+	}
+	return nil
+}
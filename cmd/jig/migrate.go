@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/runningwild/jig/filerepo"
+	"github.com/runningwild/jig/graph"
+)
+
+// migrateHash rewrites the repo to use a different hash algorithm.  The new repo is built alongside
+// the old one, which is kept as db.<algorithm> in case anything goes wrong.
+func migrateHash(r graph.Repo, v graph.View, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected exactly one algorithm, one of %v", graph.HashAlgorithms())
+	}
+	alg, err := graph.ParseHashAlgorithm(args[0])
+	if err != nil {
+		return err
+	}
+	if r.HashAlgorithm() == alg {
+		return fmt.Errorf("repo already uses %s", alg)
+	}
+
+	tmp := filepath.Join(*dir, "migrate")
+	if _, err := os.Stat(tmp); err == nil {
+		return fmt.Errorf("%s already exists, remove it if a previous migration failed", tmp)
+	}
+	to, err := filerepo.MakeWithHashAlgorithm(tmp, alg)
+	if err != nil {
+		return err
+	}
+	commits, err := graph.Migrate(r, to)
+	if closeErr := filerepo.Close(to); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	// Neither database can be moved while it is still open.
+	from := r.HashAlgorithm()
+	if err := filerepo.Close(r); err != nil {
+		return err
+	}
+	backup := filepath.Join(*dir, "db."+string(from))
+	if err := os.Rename(filepath.Join(*dir, "db"), backup); err != nil {
+		return err
+	}
+	if err := os.Rename(filepath.Join(tmp, "db"), filepath.Join(*dir, "db")); err != nil {
+		return err
+	}
+	if err := os.Remove(tmp); err != nil {
+		return err
+	}
+	if err := filerepo.RemapCommits(v, commits); err != nil {
+		return err
+	}
+	fmt.Printf("Migrated %d commits from %s to %s, the old repo is in %s\n", len(commits), from, alg, backup)
+	return nil
+}
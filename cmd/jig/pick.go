@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/runningwild/jig/graph"
+)
+
+// pick adds a single commit to the current frontier, along with whatever it depends on that the
+// frontier doesn't already observe.  Those commits and any conflicts they would cause are shown
+// before anything is changed.
+func pick(r graph.Repo, v graph.View, args []string) error {
+	yes := len(args) == 2 && args[0] == "-y"
+	if yes {
+		args = args[1:]
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("pick takes exactly one commit")
+	}
+	commit := args[0]
+	name, err := v.CurrentFrontier()
+	if err != nil {
+		return err
+	}
+	f, err := v.GetFrontier(name)
+	if err != nil {
+		return err
+	}
+	report, err := graph.PreviewPick(r, f, commit)
+	if err != nil {
+		return err
+	}
+	if len(report.Commits) == 0 {
+		fmt.Printf("%s already observes %s\n", name, commit)
+		return nil
+	}
+
+	fmt.Printf("Picking %s into %s adds %d commits:\n", commit, name, len(report.Commits))
+	for _, c := range report.Commits {
+		var message string
+		if md := r.GetCommitMetadata(c); md != nil {
+			message = strings.SplitN(md.Message, "\n", 2)[0]
+		}
+		fmt.Printf("  %s %s\n", c, message)
+	}
+	if len(report.Conflicted) > 0 {
+		fmt.Printf("Afterwards these files will have conflicts:\n")
+		for _, path := range report.Conflicted {
+			fmt.Printf("  %s\n", path)
+		}
+	}
+
+	if !yes {
+		fmt.Printf("Continue? [y/N] ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.TrimSpace(answer); a != "y" && a != "Y" {
+			return fmt.Errorf("aborted")
+		}
+	}
+	return v.AdvanceFrontier(commit)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/runningwild/jig/graph"
+)
+
+// reflog prints the log of changes made to a frontier, newest first, or restores a frontier to the
+// state recorded in one of its entries.
+func reflog(r graph.Repo, v graph.View, args []string) error {
+	if len(args) == 3 && args[0] == "restore" {
+		index, err := strconv.ParseUint(args[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("bad log index %q", args[2])
+		}
+		return v.RestoreFrontier(args[1], index)
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("reflog takes at most one frontier")
+	}
+	var name string
+	if len(args) == 1 {
+		name = args[0]
+	} else {
+		var err error
+		if name, err = v.CurrentFrontier(); err != nil {
+			return err
+		}
+	}
+	entries, err := v.FrontierLog(name)
+	if err != nil {
+		return err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		fmt.Printf("%s@{%d}\t%s\t%s", name, e.Index, e.Time.Local().Format(time.RFC3339), e.Op)
+		if e.Commit != "" {
+			fmt.Printf(" %s", e.Commit)
+		}
+		fmt.Printf("\theads: %v", e.Heads)
+		if len(e.Excludes) > 0 {
+			fmt.Printf(" excluding: %v", e.Excludes)
+		}
+		fmt.Printf("\n")
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/runningwild/jig/graph"
+)
+
+// resolve writes conflicted files to the working directory with conflict markers, or, once the
+// markers have been replaced, commits the working files and advances the current frontier with the
+// commit.  The commit depends on every commit in every conflict, so anything that observes it sees
+// the conflicts resolved.  A file that was deleted while it was being edited can also be resolved by
+// removing it from the working directory, which deletes it again.
+//
+// With -ours or -theirs, -w writes the files with each conflict already resolved in favor of one side
+// of a merge, where theirs is the named frontier that was merged in, see graph.WinnerRenderer.
+func resolve(r graph.Repo, v graph.View, args []string) error {
+	write := len(args) > 0 && args[0] == "-w"
+	if write {
+		args = args[1:]
+	}
+	cr := renderer
+	if len(args) >= 2 && (args[0] == "-ours" || args[0] == "-theirs") {
+		if !write {
+			return fmt.Errorf("%s only applies with -w", args[0])
+		}
+		theirs, err := v.GetFrontier(args[1])
+		if err != nil {
+			return err
+		}
+		cr = graph.WinnerRenderer{Theirs: theirs, TheirsWin: args[0] == "-theirs"}
+		args = args[2:]
+	}
+	var message string
+	if len(args) >= 2 && args[0] == "-m" {
+		message, args = args[1], args[2:]
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("resolve takes at least one file")
+	}
+	name, err := v.CurrentFrontier()
+	if err != nil {
+		return err
+	}
+	f, err := v.GetFrontier(name)
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	var changes []graph.FileChange
+	for _, path := range args {
+		path = filepath.ToSlash(filepath.Clean(path))
+		fv, err := graph.ReadFile(r, f, path, nil)
+		if err != nil {
+			return err
+		}
+		if fv.ConflictsErr != nil {
+			return fv.ConflictsErr
+		}
+		if fv.State != graph.FileConflicted {
+			return fmt.Errorf("%q has no conflicts", path)
+		}
+		paths = append(paths, path)
+		if write {
+			continue
+		}
+		lines, onDisk, err := readWorkFile(path)
+		if err != nil {
+			return err
+		}
+		if !onDisk {
+			if fv.Conflicts[0].Kind == graph.DeleteEditConflict {
+				changes = append(changes, graph.FileChange{Path: path, Delete: true})
+				continue
+			}
+			return fmt.Errorf("%q is not in the working directory, use resolve -w to write it out", path)
+		}
+		changes = append(changes, graph.FileChange{Path: path, Edit: true, Content: lines})
+	}
+	if write {
+		for _, path := range paths {
+			if err := writeWorkFile(r, f, path, cr); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	c, err := graph.ChangeFiles(r, f, nil, changes)
+	if err != nil {
+		return err
+	}
+	if message == "" {
+		message = "Resolve conflicts in " + strings.Join(paths, ", ")
+	}
+	c.Metadata = commitMetadata(message)
+	if err := graph.Apply(r, c); err != nil {
+		return err
+	}
+	commit := r.HashAlgorithm().HashCommit(c)
+	if err := v.AdvanceFrontier(commit); err != nil {
+		return err
+	}
+	fmt.Printf("Resolved %d files in %s with %s\n", len(paths), name, commit)
+	return nil
+}
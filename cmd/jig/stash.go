@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/runningwild/jig/graph"
+	jpb "github.com/runningwild/jig/proto"
+)
+
+// stash sets aside changes to working files as a commit that no frontier observes, and brings them
+// back later.  The working files are the ones in the directory that contains the repo, so with the
+// default -dir they are in the current directory.
+func stash(r graph.Repo, v graph.View, args []string) error {
+	if len(args) == 0 {
+		return pushStash(r, v, nil)
+	}
+	switch args[0] {
+	case "push":
+		return pushStash(r, v, args[1:])
+	case "list":
+		if len(args) != 1 {
+			return fmt.Errorf("list takes no arguments")
+		}
+		return listStashes(r, v)
+	case "pop", "drop":
+		if len(args) > 2 {
+			return fmt.Errorf("%s takes at most one stash index", args[0])
+		}
+		index, err := stashIndex(v, args[1:])
+		if err != nil {
+			return err
+		}
+		if args[0] == "drop" {
+			return v.DropStash(index)
+		}
+		return popStash(r, v, index)
+	}
+	return pushStash(r, v, args)
+}
+
+// pushStash makes a commit out of the working files named in args, or every file in the current
+// frontier if there are none, and adds it to the stash list.  Working files are then put back the way
+// the current frontier has them.  Files that are missing from the working directory are only deleted
+// if they are named explicitly.
+func pushStash(r graph.Repo, v graph.View, args []string) error {
+	var message string
+	if len(args) >= 2 && args[0] == "-m" {
+		message, args = args[1], args[2:]
+	}
+	name, err := v.CurrentFrontier()
+	if err != nil {
+		return err
+	}
+	f, err := v.GetFrontier(name)
+	if err != nil {
+		return err
+	}
+	paths := args
+	if len(paths) == 0 {
+		if paths, err = graph.ListFiles(r, f, ""); err != nil {
+			return err
+		}
+	}
+
+	var changes []graph.FileChange
+	for _, path := range paths {
+		path = filepath.ToSlash(filepath.Clean(path))
+		lines, onDisk, err := readWorkFile(path)
+		if err != nil {
+			return err
+		}
+		exists, err := graph.FileExists(r, f, path)
+		if err != nil {
+			return err
+		}
+		switch {
+		case onDisk && exists:
+			changes = append(changes, graph.FileChange{Path: path, Edit: true, Content: lines})
+		case onDisk:
+			changes = append(changes, graph.FileChange{Path: path, Content: lines})
+		case exists && len(args) > 0:
+			changes = append(changes, graph.FileChange{Path: path, Delete: true})
+		case !exists:
+			return fmt.Errorf("%q: %w", path, graph.ErrFileNotFound)
+		}
+	}
+	c, err := graph.ChangeFiles(r, f, nil, changes)
+	if err != nil {
+		return err
+	}
+	if len(c.EdgeRefs) == 0 {
+		return fmt.Errorf("no changes to stash")
+	}
+	if message == "" {
+		message = "stash on " + name
+	}
+	c.Metadata = commitMetadata(message)
+	if err := graph.Apply(r, c); err != nil {
+		return err
+	}
+	commit := r.HashAlgorithm().HashCommit(c)
+	if err := v.PushStash(commit); err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if err := writeWorkFile(r, f, change.Path, renderer); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Stashed %d files as %s\n", len(changes), commit)
+	return nil
+}
+
+// popStash advances the current frontier with a stashed commit and writes out the files it touched.
+// Conflicted files are written with conflict markers.
+func popStash(r graph.Repo, v graph.View, index uint64) error {
+	entries, err := v.ListStashes()
+	if err != nil {
+		return err
+	}
+	var commit string
+	for _, entry := range entries {
+		if entry.Index == index {
+			commit = entry.Commit
+		}
+	}
+	name, err := v.CurrentFrontier()
+	if err != nil {
+		return err
+	}
+	f, err := v.GetFrontier(name)
+	if err != nil {
+		return err
+	}
+
+	// Don't overwrite anything that has been changed since the frontier was written out.
+	if commit != "" {
+		paths, err := graph.TouchedFiles(r, f, []string{commit})
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			if clean, err := workFileMatches(r, f, path); err != nil {
+				return err
+			} else if !clean {
+				return fmt.Errorf("%q has changes that would be overwritten", path)
+			}
+		}
+	}
+
+	report, err := v.PopStash(index)
+	if err != nil {
+		return err
+	}
+	if f, err = v.GetFrontier(name); err != nil {
+		return err
+	}
+	for _, paths := range [][]string{report.Clean, report.Conflicted, report.Deleted} {
+		for _, path := range paths {
+			if err := writeWorkFile(r, f, path, renderer); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Printf("Popped stash@{%d} into %s\n", index, name)
+	for _, path := range report.Conflicted {
+		fmt.Printf("  conflicted  %s\n", path)
+	}
+	return nil
+}
+
+func listStashes(r graph.Repo, v graph.View) error {
+	entries, err := v.ListStashes()
+	if err != nil {
+		return err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		var message string
+		if md := r.GetCommitMetadata(e.Commit); md != nil {
+			message = strings.SplitN(md.Message, "\n", 2)[0]
+		}
+		fmt.Printf("stash@{%d}\t%s\t%s\t%s %s\n", e.Index, e.Time.Local().Format(time.RFC3339), e.Frontier, e.Commit, message)
+	}
+	return nil
+}
+
+// stashIndex parses the stash index in args, or returns the newest one if there isn't one.
+func stashIndex(v graph.View, args []string) (uint64, error) {
+	if len(args) == 1 {
+		s := strings.TrimSuffix(strings.TrimPrefix(args[0], "stash@{"), "}")
+		index, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("bad stash index %q", args[0])
+		}
+		return index, nil
+	}
+	entries, err := v.ListStashes()
+	if err != nil {
+		return 0, err
+	}
+	if len(entries) == 0 {
+		return 0, fmt.Errorf("the stash list is empty")
+	}
+	return entries[len(entries)-1].Index, nil
+}
+
+// commitMetadata returns the metadata for a commit made now by the current user.
+func commitMetadata(message string) *jpb.CommitMetadata {
+	return &jpb.CommitMetadata{
+		Author:    os.Getenv("USER"),
+		Timestamp: time.Now().Unix(),
+		Message:   message,
+	}
+}
+
+// workPath returns where the working file for path is.
+func workPath(path string) string {
+	return filepath.Join(filepath.Dir(filepath.Clean(*dir)), filepath.FromSlash(path))
+}
+
+// readWorkFile returns the lines in the working file for path, and whether it exists.
+func readWorkFile(path string) ([][]byte, bool, error) {
+	data, err := os.ReadFile(workPath(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(data) == 0 {
+		return nil, true, nil
+	}
+	return bytes.Split(data, []byte("\n")), true, nil
+}
+
+// workFileContent returns what the working file for path should contain as of f, with any conflicts
+// written by cr, and whether it should exist at all.
+func workFileContent(r graph.Repo, f graph.Frontier, path string, cr graph.ConflictRenderer) ([]byte, bool, error) {
+	fv, err := graph.ReadFile(r, f, path, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	switch fv.State {
+	case graph.FileAbsent, graph.FileDeleted:
+		return nil, false, nil
+	case graph.FileConflicted:
+		data, err := graph.Render(r, f, path, fv.Conflicts, cr, []byte("\n"))
+		if err != nil {
+			return nil, false, err
+		}
+		// Resolving a delete/edit conflict in favor of the deletion leaves nothing, which means the
+		// file should go.
+		_, picked := cr.(graph.WinnerRenderer)
+		if picked && len(data) == 0 && fv.Conflicts[0].Kind == graph.DeleteEditConflict {
+			return nil, false, nil
+		}
+		return data, true, nil
+	}
+	return bytes.Join(fv.Lines, []byte("\n")), true, nil
+}
+
+// workFileMatches returns true iff the working file for path is missing or is the way f has it.
+func workFileMatches(r graph.Repo, f graph.Frontier, path string) (bool, error) {
+	data, err := os.ReadFile(workPath(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	want, _, err := workFileContent(r, f, path, renderer)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(data, want), nil
+}
+
+// writeWorkFile makes the working file for path the way f has it, with any conflicts written by cr,
+// removing it if f doesn't have it.
+func writeWorkFile(r graph.Repo, f graph.Frontier, path string, cr graph.ConflictRenderer) error {
+	data, exists, err := workFileContent(r, f, path, cr)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := os.Remove(workPath(path)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(workPath(path)), 0777); err != nil {
+		return err
+	}
+	return os.WriteFile(workPath(path), data, 0666)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/runningwild/jig/graph"
+)
+
+// tag creates a tag from a frontier, the current one by default, or lists tags if no name is given.
+func tag(r graph.Repo, v graph.View, args []string) error {
+	switch len(args) {
+	case 0:
+		return listFrontierInfos(v, func(info graph.FrontierInfo) {
+			if info.Tag {
+				fmt.Printf("%s\t%s\n", info.Name, info.Description)
+			}
+		})
+	case 1:
+		current, err := v.CurrentFrontier()
+		if err != nil {
+			return err
+		}
+		return v.CreateTag(args[0], current)
+	case 2:
+		return v.CreateTag(args[0], args[1])
+	}
+	return fmt.Errorf("tag takes a name and optionally a frontier")
+}
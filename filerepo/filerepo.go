@@ -16,29 +16,120 @@ import (
 
 type fileRepo struct {
 	db *bolt.DB
+
+	// While a transaction is in progress tx is the bolt transaction that every read and write goes
+	// through, depth is how deeply nested the transaction is, and aborted indicates that some
+	// nested transaction was aborted and the whole thing will be rolled back.
+	tx      *bolt.Tx
+	depth   int
+	aborted bool
+
+	alg graph.HashAlgorithm
 }
 
+// Make opens the repo in dir, creating it with graph.DefaultHashAlgorithm if it doesn't exist.
 func Make(dir string) (graph.Repo, error) {
+	return MakeWithHashAlgorithm(dir, "")
+}
+
+// MakeWithHashAlgorithm is like Make, but a newly created repo will use alg.  If alg is not empty
+// and the repo already exists it must have been created with alg.
+func MakeWithHashAlgorithm(dir string, alg graph.HashAlgorithm) (graph.Repo, error) {
 	os.Mkdir(dir, 0777)
 	db, err := bolt.Open(filepath.Join(dir, "db"), 0600, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create database: %w", err)
 	}
 	if err := db.Update(func(tx *bolt.Tx) error {
-		for _, name := range []string{"ref", "node", "content", "commit", "rdep"} {
+		for _, name := range []string{"ref", "node", "content", "commit", "meta", "rdep", "config"} {
 			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
 				return fmt.Errorf("create bucket: %s", err)
 			}
 		}
+		config := tx.Bucket([]byte("config"))
+		recorded := graph.HashAlgorithm(config.Get([]byte("hash")))
+		if recorded == "" {
+			if k, _ := tx.Bucket([]byte("commit")).Cursor().First(); k != nil {
+				// Repos created before the algorithm was recorded all used the original one.
+				recorded = graph.Shake128_32
+			} else if alg != "" {
+				recorded = alg
+			} else {
+				recorded = graph.DefaultHashAlgorithm
+			}
+			if err := config.Put([]byte("hash"), []byte(recorded)); err != nil {
+				return err
+			}
+		}
+		if _, err := graph.ParseHashAlgorithm(string(recorded)); err != nil {
+			return err
+		}
+		if alg != "" && alg != recorded {
+			return fmt.Errorf("repo uses hash algorithm %q, not %q", recorded, alg)
+		}
+		alg = recorded
+		if config.Get([]byte("rdep")) == nil {
+			// Repos created before this was recorded kept reverse deps keyed by the new commit
+			// rather than the old one.
+			if err := rebuildReverseDeps(tx); err != nil {
+				return err
+			}
+			if err := config.Put([]byte("rdep"), []byte("dep")); err != nil {
+				return err
+			}
+		}
 		return nil
 	}); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
 	return &fileRepo{
-		db: db,
+		db:  db,
+		alg: alg,
 	}, nil
 }
 
+// rebuildReverseDeps replaces everything in the rdep bucket with the reverse deps of the commits in
+// the commit bucket.
+func rebuildReverseDeps(tx *bolt.Tx) error {
+	if err := tx.DeleteBucket([]byte("rdep")); err != nil {
+		return err
+	}
+	b, err := tx.CreateBucket([]byte("rdep"))
+	if err != nil {
+		return err
+	}
+	rdeps := make(map[string][][]byte)
+	if err := tx.Bucket([]byte("commit")).ForEach(func(k, v []byte) error {
+		commit := &jpb.Commit{}
+		if err := proto.Unmarshal(v, commit); err != nil {
+			return fmt.Errorf("failed to unmarshal commit %q: %w", k, err)
+		}
+		for _, dep := range commit.Deps {
+			rdeps[dep] = append(rdeps[dep], append([]byte(nil), k...))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for dep, commits := range rdeps {
+		if err := b.Put([]byte(dep), encodeSliceSliceBytes(commits)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes r, which must have been made by Make or MakeWithHashAlgorithm.  The database stays
+// locked until it is closed, so this must be done before moving it.  r can't be used afterwards.
+func Close(r graph.Repo) error {
+	fr, ok := r.(*fileRepo)
+	if !ok {
+		return fmt.Errorf("repo was not made by Make")
+	}
+	return fr.db.Close()
+}
+
 func (r *fileRepo) GetRef(ptr string) string {
 	data := r.getRawData("ref", ptr)
 	if data == nil {
@@ -77,6 +168,18 @@ func (r *fileRepo) GetCommit(commitHash string) *jpb.Commit {
 	}
 	return commit
 }
+func (r *fileRepo) GetCommitMetadata(commitHash string) *jpb.CommitMetadata {
+	data := r.getRawData("meta", commitHash)
+	if data == nil {
+		return nil
+	}
+	md := &jpb.CommitMetadata{}
+	if err := proto.Unmarshal(data, md); err != nil {
+		log.Printf("failed to unmarshal metadata for commit %q: %v", commitHash, err)
+		return nil
+	}
+	return md
+}
 func (r *fileRepo) GetReverseDeps(commitHash string) []string {
 	data := r.getRawData("rdep", commitHash)
 	if data == nil {
@@ -89,17 +192,41 @@ func (r *fileRepo) GetReverseDeps(commitHash string) []string {
 	}
 	return rdeps
 }
+func (r *fileRepo) HashAlgorithm() graph.HashAlgorithm {
+	return r.alg
+}
 
 func (r *fileRepo) getRawData(bucketName, key string) []byte {
 	var val []byte
-	r.db.View(func(tx *bolt.Tx) error {
+	r.view(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketName))
-		val = b.Get([]byte(key))
+		if data := b.Get([]byte(key)); data != nil {
+			// Values returned by bolt are only valid for the life of the transaction.
+			val = append([]byte{}, data...)
+		}
 		return nil
 	})
 	return val
 }
 
+// view runs fn within the current transaction, if there is one, otherwise within a new read-only
+// transaction.
+func (r *fileRepo) view(fn func(tx *bolt.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	return r.db.View(fn)
+}
+
+// update runs fn within the current transaction, if there is one, otherwise within a new writable
+// transaction.
+func (r *fileRepo) update(fn func(tx *bolt.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	return r.db.Update(fn)
+}
+
 func encodeSliceSliceBytes(b [][]byte) []byte {
 	buf := bytes.NewBuffer(nil)
 	for _, line := range b {
@@ -137,7 +264,7 @@ func (r *fileRepo) ListCommits(start string, commits []string) (n int) {
 
 func (r *fileRepo) listObjs(bucket, start string, dst []string) (n int) {
 	var pos int
-	if err := r.db.View(func(tx *bolt.Tx) error {
+	if err := r.view(func(tx *bolt.Tx) error {
 		// Assume bucket exists and has keys
 		b := tx.Bucket([]byte(bucket))
 		c := b.Cursor()
@@ -153,27 +280,64 @@ func (r *fileRepo) listObjs(bucket, start string, dst []string) (n int) {
 }
 
 func (r *fileRepo) StartTransaction() {
-	fmt.Printf("Transaction started...\n")
+	if r.depth == 0 {
+		tx, err := r.db.Begin(true)
+		if err != nil {
+			panic(err)
+		}
+		r.tx = tx
+		r.aborted = false
+	}
+	r.depth++
 }
 func (r *fileRepo) EndTransaction() error {
-	fmt.Printf("Transaction completed\n")
-	return nil
+	if r.depth == 0 {
+		return fmt.Errorf("no transaction in progress")
+	}
+	r.depth--
+	if r.aborted {
+		if r.depth == 0 {
+			r.tx.Rollback()
+			r.tx = nil
+		}
+		return graph.ErrTransactionAborted
+	}
+	if r.depth > 0 {
+		return nil
+	}
+	tx := r.tx
+	r.tx = nil
+	return tx.Commit()
+}
+func (r *fileRepo) AbortTransaction() {
+	if r.depth == 0 {
+		return
+	}
+	r.depth--
+	r.aborted = true
+	if r.depth == 0 {
+		r.tx.Rollback()
+		r.tx = nil
+	}
 }
 
 func (r *fileRepo) PutRef(ptr, val string) {
-	if err := r.db.Update(func(tx *bolt.Tx) error {
+	if err := r.update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("ref"))
 		return b.Put([]byte(ptr), []byte(val))
 	}); err != nil {
 		panic(err)
 	}
 }
+func (r *fileRepo) DeleteRef(ptr string) {
+	r.deleteRawData("ref", ptr)
+}
 func (r *fileRepo) PutNode(n *jpb.Node) {
 	data, err := proto.Marshal(n)
 	if err != nil {
 		panic(err)
 	}
-	if err := r.db.Update(func(tx *bolt.Tx) error {
+	if err := r.update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("node"))
 		return b.Put([]byte(n.Head), data)
 	}); err != nil {
@@ -181,12 +345,12 @@ func (r *fileRepo) PutNode(n *jpb.Node) {
 	}
 }
 func (r *fileRepo) DeleteNode(nodeHash string) {
-	panic("not implemented")
+	r.deleteRawData("node", nodeHash)
 }
 func (r *fileRepo) PutContent(content [][]byte) string {
-	hash := graph.HashContent(content)
+	hash := r.alg.HashContent(content)
 	enc := encodeSliceSliceBytes(content)
-	if err := r.db.Update(func(tx *bolt.Tx) error {
+	if err := r.update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("content"))
 		return b.Put([]byte(hash), enc)
 	}); err != nil {
@@ -195,35 +359,87 @@ func (r *fileRepo) PutContent(content [][]byte) string {
 	return hash
 }
 func (r *fileRepo) DeleteContent(contentHash string) {
-	panic("not implemented")
+	r.deleteRawData("content", contentHash)
 }
 func (r *fileRepo) PutCommit(c *jpb.Commit) {
 	data, err := proto.Marshal(c)
 	if err != nil {
 		panic(err)
 	}
-	if err := r.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte("commit"))
-		return b.Put([]byte(graph.HashCommit(c)), data)
+	var md []byte
+	if c.Metadata != nil {
+		if md, err = proto.Marshal(c.Metadata); err != nil {
+			panic(err)
+		}
+	}
+	if err := r.update(func(tx *bolt.Tx) error {
+		hash := []byte(r.alg.HashCommit(c))
+		if md != nil {
+			// Metadata is also stored separately so that it can be read without the whole commit.
+			if err := tx.Bucket([]byte("meta")).Put(hash, md); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket([]byte("commit")).Put(hash, data)
 	}); err != nil {
 		panic(err)
 	}
 }
+func (r *fileRepo) DeleteCommit(commitHash string) {
+	if err := r.update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket([]byte("meta")).Delete([]byte(commitHash)); err != nil {
+			return err
+		}
+		return tx.Bucket([]byte("commit")).Delete([]byte(commitHash))
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// Reverse deps are keyed by the old commit, so that GetReverseDeps(oldCommit) returns every commit
+// that depends on it.
 func (r *fileRepo) PutReverseDep(newCommit, oldCommit string) {
-	if err := r.db.Update(func(tx *bolt.Tx) error {
+	if err := r.update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte("rdep"))
-		cur := b.Get([]byte(newCommit))
+		cur := b.Get([]byte(oldCommit))
 		var rdeps [][]byte
 		if cur != nil {
 			rdeps = decodeSliceSliceBytes(cur)
 		}
-		rdeps = append(rdeps, []byte(oldCommit))
+		rdeps = append(rdeps, []byte(newCommit))
 		enc := encodeSliceSliceBytes(rdeps)
-		return b.Put([]byte(newCommit), enc)
+		return b.Put([]byte(oldCommit), enc)
 	}); err != nil {
 		panic(err)
 	}
 }
 func (r *fileRepo) DeleteReverseDep(newCommit, oldCommit string) {
-	panic("not implemented")
+	if err := r.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("rdep"))
+		cur := b.Get([]byte(oldCommit))
+		if cur == nil {
+			return nil
+		}
+		var rdeps [][]byte
+		for _, rdep := range decodeSliceSliceBytes(cur) {
+			if string(rdep) != newCommit {
+				rdeps = append(rdeps, rdep)
+			}
+		}
+		if len(rdeps) == 0 {
+			return b.Delete([]byte(oldCommit))
+		}
+		return b.Put([]byte(oldCommit), encodeSliceSliceBytes(rdeps))
+	}); err != nil {
+		panic(err)
+	}
+}
+
+func (r *fileRepo) deleteRawData(bucketName, key string) {
+	if err := r.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucketName))
+		return b.Delete([]byte(key))
+	}); err != nil {
+		panic(err)
+	}
 }
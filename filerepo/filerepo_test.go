@@ -0,0 +1,208 @@
+package filerepo_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/runningwild/jig/filerepo"
+	"github.com/runningwild/jig/graph"
+	jpb "github.com/runningwild/jig/proto"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func stringsToContent(ss ...string) [][]byte {
+	var content [][]byte
+	for _, s := range ss {
+		content = append(content, []byte(s))
+	}
+	return content
+}
+
+type allFrontier struct{}
+
+func (allFrontier) Observes(string) (bool, error) { return true, nil }
+
+// countAll returns how many of each kind of object r has.
+func countAll(r graph.Repo) [4]int {
+	var counts [4]int
+	buf := make([]string, 1000)
+	for i, list := range []func(string, []string) int{r.ListRefs, r.ListNodes, r.ListContents, r.ListCommits} {
+		counts[i] = list("", buf)
+	}
+	return counts
+}
+
+func readFoo(r graph.Repo) string {
+	lines, err := graph.ReadVersion(r, allFrontier{}, "src:foo.txt", "snk:foo.txt", nil)
+	So(err, ShouldBeNil)
+	return string(bytes.Join(lines, []byte(".")))
+}
+
+func TestTransactions(t *testing.T) {
+	Convey("A file repo", t, func() {
+		dir := t.TempDir()
+		r, err := filerepo.Make(dir)
+		So(err, ShouldBeNil)
+		c0 := &jpb.Commit{
+			EdgeRefs: []*jpb.EdgeRef{
+				{
+					Src:    &jpb.NodeRef{Node: "src:foo.txt", Depth: 1},
+					Chunks: stringsToContent("alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf"),
+					Dst:    &jpb.NodeRef{Node: "snk:foo.txt"},
+				},
+			},
+		}
+		So(graph.Apply(r, c0), ShouldBeNil)
+		var ranges []graph.ReadRange
+		_, err = graph.ReadVersion(r, allFrontier{}, "src:foo.txt", "snk:foo.txt", &graph.ReadMetadata{Ranges: &ranges})
+		So(err, ShouldBeNil)
+		head := ranges[0].Node
+		before := countAll(r)
+
+		Convey("can be closed and opened again", func() {
+			So(filerepo.Close(r), ShouldBeNil)
+			r, err := filerepo.Make(dir)
+			So(err, ShouldBeNil)
+			So(countAll(r), ShouldResemble, before)
+			So(readFoo(r), ShouldEqual, "alpha.bravo.charlie.delta.echo.foxtrot.golf")
+			So(filerepo.Close(r), ShouldBeNil)
+		})
+
+		Convey("leaves the repo untouched if a commit fails partway through", func() {
+			// The first two edges split nodes, each in a transaction nested in Apply's, before the
+			// third one fails.
+			c1 := &jpb.Commit{
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
+				EdgeRefs: []*jpb.EdgeRef{
+					{
+						Src:    &jpb.NodeRef{Node: head, Depth: 3}, // charlie
+						Chunks: stringsToContent("thunder"),
+						Dst:    &jpb.NodeRef{Node: head, Depth: 3}, // delta
+					},
+					{
+						Src: &jpb.NodeRef{Node: head, Depth: 5}, // echo
+						Dst: &jpb.NodeRef{Node: head, Depth: 6}, // golf
+					},
+					{
+						Src: &jpb.NodeRef{Node: head, Depth: 20}, // past the end of the file
+						Dst: &jpb.NodeRef{Node: "snk:foo.txt"},
+					},
+				},
+			}
+			So(graph.Apply(r, c1), ShouldNotBeNil)
+			So(r.GetCommit(r.HashAlgorithm().HashCommit(c1)), ShouldBeNil)
+			n := r.GetNode(head)
+			So(n.Count, ShouldEqual, 7)
+			So(n.Out, ShouldHaveLength, 1)
+			So(countAll(r), ShouldResemble, before)
+			So(readFoo(r), ShouldEqual, "alpha.bravo.charlie.delta.echo.foxtrot.golf")
+
+			Convey("and can still apply commits afterwards", func() {
+				c1.EdgeRefs = c1.EdgeRefs[0:2]
+				So(graph.Apply(r, c1), ShouldBeNil)
+				So(readFoo(r), ShouldEqual, "alpha.bravo.charlie.thunder.delta.echo.golf")
+			})
+		})
+
+		Convey("rolls back a SplitNode nested in a transaction that is aborted", func() {
+			r.StartTransaction()
+			_, _, err := graph.SplitNode(r, head, 3)
+			So(err, ShouldBeNil)
+			So(r.GetNode(head).Count, ShouldEqual, 3)
+			r.AbortTransaction()
+			So(r.GetNode(head).Count, ShouldEqual, 7)
+			So(countAll(r), ShouldResemble, before)
+			So(readFoo(r), ShouldEqual, "alpha.bravo.charlie.delta.echo.foxtrot.golf")
+		})
+
+		Convey("rolls back everything if a nested transaction is aborted", func() {
+			r.StartTransaction()
+			r.PutRef("outer", head)
+			r.StartTransaction()
+			r.PutRef("inner", head)
+			r.AbortTransaction()
+			So(errors.Is(r.EndTransaction(), graph.ErrTransactionAborted), ShouldBeTrue)
+			So(r.GetRef("outer"), ShouldEqual, "")
+			So(r.GetRef("inner"), ShouldEqual, "")
+			So(countAll(r), ShouldResemble, before)
+		})
+
+		Convey("only commits when the outermost transaction ends", func() {
+			r.StartTransaction()
+			r.StartTransaction()
+			r.PutRef("inner", head)
+			So(r.EndTransaction(), ShouldBeNil)
+			r.AbortTransaction()
+			So(r.GetRef("inner"), ShouldEqual, "")
+
+			r.StartTransaction()
+			r.StartTransaction()
+			r.PutRef("inner", head)
+			So(r.EndTransaction(), ShouldBeNil)
+			So(r.EndTransaction(), ShouldBeNil)
+			So(r.GetRef("inner"), ShouldEqual, head)
+		})
+	})
+}
+
+func TestReverseDeps(t *testing.T) {
+	Convey("A file repo", t, func() {
+		dir := t.TempDir()
+		r, err := filerepo.Make(dir)
+		So(err, ShouldBeNil)
+		c0 := &jpb.Commit{
+			EdgeRefs: []*jpb.EdgeRef{
+				{
+					Src:    &jpb.NodeRef{Node: "src:foo.txt", Depth: 1},
+					Chunks: stringsToContent("alpha"),
+					Dst:    &jpb.NodeRef{Node: "snk:foo.txt"},
+				},
+			},
+		}
+		So(graph.Apply(r, c0), ShouldBeNil)
+		h0 := r.HashAlgorithm().HashCommit(c0)
+		c1 := &jpb.Commit{
+			Deps: []string{h0},
+			EdgeRefs: []*jpb.EdgeRef{
+				{
+					Src:    &jpb.NodeRef{Node: "src:bar.txt", Depth: 1},
+					Chunks: stringsToContent("bravo"),
+					Dst:    &jpb.NodeRef{Node: "snk:bar.txt"},
+				},
+			},
+		}
+		So(graph.Apply(r, c1), ShouldBeNil)
+		h1 := r.HashAlgorithm().HashCommit(c1)
+		So(r.GetReverseDeps(h0), ShouldResemble, []string{h1})
+		So(r.GetReverseDeps(h1), ShouldBeEmpty)
+
+		Convey("converts reverse deps that were keyed by the new commit", func() {
+			So(filerepo.Close(r), ShouldBeNil)
+			db, err := bolt.Open(filepath.Join(dir, "db"), 0600, nil)
+			So(err, ShouldBeNil)
+			So(db.Update(func(tx *bolt.Tx) error {
+				if err := tx.Bucket([]byte("config")).Delete([]byte("rdep")); err != nil {
+					return err
+				}
+				b := tx.Bucket([]byte("rdep"))
+				if err := b.Delete([]byte(h0)); err != nil {
+					return err
+				}
+				old := append(binary.LittleEndian.AppendUint32(nil, uint32(len(h0))), h0...)
+				return b.Put([]byte(h1), old)
+			}), ShouldBeNil)
+			So(db.Close(), ShouldBeNil)
+
+			r, err = filerepo.Make(dir)
+			So(err, ShouldBeNil)
+			So(r.GetReverseDeps(h0), ShouldResemble, []string{h1})
+			So(r.GetReverseDeps(h1), ShouldBeEmpty)
+			So(filerepo.Close(r), ShouldBeNil)
+		})
+	})
+}
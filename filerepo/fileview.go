@@ -1,67 +1,353 @@
 package filerepo
 
 import (
+	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/boltdb/bolt"
 	"github.com/runningwild/jig/graph"
 )
 
+// The frontiers bucket holds a bucket for each frontier, named after it.  Each of those holds a
+// graph.CommitSet as a bucket of its heads and a bucket of its exclusions, along with the frontier's
+// metadata and a log of every change made to it.  Anything that isn't a frontier, like the name of
+// the current frontier, is kept in the view bucket.  The stash bucket holds the stash list.
+const (
+	frontiersBucket = "frontiers"
+	viewBucket      = "view"
+	stashBucket     = "stash"
+	headsBucket     = "heads"
+	excludesBucket  = "excludes"
+	logBucket       = "log"
+	createdKey      = "created"
+	descriptionKey  = "description"
+	tagKey          = "tag"
+	currentKey      = "current"
+)
+
 type fileView struct {
 	db *bolt.DB
+
+	// r is used to work out which commits a frontier observes from its heads.
+	r graph.Repo
 }
 
-func MakeView(dir string) (graph.View, error) {
+func MakeView(dir string, r graph.Repo) (graph.View, error) {
 	os.Mkdir(dir, 0777)
 	db, err := bolt.Open(filepath.Join(dir, "view"), 0600, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create view: %w", err)
 	}
 	if err := db.Update(func(tx *bolt.Tx) error {
-		for _, name := range []string{"frontiers"} {
+		for _, name := range []string{frontiersBucket, viewBucket, stashBucket} {
 			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
 				return fmt.Errorf("create bucket: %s", err)
 			}
 		}
-		b := tx.Bucket([]byte("frontiers"))
-		if b.Stats().BucketN == 1 {
-			if _, err := b.CreateBucket([]byte("main")); err != nil {
-				return fmt.Errorf("failed to create initial branch: %v", err)
+		b := tx.Bucket([]byte(frontiersBucket))
+		vb := tx.Bucket([]byte(viewBucket))
+
+		// The current frontier used to be stored alongside the frontiers.
+		if current := b.Get([]byte(currentKey)); current != nil && b.Bucket([]byte(currentKey)) == nil {
+			if err := vb.Put([]byte(currentKey), append([]byte{}, current...)); err != nil {
+				return err
+			}
+			if err := b.Delete([]byte(currentKey)); err != nil {
+				return err
+			}
+		}
+		if err := convertFrontiers(b, r); err != nil {
+			return err
+		}
+		if vb.Get([]byte(currentKey)) == nil {
+			if b.Bucket([]byte("main")) == nil {
+				if _, err := createFrontier(b, "main", graph.CommitSetOf(r, nil), "create"); err != nil {
+					return fmt.Errorf("failed to create initial branch: %v", err)
+				}
 			}
+			return vb.Put([]byte(currentKey), []byte("main"))
 		}
-		return b.Put([]byte("current"), []byte("main"))
+		return nil
 	}); err != nil {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
 	return &fileView{
 		db: db,
+		r:  r,
 	}, nil
 }
 
-func (v *fileView) getRawData(bucketName, key string) ([]byte, error) {
-	var val []byte
-	if err := v.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(bucketName))
-		val = b.Get([]byte(key))
-		if val == nil {
-			return fmt.Errorf("not found")
+// convertFrontiers rewrites any frontier stored the old way, as a bucket with a key for every commit
+// it observes, as a graph.CommitSet.
+func convertFrontiers(b *bolt.Bucket, r graph.Repo) error {
+	var names [][]byte
+	if err := b.ForEach(func(name, _ []byte) error {
+		if f := b.Bucket(name); f != nil && f.Bucket([]byte(headsBucket)) == nil {
+			names = append(names, append([]byte{}, name...))
 		}
 		return nil
 	}); err != nil {
+		return err
+	}
+	for _, name := range names {
+		var commits []string
+		if err := b.Bucket(name).ForEach(func(k, _ []byte) error {
+			commits = append(commits, string(k))
+			return nil
+		}); err != nil {
+			return err
+		}
+		if err := b.DeleteBucket(name); err != nil {
+			return err
+		}
+		f, err := b.CreateBucket(name)
+		if err != nil {
+			return err
+		}
+		if err := putCommitSet(f, graph.CommitSetOf(r, commits)); err != nil {
+			return fmt.Errorf("failed to convert frontier %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// createFrontier creates a frontier named name in the frontiers bucket b that observes s, logs its
+// creation as op, and returns its bucket.
+func createFrontier(b *bolt.Bucket, name string, s *graph.CommitSet, op string) (*bolt.Bucket, error) {
+	if name == "" {
+		return nil, fmt.Errorf("frontiers must have a name")
+	}
+	f, err := b.CreateBucket([]byte(name))
+	if err == bolt.ErrBucketExists {
+		return nil, fmt.Errorf("%q: %w", name, graph.ErrFrontierExists)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create frontier %q: %v", name, err)
+	}
+	created, err := time.Now().UTC().MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Put([]byte(createdKey), created); err != nil {
+		return nil, err
+	}
+	if err := putCommitSet(f, s); err != nil {
+		return nil, err
+	}
+	return f, appendLog(f, op, "")
+}
+
+// appendLog records in the log of the frontier stored in f that op was done to it with commit, along
+// with the commits that it observes now that op is done.
+func appendLog(f *bolt.Bucket, op, commit string) error {
+	log, err := f.CreateBucketIfNotExists([]byte(logBucket))
+	if err != nil {
+		return err
+	}
+	index, err := log.NextSequence()
+	if err != nil {
+		return err
+	}
+	return putLogEntry(log, graph.FrontierLogEntry{
+		Index:    index,
+		Time:     time.Now().UTC(),
+		Op:       op,
+		Commit:   commit,
+		Heads:    bucketKeys(f.Bucket([]byte(headsBucket))),
+		Excludes: bucketKeys(f.Bucket([]byte(excludesBucket))),
+	})
+}
+
+// Log entries are stored as JSON, keyed by their index in big-endian order so that they are sorted.
+func putLogEntry(log *bolt.Bucket, entry graph.FrontierLogEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return log.Put(indexKey(entry.Index), data)
+}
+
+func indexKey(index uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, index)
+	return key
+}
+
+func logEntries(f *bolt.Bucket) ([]graph.FrontierLogEntry, error) {
+	log := f.Bucket([]byte(logBucket))
+	if log == nil {
+		return nil, nil
+	}
+	var entries []graph.FrontierLogEntry
+	err := log.ForEach(func(k, v []byte) error {
+		var entry graph.FrontierLogEntry
+		if err := json.Unmarshal(v, &entry); err != nil {
+			return fmt.Errorf("bad log entry %x: %v", k, err)
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+// Stash entries are stored the same way as log entries.
+func putStashEntry(b *bolt.Bucket, entry graph.StashEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return b.Put(indexKey(entry.Index), data)
+}
+
+func stashEntries(tx *bolt.Tx) ([]graph.StashEntry, error) {
+	var entries []graph.StashEntry
+	err := tx.Bucket([]byte(stashBucket)).ForEach(func(k, data []byte) error {
+		var entry graph.StashEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return fmt.Errorf("bad stash entry %x: %v", k, err)
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+func getStashEntry(b *bolt.Bucket, index uint64) (graph.StashEntry, error) {
+	var entry graph.StashEntry
+	data := b.Get(indexKey(index))
+	if data == nil {
+		return entry, fmt.Errorf("stash@{%d}: %w", index, graph.ErrUnknownStash)
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, fmt.Errorf("bad stash entry %d: %v", index, err)
+	}
+	return entry, nil
+}
+
+// getCommitSet returns the commits observed by the frontier stored in f.
+func getCommitSet(f *bolt.Bucket, r graph.Repo) *graph.CommitSet {
+	return graph.NewCommitSet(r, bucketKeys(f.Bucket([]byte(headsBucket))), bucketKeys(f.Bucket([]byte(excludesBucket))))
+}
+
+// putCommitSet stores s in the frontier bucket f, replacing whatever was there.
+func putCommitSet(f *bolt.Bucket, s *graph.CommitSet) error {
+	return putCommitLists(f, s.Heads(), s.Excludes())
+}
+
+func putCommitLists(f *bolt.Bucket, heads, excludes []string) error {
+	for name, commits := range map[string][]string{headsBucket: heads, excludesBucket: excludes} {
+		if f.Bucket([]byte(name)) != nil {
+			if err := f.DeleteBucket([]byte(name)); err != nil {
+				return err
+			}
+		}
+		b, err := f.CreateBucket([]byte(name))
+		if err != nil {
+			return err
+		}
+		for _, commit := range commits {
+			if err := b.Put([]byte(commit), []byte{}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func bucketKeys(b *bolt.Bucket) []string {
+	var keys []string
+	if b == nil {
+		return nil
+	}
+	b.ForEach(func(k, _ []byte) error {
+		keys = append(keys, string(k))
+		return nil
+	})
+	return keys
+}
+
+// copyBucket copies everything in src, including nested buckets and sequence numbers, into dst.
+func copyBucket(dst, src *bolt.Bucket) error {
+	if err := dst.SetSequence(src.Sequence()); err != nil {
+		return err
+	}
+	return src.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return dst.Put(k, v)
+		}
+		nested, err := dst.CreateBucket(k)
+		if err != nil {
+			return err
+		}
+		return copyBucket(nested, src.Bucket(k))
+	})
+}
+
+// frontierInfo returns the FrontierInfo for the frontier stored in f.
+func frontierInfo(name string, f *bolt.Bucket) (graph.FrontierInfo, error) {
+	info := graph.FrontierInfo{
+		Name:        name,
+		Heads:       bucketKeys(f.Bucket([]byte(headsBucket))),
+		Description: string(f.Get([]byte(descriptionKey))),
+		Tag:         f.Get([]byte(tagKey)) != nil,
+	}
+	if created := f.Get([]byte(createdKey)); created != nil {
+		if err := info.Created.UnmarshalText(created); err != nil {
+			return graph.FrontierInfo{}, fmt.Errorf("frontier %q has a bad creation time: %v", name, err)
+		}
+	}
+	return info, nil
+}
+
+// mutableFrontier is like frontier but returns an error wrapping graph.ErrTag if the frontier is a
+// tag.
+func mutableFrontier(tx *bolt.Tx, name string) (*bolt.Bucket, error) {
+	f, err := frontier(tx, name)
+	if err != nil {
 		return nil, err
 	}
-	return val, nil
+	if f.Get([]byte(tagKey)) != nil {
+		return nil, fmt.Errorf("%q: %w", name, graph.ErrTag)
+	}
+	return f, nil
+}
+
+// frontier returns the bucket of the frontier named name.
+func frontier(tx *bolt.Tx, name string) (*bolt.Bucket, error) {
+	b := tx.Bucket([]byte(frontiersBucket))
+	if b == nil {
+		return nil, fmt.Errorf("frontiers bucket not found")
+	}
+	f := b.Bucket([]byte(name))
+	if f == nil {
+		return nil, fmt.Errorf("%q: %w", name, graph.ErrUnknownFrontier)
+	}
+	return f, nil
 }
-func (v *fileView) listObjs(bucket, start string, dst []string) (n int, err error) {
+
+func currentFrontier(tx *bolt.Tx) (string, error) {
+	c := tx.Bucket([]byte(viewBucket)).Get([]byte(currentKey))
+	if c == nil {
+		return "", fmt.Errorf("current frontier unknown")
+	}
+	return string(c), nil
+}
+
+func (v *fileView) ListFrontiers(start string, frontiers []graph.FrontierInfo) (n int, err error) {
 	var pos int
 	if err := v.db.View(func(tx *bolt.Tx) error {
-		// Assume bucket exists and has keys
-		b := tx.Bucket([]byte(bucket))
+		b := tx.Bucket([]byte(frontiersBucket))
 		c := b.Cursor()
-		for k, _ := c.Seek([]byte(start)); k != nil && pos < len(dst); k, _ = c.Next() {
-			dst[pos] = string(k)
+		for k, _ := c.Seek([]byte(start)); k != nil && pos < len(frontiers); k, _ = c.Next() {
+			info, err := frontierInfo(string(k), b.Bucket(k))
+			if err != nil {
+				return err
+			}
+			frontiers[pos] = info
 			pos++
 		}
 		return nil
@@ -70,113 +356,380 @@ func (v *fileView) listObjs(bucket, start string, dst []string) (n int, err erro
 	}
 	return pos, nil
 }
-func (v *fileView) ListFrontiers(start string, frontiers []string) (n int, err error) {
-	return v.listObjs("frontiers", start, frontiers)
+func (v *fileView) StatFrontier(name string) (graph.FrontierInfo, error) {
+	var info graph.FrontierInfo
+	err := v.db.View(func(tx *bolt.Tx) error {
+		f, err := frontier(tx, name)
+		if err != nil {
+			return err
+		}
+		info, err = frontierInfo(name, f)
+		return err
+	})
+	return info, err
 }
 func (v *fileView) CurrentFrontier() (string, error) {
-	data, err := v.getRawData("frontiers", "current")
-	if err != nil {
-		return "", err
-	}
-	return string(data), nil
+	var current string
+	err := v.db.View(func(tx *bolt.Tx) (err error) {
+		current, err = currentFrontier(tx)
+		return err
+	})
+	return current, err
 }
-func (v *fileView) ChangeFrontiers(frontier string) error {
-	if err := v.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte("frontiers"))
-		if f := b.Bucket([]byte(frontier)); f == nil {
-			return fmt.Errorf("frontier not found")
+func (v *fileView) ChangeFrontiers(name string) error {
+	return v.db.Update(func(tx *bolt.Tx) error {
+		f, err := frontier(tx, name)
+		if err != nil {
+			return err
 		}
-		return b.Put([]byte("current"), []byte(frontier))
-	}); err != nil {
-		return err
-	}
-	return nil
+		if err := tx.Bucket([]byte(viewBucket)).Put([]byte(currentKey), []byte(name)); err != nil {
+			return err
+		}
+		return appendLog(f, "switch", "")
+	})
 }
+
+// AdvanceFrontier adds commit to the current frontier, along with anything it depends on that the
+// frontier doesn't already observe.
 func (v *fileView) AdvanceFrontier(commit string) error {
+	return v.updateCurrent("advance", commit, func(s *graph.CommitSet) (*graph.CommitSet, error) {
+		return s.Advance(commit)
+	})
+}
+
+// RetreatFrontier removes commit from the current frontier, along with everything in it that
+// depends on commit.
+func (v *fileView) RetreatFrontier(commit string) error {
+	return v.updateCurrent("retreat", commit, func(s *graph.CommitSet) (*graph.CommitSet, error) {
+		obs, err := s.Observes(commit)
+		if err != nil {
+			return nil, err
+		}
+		if !obs {
+			return nil, fmt.Errorf("commit %q: %w", commit, graph.ErrNoObserve)
+		}
+		return s.Retreat(commit), nil
+	})
+}
+
+// MergeFrontier adds every commit that from observes to the current frontier.
+func (v *fileView) MergeFrontier(from string) (*graph.MergeReport, error) {
+	var merged, added *graph.CommitSet
 	if err := v.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte("frontiers"))
-		if b == nil {
-			return fmt.Errorf("frontiers bucket not found")
+		current, err := currentFrontier(tx)
+		if err != nil {
+			return err
 		}
-		c := b.Get([]byte("current"))
-		if c == nil {
-			return fmt.Errorf("current frontier unknown")
+		f, err := mutableFrontier(tx, current)
+		if err != nil {
+			return err
 		}
-		f := b.Bucket(c)
-		if f == nil {
-			return fmt.Errorf("current frontier unspecified")
+		other, err := frontier(tx, from)
+		if err != nil {
+			return err
+		}
+		s, o := getCommitSet(f, v.r), getCommitSet(other, v.r)
+		merged, added = s.Union(o), o.Difference(s)
+		if err := putCommitSet(f, merged); err != nil {
+			return err
 		}
-		return f.Put([]byte(commit), []byte{})
+		return appendLog(f, "merge", "")
 	}); err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return graph.ReportMerge(v.r, merged, added.Commits())
 }
-func (v *fileView) CreateFrontier(frontier string) error {
-	if err := v.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte("frontiers"))
-		if b == nil {
-			return fmt.Errorf("frontiers bucket not found")
+
+// updateCurrent replaces the commits observed by the current frontier with the result of update,
+// and logs it as op with commit.
+func (v *fileView) updateCurrent(op, commit string, update func(s *graph.CommitSet) (*graph.CommitSet, error)) error {
+	return v.db.Update(func(tx *bolt.Tx) error {
+		current, err := currentFrontier(tx)
+		if err != nil {
+			return err
+		}
+		f, err := mutableFrontier(tx, current)
+		if err != nil {
+			return err
+		}
+		s, err := update(getCommitSet(f, v.r))
+		if err != nil {
+			return err
 		}
-		c := b.Get([]byte("current"))
-		if c == nil {
-			return fmt.Errorf("current frontier unknown")
+		if err := putCommitSet(f, s); err != nil {
+			return err
 		}
-		from := b.Bucket(c)
-		if from == nil {
-			return fmt.Errorf("current frontier unspecified")
+		return appendLog(f, op, commit)
+	})
+}
+func (v *fileView) CreateFrontier(name string) error {
+	return v.db.Update(func(tx *bolt.Tx) error {
+		current, err := currentFrontier(tx)
+		if err != nil {
+			return err
 		}
-		to, err := b.CreateBucket([]byte(frontier))
+		from, err := frontier(tx, current)
 		if err != nil {
-			return fmt.Errorf("failed to create frontier %q: %v", frontier, err)
+			return err
 		}
-		if err := from.ForEach(func(k, v []byte) error {
-			return to.Put(k, v)
-		}); err != nil {
+		_, err = createFrontier(tx.Bucket([]byte(frontiersBucket)), name, getCommitSet(from, v.r), "create")
+		return err
+	})
+}
+func (v *fileView) CreateTag(tag, name string) error {
+	return v.db.Update(func(tx *bolt.Tx) error {
+		from, err := frontier(tx, name)
+		if err != nil {
+			return err
+		}
+		f, err := createFrontier(tx.Bucket([]byte(frontiersBucket)), tag, getCommitSet(from, v.r), "tag")
+		if err != nil {
 			return err
 		}
+		return f.Put([]byte(tagKey), []byte{})
+	})
+}
+func (v *fileView) DeleteFrontier(name string) error {
+	return v.db.Update(func(tx *bolt.Tx) error {
+		if _, err := frontier(tx, name); err != nil {
+			return err
+		}
+		if current, err := currentFrontier(tx); err != nil {
+			return err
+		} else if current == name {
+			return fmt.Errorf("cannot delete %q: %w", name, graph.ErrCurrentFrontier)
+		}
+		return tx.Bucket([]byte(frontiersBucket)).DeleteBucket([]byte(name))
+	})
+}
+func (v *fileView) RenameFrontier(from, to string) error {
+	return v.db.Update(func(tx *bolt.Tx) error {
+		src, err := frontier(tx, from)
+		if err != nil {
+			return err
+		}
+		if to == "" {
+			return fmt.Errorf("frontiers must have a name")
+		}
+		b := tx.Bucket([]byte(frontiersBucket))
+		dst, err := b.CreateBucket([]byte(to))
+		if err == bolt.ErrBucketExists {
+			return fmt.Errorf("%q: %w", to, graph.ErrFrontierExists)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create frontier %q: %v", to, err)
+		}
+		if err := copyBucket(dst, src); err != nil {
+			return err
+		}
+		if err := b.DeleteBucket([]byte(from)); err != nil {
+			return err
+		}
+		if err := appendLog(dst, "rename", ""); err != nil {
+			return err
+		}
+		if current, err := currentFrontier(tx); err != nil {
+			return err
+		} else if current == from {
+			return tx.Bucket([]byte(viewBucket)).Put([]byte(currentKey), []byte(to))
+		}
 		return nil
-	}); err != nil {
+	})
+}
+func (v *fileView) DescribeFrontier(name, description string) error {
+	return v.db.Update(func(tx *bolt.Tx) error {
+		f, err := frontier(tx, name)
+		if err != nil {
+			return err
+		}
+		return f.Put([]byte(descriptionKey), []byte(description))
+	})
+}
+
+func (v *fileView) FrontierLog(name string) ([]graph.FrontierLogEntry, error) {
+	var entries []graph.FrontierLogEntry
+	err := v.db.View(func(tx *bolt.Tx) error {
+		f, err := frontier(tx, name)
+		if err != nil {
+			return err
+		}
+		entries, err = logEntries(f)
 		return err
-	}
-	return nil
+	})
+	return entries, err
+}
+func (v *fileView) RestoreFrontier(name string, index uint64) error {
+	return v.db.Update(func(tx *bolt.Tx) error {
+		f, err := mutableFrontier(tx, name)
+		if err != nil {
+			return err
+		}
+		entries, err := logEntries(f)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.Index == index {
+				if err := putCommitLists(f, entry.Heads, entry.Excludes); err != nil {
+					return err
+				}
+				return appendLog(f, "restore", "")
+			}
+		}
+		return fmt.Errorf("frontier %q has no log entry %d", name, index)
+	})
 }
 
-func (v *fileView) GetFrontier(frontier string) (graph.Frontier, error) {
+func (v *fileView) PushStash(commit string) error {
+	if v.r.GetCommit(commit) == nil {
+		return fmt.Errorf("commit %q: %w", commit, graph.ErrUnknownCommit)
+	}
+	return v.db.Update(func(tx *bolt.Tx) error {
+		current, err := currentFrontier(tx)
+		if err != nil {
+			return err
+		}
+		b := tx.Bucket([]byte(stashBucket))
+		index, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		return putStashEntry(b, graph.StashEntry{
+			Index:    index,
+			Time:     time.Now().UTC(),
+			Frontier: current,
+			Commit:   commit,
+		})
+	})
+}
+func (v *fileView) ListStashes() ([]graph.StashEntry, error) {
+	var entries []graph.StashEntry
 	err := v.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte("frontiers"))
-		if b == nil {
-			return fmt.Errorf("frontiers bucket not found")
+		var err error
+		entries, err = stashEntries(tx)
+		return err
+	})
+	return entries, err
+}
+
+// PopStash advances the current frontier with a stashed commit, and removes it from the stash list.
+func (v *fileView) PopStash(index uint64) (*graph.MergeReport, error) {
+	var popped, added *graph.CommitSet
+	if err := v.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(stashBucket))
+		entry, err := getStashEntry(b, index)
+		if err != nil {
+			return err
+		}
+		current, err := currentFrontier(tx)
+		if err != nil {
+			return err
+		}
+		f, err := mutableFrontier(tx, current)
+		if err != nil {
+			return err
+		}
+		s := getCommitSet(f, v.r)
+		if popped, err = s.Advance(entry.Commit); err != nil {
+			return err
 		}
-		if b.Bucket([]byte(frontier)) == nil {
-			return fmt.Errorf("frontier unknown")
+		added = popped.Difference(s)
+		if err := putCommitSet(f, popped); err != nil {
+			return err
 		}
+		if err := appendLog(f, "pop", entry.Commit); err != nil {
+			return err
+		}
+		return b.Delete(indexKey(index))
+	}); err != nil {
+		return nil, err
+	}
+	return graph.ReportMerge(v.r, popped, added.Commits())
+}
+func (v *fileView) DropStash(index uint64) error {
+	return v.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(stashBucket))
+		if _, err := getStashEntry(b, index); err != nil {
+			return err
+		}
+		return b.Delete(indexKey(index))
+	})
+}
+
+// GetFrontier returns the commits that frontier observes.  Later changes to frontier don't affect the
+// returned Frontier.
+func (v *fileView) GetFrontier(name string) (graph.Frontier, error) {
+	var s *graph.CommitSet
+	err := v.db.View(func(tx *bolt.Tx) error {
+		f, err := frontier(tx, name)
+		if err != nil {
+			return err
+		}
+		s = getCommitSet(f, v.r)
 		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
-	return &boltFrontier{frontier: frontier, v: v}, nil
-}
-
-type boltFrontier struct {
-	frontier string
-	v        *fileView
+	return s, nil
 }
 
-func (bf *boltFrontier) Observes(commit string) (bool, error) {
-	var observes bool
-	err := bf.v.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte("frontiers"))
+// RemapCommits changes every frontier and stash entry in v, which must have been made by MakeView, so
+// that it refers to commits[c] instead of c for every commit c in commits.  This is used after a repo has
+// been rewritten with graph.Migrate.
+func RemapCommits(v graph.View, commits map[string]string) error {
+	fv, ok := v.(*fileView)
+	if !ok {
+		return fmt.Errorf("view was not made by MakeView")
+	}
+	return fv.db.Update(func(tx *bolt.Tx) error {
+		entries, err := stashEntries(tx)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if newCommit, ok := commits[entry.Commit]; ok {
+				entry.Commit = newCommit
+				if err := putStashEntry(tx.Bucket([]byte(stashBucket)), entry); err != nil {
+					return err
+				}
+			}
+		}
+		b := tx.Bucket([]byte(frontiersBucket))
 		if b == nil {
 			return fmt.Errorf("frontiers bucket not found")
 		}
-		f := b.Bucket([]byte(bf.frontier))
-		if f == nil {
-			return fmt.Errorf("frontier unknown")
-		}
-		observes = f.Get([]byte(commit)) != nil
-		return nil
+		return b.ForEach(func(name, _ []byte) error {
+			f := b.Bucket(name)
+
+			// Commits keep their deps when they are migrated, so the heads of a frontier are still
+			// its heads afterwards.
+			remap := func(list []string) []string {
+				for i, commit := range list {
+					if newCommit, ok := commits[commit]; ok {
+						list[i] = newCommit
+					}
+				}
+				return list
+			}
+			heads := remap(bucketKeys(f.Bucket([]byte(headsBucket))))
+			excludes := remap(bucketKeys(f.Bucket([]byte(excludesBucket))))
+			if err := putCommitLists(f, heads, excludes); err != nil {
+				return err
+			}
+			entries, err := logEntries(f)
+			if err != nil {
+				return err
+			}
+			for _, entry := range entries {
+				entry.Commit = remap([]string{entry.Commit})[0]
+				entry.Heads = remap(entry.Heads)
+				entry.Excludes = remap(entry.Excludes)
+				if err := putLogEntry(f.Bucket([]byte(logBucket)), entry); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
 	})
-	return observes, err
 }
@@ -0,0 +1,256 @@
+package filerepo_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/runningwild/jig/filerepo"
+	"github.com/runningwild/jig/graph"
+	jpb "github.com/runningwild/jig/proto"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// writeOldView writes a view to dir the way it used to be stored, with the name of the current
+// frontier kept in the frontiers bucket, and each frontier as a bucket with a key for every commit it
+// observes.
+func writeOldView(dir, current string, frontiers map[string][]string) {
+	db, err := bolt.Open(filepath.Join(dir, "view"), 0600, nil)
+	So(err, ShouldBeNil)
+	So(db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("frontiers"))
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte("current"), []byte(current)); err != nil {
+			return err
+		}
+		for name, commits := range frontiers {
+			f, err := b.CreateBucket([]byte(name))
+			if err != nil {
+				return err
+			}
+			for _, commit := range commits {
+				if err := f.Put([]byte(commit), []byte{}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}), ShouldBeNil)
+	So(db.Close(), ShouldBeNil)
+}
+
+func listFrontiers(v graph.View) []string {
+	infos := make([]graph.FrontierInfo, 10)
+	n, err := v.ListFrontiers("", infos)
+	So(err, ShouldBeNil)
+	var names []string
+	for _, info := range infos[0:n] {
+		names = append(names, info.Name)
+	}
+	return names
+}
+
+func observes(v graph.View, name, commit string) bool {
+	f, err := v.GetFrontier(name)
+	So(err, ShouldBeNil)
+	obs, err := f.Observes(commit)
+	So(err, ShouldBeNil)
+	return obs
+}
+
+// logOps returns the op of every entry in the log of the frontier name, and checks that their
+// indices count up from 1.
+func logOps(v graph.View, name string) []string {
+	entries, err := v.FrontierLog(name)
+	So(err, ShouldBeNil)
+	var ops []string
+	for i, entry := range entries {
+		So(entry.Index, ShouldEqual, i+1)
+		ops = append(ops, entry.Op)
+	}
+	return ops
+}
+
+func TestView(t *testing.T) {
+	Convey("A file view", t, func() {
+		dir := t.TempDir()
+		r, err := filerepo.Make(dir)
+		So(err, ShouldBeNil)
+		c0 := &jpb.Commit{
+			EdgeRefs: []*jpb.EdgeRef{
+				{
+					Src:    &jpb.NodeRef{Node: "src:foo.txt", Depth: 1},
+					Chunks: stringsToContent("alpha"),
+					Dst:    &jpb.NodeRef{Node: "snk:foo.txt"},
+				},
+			},
+		}
+		So(graph.Apply(r, c0), ShouldBeNil)
+		h0 := r.HashAlgorithm().HashCommit(c0)
+		c1 := &jpb.Commit{
+			Deps: []string{h0},
+			EdgeRefs: []*jpb.EdgeRef{
+				{
+					Src:    &jpb.NodeRef{Node: "src:bar.txt", Depth: 1},
+					Chunks: stringsToContent("bravo"),
+					Dst:    &jpb.NodeRef{Node: "snk:bar.txt"},
+				},
+			},
+		}
+		So(graph.Apply(r, c1), ShouldBeNil)
+		h1 := r.HashAlgorithm().HashCommit(c1)
+
+		Convey("moves the current frontier out of the frontiers bucket of an old view", func() {
+			writeOldView(dir, "old", map[string][]string{"old": {h0, h1}})
+			v, err := filerepo.MakeView(dir, r)
+			So(err, ShouldBeNil)
+			current, err := v.CurrentFrontier()
+			So(err, ShouldBeNil)
+			So(current, ShouldEqual, "old")
+			So(listFrontiers(v), ShouldResemble, []string{"old"})
+			info, err := v.StatFrontier("old")
+			So(err, ShouldBeNil)
+			So(info.Heads, ShouldResemble, []string{h1})
+			So(observes(v, "old", h0), ShouldBeTrue)
+		})
+
+		Convey("restores the heads and excludes of a frontier from its log", func() {
+			// A frontier converted from the old format only observes what it did before, which may
+			// leave out some deps.
+			writeOldView(dir, "old", map[string][]string{"old": {h1}})
+			v, err := filerepo.MakeView(dir, r)
+			So(err, ShouldBeNil)
+			So(v.ChangeFrontiers("old"), ShouldBeNil)
+			So(observes(v, "old", h0), ShouldBeFalse)
+			So(v.AdvanceFrontier(h0), ShouldBeNil)
+			So(observes(v, "old", h0), ShouldBeTrue)
+
+			So(v.RestoreFrontier("old", 1), ShouldBeNil)
+			So(observes(v, "old", h0), ShouldBeFalse)
+			So(observes(v, "old", h1), ShouldBeTrue)
+			entries, err := v.FrontierLog("old")
+			So(err, ShouldBeNil)
+			So(entries, ShouldHaveLength, 3)
+			So(entries[0].Heads, ShouldResemble, []string{h1})
+			So(entries[0].Excludes, ShouldResemble, []string{h0})
+			So(entries[1].Excludes, ShouldBeEmpty)
+			So(entries[2].Op, ShouldEqual, "restore")
+			So(entries[2].Heads, ShouldResemble, entries[0].Heads)
+			So(entries[2].Excludes, ShouldResemble, entries[0].Excludes)
+		})
+
+		Convey("made from scratch", func() {
+			v, err := filerepo.MakeView(dir, r)
+			So(err, ShouldBeNil)
+			So(v.AdvanceFrontier(h0), ShouldBeNil)
+
+			Convey("starts out on main", func() {
+				current, err := v.CurrentFrontier()
+				So(err, ShouldBeNil)
+				So(current, ShouldEqual, "main")
+				So(listFrontiers(v), ShouldResemble, []string{"main"})
+			})
+
+			Convey("keeps the current frontier current when it is renamed", func() {
+				So(v.RenameFrontier("main", "trunk"), ShouldBeNil)
+				current, err := v.CurrentFrontier()
+				So(err, ShouldBeNil)
+				So(current, ShouldEqual, "trunk")
+				So(listFrontiers(v), ShouldResemble, []string{"trunk"})
+				_, err = v.StatFrontier("main")
+				So(errors.Is(err, graph.ErrUnknownFrontier), ShouldBeTrue)
+
+				So(v.AdvanceFrontier(h1), ShouldBeNil)
+				So(observes(v, "trunk", h1), ShouldBeTrue)
+
+				Convey("but not when another frontier is", func() {
+					So(v.CreateFrontier("other"), ShouldBeNil)
+					So(v.RenameFrontier("other", "another"), ShouldBeNil)
+					current, err := v.CurrentFrontier()
+					So(err, ShouldBeNil)
+					So(current, ShouldEqual, "trunk")
+				})
+			})
+
+			Convey("logs every change to a frontier", func() {
+				So(v.CreateFrontier("other"), ShouldBeNil)
+				So(v.ChangeFrontiers("other"), ShouldBeNil)
+				So(v.AdvanceFrontier(h1), ShouldBeNil)
+				So(v.ChangeFrontiers("main"), ShouldBeNil)
+				_, err := v.MergeFrontier("other")
+				So(err, ShouldBeNil)
+				So(v.RenameFrontier("main", "trunk"), ShouldBeNil)
+				So(logOps(v, "other"), ShouldResemble, []string{"create", "switch", "advance"})
+				So(logOps(v, "trunk"), ShouldResemble, []string{"create", "advance", "switch", "merge", "rename"})
+
+				entries, err := v.FrontierLog("trunk")
+				So(err, ShouldBeNil)
+				So(entries[1].Commit, ShouldEqual, h0)
+				So(entries[1].Heads, ShouldResemble, []string{h0})
+				So(entries[3].Heads, ShouldResemble, []string{h1})
+
+				Convey("and can go back to any of them", func() {
+					So(v.RestoreFrontier("trunk", 2), ShouldBeNil)
+					So(observes(v, "trunk", h0), ShouldBeTrue)
+					So(observes(v, "trunk", h1), ShouldBeFalse)
+					So(logOps(v, "trunk"), ShouldResemble, []string{"create", "advance", "switch", "merge", "rename", "restore"})
+					So(v.RestoreFrontier("trunk", 100), ShouldNotBeNil)
+				})
+			})
+
+			Convey("won't change a tag", func() {
+				So(v.CreateTag("v1", "main"), ShouldBeNil)
+				info, err := v.StatFrontier("v1")
+				So(err, ShouldBeNil)
+				So(info.Tag, ShouldBeTrue)
+				So(v.CreateFrontier("other"), ShouldBeNil)
+				So(v.ChangeFrontiers("other"), ShouldBeNil)
+				So(v.AdvanceFrontier(h1), ShouldBeNil)
+				So(v.PushStash(h1), ShouldBeNil)
+				So(v.ChangeFrontiers("v1"), ShouldBeNil)
+
+				shouldBeTag := func(err error) {
+					So(errors.Is(err, graph.ErrTag), ShouldBeTrue)
+				}
+				shouldBeTag(v.AdvanceFrontier(h1))
+				shouldBeTag(v.RetreatFrontier(h0))
+				_, err = v.MergeFrontier("other")
+				shouldBeTag(err)
+				_, err = v.PopStash(1)
+				shouldBeTag(err)
+				shouldBeTag(v.RestoreFrontier("v1", 1))
+
+				So(observes(v, "v1", h0), ShouldBeTrue)
+				So(observes(v, "v1", h1), ShouldBeFalse)
+				stashes, err := v.ListStashes()
+				So(err, ShouldBeNil)
+				So(stashes, ShouldHaveLength, 1)
+
+				Convey("even after it is renamed", func() {
+					So(v.RenameFrontier("v1", "v2"), ShouldBeNil)
+					info, err := v.StatFrontier("v2")
+					So(err, ShouldBeNil)
+					So(info.Tag, ShouldBeTrue)
+					shouldBeTag(v.AdvanceFrontier(h1))
+					So(observes(v, "v2", h1), ShouldBeFalse)
+				})
+			})
+
+			Convey("won't delete the current frontier", func() {
+				err := v.DeleteFrontier("main")
+				So(errors.Is(err, graph.ErrCurrentFrontier), ShouldBeTrue)
+				So(listFrontiers(v), ShouldResemble, []string{"main"})
+				So(observes(v, "main", h0), ShouldBeTrue)
+
+				So(v.CreateFrontier("other"), ShouldBeNil)
+				So(v.ChangeFrontiers("other"), ShouldBeNil)
+				So(v.DeleteFrontier("main"), ShouldBeNil)
+				So(listFrontiers(v), ShouldResemble, []string{"other"})
+			})
+		})
+	})
+}
@@ -0,0 +1,162 @@
+package graph
+
+import (
+	"fmt"
+
+	jpb "github.com/runningwild/jig/proto"
+)
+
+// A CheckError describes a single violation of the repo's invariants.
+type CheckError struct {
+	// Kind is one of "node", "ref", "content", or "commit", and Key is the key of the broken object
+	// within that kind.
+	Kind string
+	Key  string
+	Msg  string
+}
+
+func (e *CheckError) Error() string {
+	return fmt.Sprintf("%s %q: %s", e.Kind, e.Key, e.Msg)
+}
+
+// Check verifies that every object in r is consistent with every other object and returns a
+// CheckError for each violation it finds.  It does not modify r.
+func Check(r Repo) []*CheckError {
+	var errs []*CheckError
+	alg := r.HashAlgorithm()
+	add := func(kind, key, format string, args ...interface{}) {
+		errs = append(errs, &CheckError{Kind: kind, Key: key, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	// departures maps each commit to the nodes it has edges out of, so that reads through them can be
+	// checked once everything else has been.
+	departures := make(map[string][]string)
+	for _, head := range listAll(r.ListNodes) {
+		n := r.GetNode(head)
+		if n == nil {
+			add("node", head, "failed to read node")
+			continue
+		}
+		if n.Head != head {
+			add("node", head, "stored under the wrong key, head is %q", n.Head)
+		}
+		if ref := r.GetRef(n.Tail); ref != n.Head {
+			add("node", head, "tail %q refers to %q instead of this node", n.Tail, ref)
+		}
+
+		// Every edge must have a matching edge on the node at the other end.
+		for _, e := range n.In {
+			peer := r.GetNode(r.GetRef(e.Node))
+			if peer == nil {
+				add("node", head, "in edge from commit %s refers to missing node %q", e.Commit, e.Node)
+				continue
+			}
+			if !hasEdge(peer.Out, e.Commit, func(node string) bool { return node == n.Head }) {
+				add("node", head, "in edge from commit %s has no matching out edge on %q", e.Commit, peer.Head)
+			}
+		}
+		for _, e := range n.Out {
+			peer := r.GetNode(e.Node)
+			if peer == nil {
+				add("node", head, "out edge from commit %s refers to missing node %q", e.Commit, e.Node)
+				continue
+			}
+			if !hasEdge(peer.In, e.Commit, func(node string) bool { return r.GetRef(node) == n.Head }) {
+				add("node", head, "out edge from commit %s has no matching in edge on %q", e.Commit, peer.Head)
+			}
+			if e.Node != DeletedNode {
+				departures[e.Commit] = append(departures[e.Commit], n.Head)
+			}
+		}
+
+		if n.GetSrc() != nil || n.GetSnk() != nil {
+			continue
+		}
+		if err := checkJoins(n); err != nil {
+			add("node", head, "%v", err)
+		}
+		content := r.GetContent(n.GetContentHash())
+		if content == nil {
+			add("node", head, "content %q not found", n.GetContentHash())
+			continue
+		}
+		if len(content) != int(n.Count) {
+			add("node", head, "count is %d but content has length %d", n.Count, len(content))
+			continue
+		}
+		if len(n.In) == 0 || !n.In[0].Join {
+			add("node", head, "first in edge is not the join edge of the commit that created it")
+			continue
+		}
+		if h, t := alg.CalculateNodeHashes(n.In[0].Commit, n.In[0].Node, content); h != n.Head || t != n.Tail {
+			add("node", head, "hashes recompute to (%s, %s), expected (%s, %s)", h, t, n.Head, n.Tail)
+		}
+	}
+
+	for _, ref := range listAll(r.ListRefs) {
+		if val := r.GetRef(ref); r.GetNode(val) == nil {
+			add("ref", ref, "refers to missing node %q", val)
+		}
+	}
+
+	for _, contentHash := range listAll(r.ListContents) {
+		if h := alg.HashContent(r.GetContent(contentHash)); h != contentHash {
+			add("content", contentHash, "content hashes to %q", h)
+		}
+	}
+
+	for _, commitHash := range listAll(r.ListCommits) {
+		c := r.GetCommit(commitHash)
+		if c == nil {
+			add("commit", commitHash, "failed to read commit")
+			continue
+		}
+		if h := alg.HashCommit(c); h != commitHash {
+			add("commit", commitHash, "commit hashes to %q", h)
+		}
+		for _, dep := range c.Deps {
+			if r.GetCommit(dep) == nil {
+				add("commit", commitHash, "depends on missing commit %q", dep)
+			}
+		}
+		if _, err := checkReads(r, NewCommitSet(r, []string{commitHash}, nil), departures[commitHash]); err != nil {
+			add("commit", commitHash, "%v", err)
+		}
+	}
+
+	return errs
+}
+
+// hasEdge returns true iff edges contains an edge from commit to a node accepted by match.
+func hasEdge(edges []*jpb.Edge, commit string, match func(node string) bool) bool {
+	for _, e := range edges {
+		if e.Commit == commit && match(e.Node) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkJoins verifies that every join edge on n comes as part of an In/Out pair from the same commit.
+func checkJoins(n *jpb.Node) error {
+	internalCommits := make(map[string]bool)
+	for _, e := range n.In {
+		if e.Join {
+			internalCommits[e.Commit] = true
+		}
+	}
+	for _, e := range n.Out {
+		if e.Join {
+			if _, ok := internalCommits[e.Commit]; !ok {
+				return fmt.Errorf("join edge from commit %s was present in Out but not In", e.Commit)
+			}
+			delete(internalCommits, e.Commit)
+		}
+	}
+	for _, e := range n.In {
+		if internalCommits[e.Commit] {
+			return fmt.Errorf("join edge from commit %s was present in In but not Out", e.Commit)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package graph_test
+
+import (
+	"testing"
+
+	"github.com/runningwild/jig/graph"
+	jpb "github.com/runningwild/jig/proto"
+	"github.com/runningwild/jig/testutils"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCheck(t *testing.T) {
+	Convey("Check", t, func() {
+		r := testutils.MakeFakeRepo()
+		c0 := &jpb.Commit{
+			EdgeRefs: []*jpb.EdgeRef{
+				{
+					Src:    &jpb.NodeRef{Node: "src:foo.txt", Depth: 1},
+					Chunks: stringsToContent("alpha", "bravo", "charlie", "delta", "echo"),
+					Dst:    &jpb.NodeRef{Node: "snk:foo.txt"},
+				},
+			},
+		}
+		So(graph.Apply(r, c0), ShouldBeNil)
+		head := firstRangeNode(r, c0)
+		c1 := &jpb.Commit{
+			Deps: []string{r.HashAlgorithm().HashCommit(c0)},
+			EdgeRefs: []*jpb.EdgeRef{
+				{
+					Src:    &jpb.NodeRef{Node: head, Depth: 1},
+					Chunks: stringsToContent("BRAVO"),
+					Dst:    &jpb.NodeRef{Node: head, Depth: 2},
+				},
+				{
+					Src: &jpb.NodeRef{Node: head, Depth: 3},
+					Dst: &jpb.NodeRef{Node: head, Depth: 4},
+				},
+			},
+		}
+		So(graph.Apply(r, c1), ShouldBeNil)
+
+		Convey("finds nothing wrong with a repo built by Apply", func() {
+			So(graph.Check(r), ShouldBeEmpty)
+		})
+
+		Convey("finds edges without a matching edge on the peer", func() {
+			n := r.GetNode(head)
+			n.Out = append(n.Out, &jpb.Edge{Commit: "bogus", Node: "snk:foo.txt"})
+			r.PutNode(n)
+			errs := graph.Check(r)
+			So(errs, ShouldHaveLength, 1)
+			So(errs[0].Key, ShouldEqual, head)
+		})
+
+		Convey("finds unpaired join edges", func() {
+			n := r.GetNode(head)
+			n.Out[0].Join = false
+			r.PutNode(n)
+			errs := graph.Check(r)
+			So(errs, ShouldHaveLength, 1)
+			So(errs[0].Key, ShouldEqual, head)
+		})
+
+		Convey("finds nodes whose count doesn't match their content", func() {
+			n := r.GetNode(head)
+			n.Count++
+			r.PutNode(n)
+			errs := graph.Check(r)
+			So(errs, ShouldHaveLength, 1)
+			So(errs[0].Key, ShouldEqual, head)
+		})
+
+		Convey("finds nodes whose hashes don't match their content", func() {
+			n := r.GetNode(head)
+			n.Content = &jpb.Node_ContentHash{ContentHash: r.PutContent(stringsToContent("ALPHA"))}
+			r.PutNode(n)
+			errs := graph.Check(r)
+			So(errs, ShouldHaveLength, 1)
+			So(errs[0].Key, ShouldEqual, head)
+		})
+
+		Convey("finds commits that make the file read in a cycle", func() {
+			// Point c1's edge out of charlie back at bravo instead of at echo, which Apply wouldn't allow.
+			h1 := r.HashAlgorithm().HashCommit(c1)
+			bravo := r.GetNode(r.GetNode(head).Out[0].Node)
+			charlie := r.GetNode(bravo.Out[0].Node)
+			echo := r.GetNode(charlie.Out[1].Node)
+			So(charlie.Out[1].Commit, ShouldEqual, h1)
+			charlie.Out[1].Node = bravo.Head
+			So(echo.In[1].Commit, ShouldEqual, h1)
+			bravo.In = append(bravo.In, echo.In[1])
+			echo.In = echo.In[0:1]
+			r.PutNode(bravo)
+			r.PutNode(charlie)
+			r.PutNode(echo)
+			errs := graph.Check(r)
+			So(errs, ShouldHaveLength, 1)
+			So(errs[0].Kind, ShouldEqual, "commit")
+			So(errs[0].Key, ShouldEqual, h1)
+		})
+
+		Convey("finds dangling refs", func() {
+			r.PutRef("dangling", "missing")
+			errs := graph.Check(r)
+			So(errs, ShouldHaveLength, 1)
+			So(errs[0].Kind, ShouldEqual, "ref")
+			So(errs[0].Key, ShouldEqual, "dangling")
+		})
+
+		Convey("finds commits with missing deps", func() {
+			c := &jpb.Commit{Deps: []string{"missing"}}
+			r.PutCommit(c)
+			errs := graph.Check(r)
+			So(errs, ShouldHaveLength, 1)
+			So(errs[0].Kind, ShouldEqual, "commit")
+			So(errs[0].Key, ShouldEqual, r.HashAlgorithm().HashCommit(c))
+		})
+	})
+}
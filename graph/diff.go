@@ -0,0 +1,264 @@
+package graph
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	jpb "github.com/runningwild/jig/proto"
+)
+
+// diffFile returns the edges that change the file at path, as of f, so that it has content, along with
+// the commits those edges depend on.  Lines that content shares with the file are left alone, so the
+// edges only conflict with concurrent changes to the lines that actually changed.  If the file has
+// conflicts then content resolves them, it is usually what HumanReadable wrote after the user has
+// edited it, and it must not have any conflict markers left in it.  Every conflict is replaced by
+// whatever content has in its place, and the edges depend on every commit in every conflict so that
+// the conflicts stay resolved.
+func diffFile(r Repo, f Frontier, path string, content [][]byte) ([]*jpb.EdgeRef, []string, error) {
+	v, err := ReadFile(r, f, path, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch v.State {
+	case FileAbsent, FileDeleted:
+		return nil, nil, ErrFileNotFound
+	case FileConflicted:
+		for i, line := range content {
+			if isConflictMarker(line) {
+				return nil, nil, fmt.Errorf("line %d: %w", i+1, ErrConflictMarkers)
+			}
+		}
+	}
+	old, err := readAroundConflicts(r, f, path, v.Conflicts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// srcRef is the place right after the first n lines of the file, and dstRef is the place right
+	// before line n.
+	srcRef := func(n int) *jpb.NodeRef {
+		if n == 0 {
+			return &jpb.NodeRef{Node: "src:" + path, Depth: 1}
+		}
+		return &jpb.NodeRef{Node: old.refs[n-1].Node, Depth: old.refs[n-1].Depth + 1}
+	}
+	dstRef := func(n int) *jpb.NodeRef {
+		if n == len(old.lines) {
+			return &jpb.NodeRef{Node: "snk:" + path}
+		}
+		return &jpb.NodeRef{Node: old.refs[n].Node, Depth: old.refs[n].Depth}
+	}
+
+	// No run of common lines may span a conflict, since the conflict has to be replaced.
+	var runs []lineRun
+	for _, cs := range commonLines(old.lines, content) {
+		for i := cs.a + 1; i < cs.a+cs.n; i++ {
+			if len(old.conflicts[i]) > 0 {
+				runs = append(runs, lineRun{a: cs.a, b: cs.b, n: i - cs.a})
+				cs = lineRun{a: i, b: cs.b + i - cs.a, n: cs.a + cs.n - i}
+			}
+		}
+		runs = append(runs, cs)
+	}
+
+	// Every gap between the lines the two versions have in common becomes an edge that skips the old
+	// lines and conflicts in the gap and goes through the new lines.
+	var edges []*jpb.EdgeRef
+	var prevOld, prevNew int
+	for _, cs := range runs {
+		conflicted := false
+		for i := prevOld; i <= cs.a; i++ {
+			conflicted = conflicted || len(old.conflicts[i]) > 0
+		}
+		if cs.a > prevOld || cs.b > prevNew || conflicted {
+			edges = append(edges, &jpb.EdgeRef{
+				Src:    srcRef(prevOld),
+				Chunks: content[prevNew:cs.b],
+				Dst:    dstRef(cs.a),
+			})
+		}
+		prevOld, prevNew = cs.a+cs.n, cs.b+cs.n
+	}
+	if len(edges) == 0 {
+		return nil, nil, nil
+	}
+
+	// The edges are only correct for the version of the file they were made from, so they depend on
+	// every commit that contributed to it.
+	var deps []string
+	for commit := range old.commits {
+		deps = append(deps, commit)
+	}
+	sort.Strings(deps)
+	return edges, deps, nil
+}
+
+// conflictMarkers are the prefixes of the lines that HumanReadable puts around and between the
+// versions in a conflict.
+var conflictMarkers = []string{"<<<<<<<", "|||||||", "=======", ">>>>>>>"}
+
+func isConflictMarker(line []byte) bool {
+	for _, marker := range conflictMarkers {
+		if bytes.HasPrefix(line, []byte(marker)) {
+			return true
+		}
+	}
+	return false
+}
+
+// linesAroundConflicts is a file with each of its conflicts left out.
+type linesAroundConflicts struct {
+	lines [][]byte
+
+	// refs[i] is where lines[i] is, as the number of lines that come before it in the node that was
+	// created along with it.  These can be used as a NodeRef's Dst as they are, and as a Src by adding
+	// one.
+	refs []jpb.NodeRef
+
+	// conflicts[i] are the conflicts that come right before lines[i], or at the end of the file if i
+	// is len(lines).
+	conflicts map[int][]Conflict
+
+	// commits are the commits that the lines, and the conflicts, came from.
+	commits map[string]bool
+}
+
+// readAroundConflicts reads the file at path as of f the same way HumanReadable does, except that
+// the lines on either side of each conflict are kept where they are in the file rather than repeated
+// in every version of the conflict.
+func readAroundConflicts(r Repo, f Frontier, path string, conflicts []Conflict) (*linesAroundConflicts, error) {
+	starts := make(map[string]int)
+	for i, c := range conflicts {
+		starts[c.Start] = i
+	}
+	l := &linesAroundConflicts{conflicts: make(map[int][]Conflict), commits: make(map[string]bool)}
+	n := r.GetNode("src:" + path)
+	used := make(map[string]bool)
+	var renames []string
+	for n.Head != "snk:"+path {
+		if used[n.Head] {
+			return nil, fmt.Errorf("file graph contains a cycle %v", n.Head)
+		}
+		used[n.Head] = true
+		if n.GetSrc() == nil && n.GetSnk() == nil {
+			node, depth := nodeRef(r, n)
+			for i, line := range r.GetContent(n.GetContentHash()) {
+				l.lines = append(l.lines, line)
+				l.refs = append(l.refs, jpb.NodeRef{Node: node, Depth: int32(depth + i)})
+			}
+		}
+
+		var next string
+		if i, ok := starts[n.Tail]; ok {
+			c := conflicts[i]
+			l.conflicts[len(l.lines)] = append(l.conflicts[len(l.lines)], c)
+			for commit := range c.Commits {
+				l.commits[commit] = true
+			}
+			next = c.End
+		} else {
+			e, err := nextEdge(r, f, n, renames)
+			if err != nil {
+				return nil, err
+			}
+			if e == nil {
+				return nil, fmt.Errorf("failed pathing through %q", path)
+			}
+			l.commits[e.Commit] = true
+			renames = followRenames(n, e, renames)
+			next = e.Node
+		}
+		if n = r.GetNode(next); n == nil {
+			return nil, fmt.Errorf("%q: %w", next, ErrMissingNode)
+		}
+	}
+	return l, nil
+}
+
+// A lineRun is n lines that start at line a in one version of a file and line b in another.
+type lineRun struct {
+	a, b, n int
+}
+
+// commonLines returns the runs of lines that make up a longest common subsequence of a and b, in
+// order.  The last run is always an empty one at the end of both.  It uses Myers' diff algorithm, so
+// it is fast when a and b are similar.
+func commonLines(a, b [][]byte) []lineRun {
+	ids := make(map[string]int)
+	toIDs := func(lines [][]byte) []int {
+		var v []int
+		for _, line := range lines {
+			id, ok := ids[string(line)]
+			if !ok {
+				id = len(ids)
+				ids[string(line)] = id
+			}
+			v = append(v, id)
+		}
+		return v
+	}
+	x, y := toIDs(a), toIDs(b)
+	n, m := len(x), len(y)
+
+	// v[offset+k] is the furthest point reached along diagonal k, and trace[d] holds diagonals -d
+	// through d of v as it was before step d, which are the only ones that step reads, so that the
+	// path can be recovered afterwards.
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var i int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				i = v[offset+k+1]
+			} else {
+				i = v[offset+k-1] + 1
+			}
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i, j = i+1, j+1
+			}
+			v[offset+k] = i
+			if i >= n && j >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk back from the end, collecting the diagonals.
+	runs := []lineRun{{a: n, b: m}}
+	i, j := n, m
+	for d := len(trace) - 1; d >= 0 && (i > 0 || j > 0); d-- {
+		k := i - j
+		if d == 0 {
+			// The first snake starts at the beginning of both.
+			runs = append(runs, lineRun{a: 0, b: 0, n: i})
+			break
+		}
+		v := trace[d]
+		var prevK int
+		if k == -d || (k != d && v[d+k-1] < v[d+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevI := v[d+prevK]
+		prevJ := prevI - prevK
+		// The snake along diagonal k starts right after the step onto it.
+		start := prevI
+		if prevK < k {
+			start++
+		}
+		if start < i {
+			runs = append(runs, lineRun{a: start, b: start - k, n: i - start})
+		}
+		i, j = prevI, prevJ
+	}
+	for l, r := 0, len(runs)-1; l < r; l, r = l+1, r-1 {
+		runs[l], runs[r] = runs[r], runs[l]
+	}
+	return runs
+}
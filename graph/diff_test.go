@@ -0,0 +1,151 @@
+package graph_test
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/runningwild/jig/graph"
+	"github.com/runningwild/jig/testutils"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestEdit(t *testing.T) {
+	Convey("Editing a file", t, func() {
+		r := testutils.MakeFakeRepo()
+		c0, err := graph.ChangeFiles(r, allFrontier{}, nil, []graph.FileChange{
+			{Path: "a.txt", Content: stringsToContent("alpha", "bravo", "charlie", "delta")},
+			{Path: "empty.txt"},
+		})
+		So(err, ShouldBeNil)
+		So(graph.Apply(r, c0), ShouldBeNil)
+		h0 := r.HashAlgorithm().HashCommit(c0)
+		f0 := explicitFrontierStrings(h0)
+
+		shouldReadFile := func(f graph.Frontier, path string, state graph.FileState, want string) {
+			v, err := graph.ReadFile(r, f, path, nil)
+			So(err, ShouldBeNil)
+			So(v.ConflictsErr, ShouldBeNil)
+			So(v.State, ShouldEqual, state)
+			So(contentToString(v.Lines), ShouldEqual, want)
+		}
+
+		Convey("depends on the commits the file came from", func() {
+			h1 := editFile(r, f0, nil, "a.txt", "alpha", "BRAVO", "charlie", "delta", "echo")
+			So(r.GetCommit(h1).Deps, ShouldResemble, []string{h0})
+			shouldReadFile(explicitFrontierStrings(h0, h1), "a.txt", graph.FilePresent, "alpha.BRAVO.charlie.delta.echo")
+
+			h2 := editFile(r, explicitFrontierStrings(h0, h1), nil, "a.txt", "first", "BRAVO", "delta", "echo")
+			deps := []string{h0, h1}
+			sort.Strings(deps)
+			So(r.GetCommit(h2).Deps, ShouldResemble, deps)
+			shouldReadFile(explicitFrontierStrings(h0, h1, h2), "a.txt", graph.FilePresent, "first.BRAVO.delta.echo")
+		})
+
+		Convey("only touches the lines that changed", func() {
+			h1 := editFile(r, f0, nil, "a.txt", "first", "alpha", "BRAVO", "charlie", "delta")
+			h2 := editFile(r, f0, nil, "a.txt", "alpha", "bravo", "charlie", "DELTA", "last")
+			shouldReadFile(explicitFrontierStrings(h0, h1, h2), "a.txt", graph.FilePresent, "first.alpha.BRAVO.charlie.DELTA.last")
+
+			h3 := editFile(r, f0, nil, "a.txt", "alpha", "bravo", "CHARLIE", "delta")
+			h4 := editFile(r, f0, nil, "a.txt", "alpha", "bravo", "charlie?", "delta")
+			v, err := graph.ReadFile(r, explicitFrontierStrings(h0, h3, h4), "a.txt", nil)
+			So(err, ShouldBeNil)
+			So(v.State, ShouldEqual, graph.FileConflicted)
+		})
+
+		Convey("conflicts with concurrent edits to the whole file", func() {
+			h1 := editFile(r, f0, nil, "a.txt", "one")
+			h2 := editFile(r, f0, nil, "a.txt", "two")
+			f := explicitFrontierStrings(h0, h1, h2)
+			v, err := graph.ReadFile(r, f, "a.txt", nil)
+			So(err, ShouldBeNil)
+			So(v.State, ShouldEqual, graph.FileConflicted)
+			data, err := graph.HumanReadable(r, f, "a.txt", v.Conflicts, []byte("."), false)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldContainSubstring, "one")
+			So(string(data), ShouldContainSubstring, "two")
+		})
+
+		Convey("resolves conflicts", func() {
+			h1 := editFile(r, f0, nil, "a.txt", "alpha", "bravo", "CHARLIE", "delta")
+			h2 := editFile(r, f0, nil, "a.txt", "alpha", "bravo", "charlie?", "delta")
+			f := explicitFrontierStrings(h0, h1, h2)
+
+			_, err := graph.ChangeFiles(r, f, nil, []graph.FileChange{
+				{Path: "a.txt", Edit: true, Content: stringsToContent("alpha", "<<<<<<<", "CHARLIE", ">>>>>>>", "delta")},
+			})
+			So(errors.Is(err, graph.ErrConflictMarkers), ShouldBeTrue)
+
+			c, err := graph.ChangeFiles(r, f, nil, []graph.FileChange{
+				{Path: "a.txt", Edit: true, Content: stringsToContent("alpha", "bravo", "CHARLIE?", "delta")},
+			})
+			So(err, ShouldBeNil)
+			So(c.Deps, ShouldContain, h1)
+			So(c.Deps, ShouldContain, h2)
+			So(c.EdgeRefs, ShouldHaveLength, 1)
+			So(contentToString(c.EdgeRefs[0].Chunks), ShouldEqual, "CHARLIE?")
+			So(graph.Apply(r, c), ShouldBeNil)
+			shouldReadFile(explicitFrontierStrings(h0, h1, h2, r.HashAlgorithm().HashCommit(c)), "a.txt", graph.FilePresent, "alpha.bravo.CHARLIE?.delta")
+		})
+
+		Convey("resolves conflicts at the ends of the file", func() {
+			h1 := editFile(r, f0, nil, "a.txt", "one")
+			h2 := editFile(r, f0, nil, "a.txt", "two")
+			h3 := editFile(r, explicitFrontierStrings(h0, h1, h2), nil, "a.txt", "one", "two")
+			shouldReadFile(explicitFrontierStrings(h0, h1, h2, h3), "a.txt", graph.FilePresent, "one.two")
+		})
+
+		Convey("can remove every line and add them back", func() {
+			h1 := editFile(r, f0, nil, "a.txt")
+			shouldReadFile(explicitFrontierStrings(h0, h1), "a.txt", graph.FileEmpty, "")
+			h2 := editFile(r, f0, nil, "empty.txt", "alpha")
+			shouldReadFile(explicitFrontierStrings(h0, h2), "empty.txt", graph.FilePresent, "alpha")
+		})
+
+		Convey("can be edited again and again", func() {
+			hashes := []string{h0}
+			for _, lines := range [][]string{
+				{"first", "alpha", "bravo", "charlie", "delta"},
+				{"first", "alpha", "charlie", "delta", "last"},
+				{"delta", "charlie", "alpha", "first", "last"},
+				{"delta", "delta", "charlie", "new", "alpha", "first", "last", "last"},
+				{"alpha"},
+				{"one", "alpha", "two", "alpha", "three"},
+			} {
+				f := explicitFrontierStrings(hashes...)
+				hashes = append(hashes, editFile(r, f, nil, "a.txt", lines...))
+				shouldReadFile(explicitFrontierStrings(hashes...), "a.txt", graph.FilePresent, contentToString(stringsToContent(lines...)))
+			}
+		})
+
+		Convey("can read every version of a file whose lines repeat", func() {
+			c, err := graph.ChangeFiles(r, allFrontier{}, nil, []graph.FileChange{{Path: "b.txt", Content: stringsToContent("b")}})
+			So(err, ShouldBeNil)
+			So(graph.Apply(r, c), ShouldBeNil)
+			shouldReadFile(allFrontier{}, "b.txt", graph.FilePresent, "b")
+			editFile(r, allFrontier{}, nil, "b.txt", "b", "d", "c")
+			shouldReadFile(allFrontier{}, "b.txt", graph.FilePresent, "b.d.c")
+			editFile(r, allFrontier{}, nil, "b.txt", "d", "c", "b", "b", "b")
+			shouldReadFile(allFrontier{}, "b.txt", graph.FilePresent, "d.c.b.b.b")
+			editFile(r, allFrontier{}, nil, "b.txt", "c", "c")
+			shouldReadFile(allFrontier{}, "b.txt", graph.FilePresent, "c.c")
+		})
+
+		Convey("does nothing if the content is the same", func() {
+			c, err := graph.ChangeFiles(r, f0, nil, []graph.FileChange{
+				{Path: "a.txt", Edit: true, Content: stringsToContent("alpha", "bravo", "charlie", "delta")},
+			})
+			So(err, ShouldBeNil)
+			So(c.EdgeRefs, ShouldBeEmpty)
+		})
+
+		Convey("requires the file to exist", func() {
+			_, err := graph.ChangeFiles(r, f0, nil, []graph.FileChange{
+				{Path: "b.txt", Edit: true, Content: stringsToContent("alpha")},
+			})
+			So(errors.Is(err, graph.ErrFileNotFound), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,251 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A CommitSet is a Frontier that observes an arbitrary set of commits.  Rather than storing every
+// commit it observes, it stores its heads, which are the commits in it that no other commit in it
+// depends on, and the commits that its heads depend on that it excludes.  Every commit in the set
+// is a head or is depended on by one, so the set is the closure of its heads through Commit.Deps
+// minus its exclusions.  Frontiers usually observe everything their heads depend on, in which case
+// there are no exclusions and the set is stored as a handful of heads.
+//
+// A CommitSet is immutable, operations on it return a new CommitSet.
+type CommitSet struct {
+	r        Repo
+	heads    []string
+	excludes []string
+
+	// commits is every commit in the set, it is computed lazily.
+	commits map[string]bool
+}
+
+// NewCommitSet returns the set of commits made up of heads and everything they depend on, except
+// for excludes.  Unlike CommitSetOf it doesn't check that heads and excludes are minimal, so it is
+// meant for loading a CommitSet that was previously stored using its Heads and Excludes.
+func NewCommitSet(r Repo, heads, excludes []string) *CommitSet {
+	return &CommitSet{
+		r:        r,
+		heads:    sortedCopy(heads),
+		excludes: sortedCopy(excludes),
+	}
+}
+
+// CommitSetOf returns the CommitSet that contains exactly commits.
+func CommitSetOf(r Repo, commits []string) *CommitSet {
+	members := make(map[string]bool)
+	for _, commit := range commits {
+		members[commit] = true
+	}
+	return commitSetOf(r, members)
+}
+
+func commitSetOf(r Repo, members map[string]bool) *CommitSet {
+	// Anything that a member depends on can't be a head.
+	var deps []string
+	for commit := range members {
+		deps = append(deps, r.GetCommit(commit).GetDeps()...)
+	}
+	ancestors := depClosure(r, deps)
+
+	s := &CommitSet{r: r, commits: members}
+	for commit := range members {
+		if !ancestors[commit] {
+			s.heads = append(s.heads, commit)
+		}
+	}
+	for commit := range ancestors {
+		if !members[commit] {
+			s.excludes = append(s.excludes, commit)
+		}
+	}
+	sort.Strings(s.heads)
+	sort.Strings(s.excludes)
+	return s
+}
+
+// Heads returns the commits in s that no other commit in s depends on, in sorted order.
+func (s *CommitSet) Heads() []string {
+	return sortedCopy(s.heads)
+}
+
+// Excludes returns the commits that the heads of s depend on that aren't in s, in sorted order.
+func (s *CommitSet) Excludes() []string {
+	return sortedCopy(s.excludes)
+}
+
+// Commits returns every commit in s in sorted order.
+func (s *CommitSet) Commits() []string {
+	var commits []string
+	for commit := range s.members() {
+		commits = append(commits, commit)
+	}
+	sort.Strings(commits)
+	return commits
+}
+
+func (s *CommitSet) Observes(commit string) (bool, error) {
+	return s.members()[commit], nil
+}
+
+func (s *CommitSet) members() map[string]bool {
+	if s.commits == nil {
+		s.commits = depClosure(s.r, s.heads)
+		for _, commit := range s.excludes {
+			delete(s.commits, commit)
+		}
+	}
+	return s.commits
+}
+
+// Add returns the set of commits in s along with commits.
+func (s *CommitSet) Add(commits ...string) *CommitSet {
+	return s.Union(CommitSetOf(s.r, commits))
+}
+
+// Advance returns the set of commits in s along with commit and everything it depends on, so that
+// every commit in s that was readable before is still readable, and so is commit.  It returns an
+// error wrapping ErrUnknownCommit if commit or anything it depends on isn't in the repo.
+func (s *CommitSet) Advance(commit string) (*CommitSet, error) {
+	closure := depClosure(s.r, []string{commit})
+	for dep := range closure {
+		if s.r.GetCommit(dep) == nil {
+			return nil, fmt.Errorf("commit %q: %w", dep, ErrUnknownCommit)
+		}
+	}
+	return s.Union(commitSetOf(s.r, closure)), nil
+}
+
+// Retreat returns the set of commits in s without commit or anything in s that depends on it, as
+// found through the repo's reverse deps.
+func (s *CommitSet) Retreat(commit string) *CommitSet {
+	// Commits that s excludes may still have dependents in s, so they are followed too.
+	members := s.members()
+	seen := make(map[string]bool)
+	remove := make(map[string]bool)
+	stack := []string{commit}
+	for len(stack) > 0 {
+		commit := stack[len(stack)-1]
+		stack = stack[0 : len(stack)-1]
+		if seen[commit] {
+			continue
+		}
+		seen[commit] = true
+		if members[commit] {
+			remove[commit] = true
+		}
+		stack = append(stack, s.r.GetReverseDeps(commit)...)
+	}
+	return s.Difference(commitSetOf(s.r, remove))
+}
+
+// Union returns the set of commits that are in either s or t.
+func (s *CommitSet) Union(t *CommitSet) *CommitSet {
+	members := make(map[string]bool)
+	for commit := range s.members() {
+		members[commit] = true
+	}
+	for commit := range t.members() {
+		members[commit] = true
+	}
+	return commitSetOf(s.r, members)
+}
+
+// Intersection returns the set of commits that are in both s and t.
+func (s *CommitSet) Intersection(t *CommitSet) *CommitSet {
+	members := make(map[string]bool)
+	tm := t.members()
+	for commit := range s.members() {
+		if tm[commit] {
+			members[commit] = true
+		}
+	}
+	return commitSetOf(s.r, members)
+}
+
+// Difference returns the set of commits that are in s but not in t.
+func (s *CommitSet) Difference(t *CommitSet) *CommitSet {
+	members := make(map[string]bool)
+	tm := t.members()
+	for commit := range s.members() {
+		if !tm[commit] {
+			members[commit] = true
+		}
+	}
+	return commitSetOf(s.r, members)
+}
+
+// AncestorClosedWithin returns true iff s is a subset of t and every commit in t that a commit in s
+// depends on is also in s.  A frontier that is ancestor-closed within another can be advanced to it
+// by applying only the commits in their difference.
+func (s *CommitSet) AncestorClosedWithin(t *CommitSet) bool {
+	sm, tm := s.members(), t.members()
+	var deps []string
+	for commit := range sm {
+		if !tm[commit] {
+			return false
+		}
+		deps = append(deps, s.r.GetCommit(commit).GetDeps()...)
+	}
+	for commit := range depClosure(s.r, deps) {
+		if tm[commit] && !sm[commit] {
+			return false
+		}
+	}
+	return true
+}
+
+// FrontierDelta returns the commits that a observes and b doesn't, sorted so that every commit comes
+// after all of its deps.
+func FrontierDelta(r Repo, a, b Frontier) ([]string, error) {
+	var candidates []string
+	if s, ok := a.(*CommitSet); ok {
+		candidates = s.Commits()
+	} else {
+		candidates = listAll(r.ListCommits)
+	}
+	var delta []string
+	for _, commit := range candidates {
+		obs, err := a.Observes(commit)
+		if err != nil {
+			return nil, err
+		}
+		if !obs {
+			continue
+		}
+		if obs, err = b.Observes(commit); err != nil {
+			return nil, err
+		}
+		if !obs {
+			delta = append(delta, commit)
+		}
+	}
+	return sortCommits(r, delta)
+}
+
+// depClosure returns the set of commits made up of commits and everything they depend on.
+func depClosure(r Repo, commits []string) map[string]bool {
+	closure := make(map[string]bool)
+	stack := append([]string(nil), commits...)
+	for len(stack) > 0 {
+		commit := stack[len(stack)-1]
+		stack = stack[0 : len(stack)-1]
+		if closure[commit] {
+			continue
+		}
+		closure[commit] = true
+		stack = append(stack, r.GetCommit(commit).GetDeps()...)
+	}
+	return closure
+}
+
+func sortedCopy(strs []string) []string {
+	if len(strs) == 0 {
+		return nil
+	}
+	c := append([]string(nil), strs...)
+	sort.Strings(c)
+	return c
+}
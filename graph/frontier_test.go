@@ -0,0 +1,156 @@
+package graph_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/runningwild/jig/graph"
+	jpb "github.com/runningwild/jig/proto"
+	"github.com/runningwild/jig/testutils"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCommitSet(t *testing.T) {
+	Convey("CommitSet", t, func() {
+		// a <- b <- c <- e
+		//   <- d <------/
+		r := &commitOnlyRepo{commits: map[string][]string{
+			"a": {},
+			"b": {"a"},
+			"c": {"b"},
+			"d": {"a"},
+			"e": {"c", "d"},
+		}}
+		set := func(commits ...string) *graph.CommitSet {
+			return graph.CommitSetOf(r, commits)
+		}
+
+		Convey("stores closed sets as their heads", func() {
+			s := set("a", "b", "c", "d")
+			So(s.Heads(), ShouldResemble, []string{"c", "d"})
+			So(s.Excludes(), ShouldBeEmpty)
+			So(s.Commits(), ShouldResemble, []string{"a", "b", "c", "d"})
+			So(set("a", "b", "c", "d", "e").Heads(), ShouldResemble, []string{"e"})
+		})
+
+		Convey("stores anything else with exclusions", func() {
+			s := set("a", "c", "e")
+			So(s.Heads(), ShouldResemble, []string{"e"})
+			So(s.Excludes(), ShouldResemble, []string{"b", "d"})
+			for _, commit := range []string{"a", "c", "e"} {
+				obs, err := s.Observes(commit)
+				So(err, ShouldBeNil)
+				So(obs, ShouldBeTrue)
+			}
+			for _, commit := range []string{"b", "d", "f"} {
+				obs, err := s.Observes(commit)
+				So(err, ShouldBeNil)
+				So(obs, ShouldBeFalse)
+			}
+		})
+
+		Convey("round trips through its heads and exclusions", func() {
+			s := set("a", "c", "d")
+			loaded := graph.NewCommitSet(r, s.Heads(), s.Excludes())
+			So(loaded.Commits(), ShouldResemble, []string{"a", "c", "d"})
+		})
+
+		Convey("supports set operations", func() {
+			s := set("a", "b", "c")
+			t := set("a", "d")
+			So(s.Union(t).Commits(), ShouldResemble, []string{"a", "b", "c", "d"})
+			So(s.Union(t).Heads(), ShouldResemble, []string{"c", "d"})
+			So(s.Intersection(t).Commits(), ShouldResemble, []string{"a"})
+			So(s.Difference(t).Commits(), ShouldResemble, []string{"b", "c"})
+			So(s.Difference(t).Excludes(), ShouldResemble, []string{"a"})
+			So(t.Difference(s).Commits(), ShouldResemble, []string{"d"})
+			So(s.Add("e").Commits(), ShouldResemble, []string{"a", "b", "c", "e"})
+			So(set().Commits(), ShouldBeEmpty)
+		})
+
+		Convey("knows when one set is ancestor-closed within another", func() {
+			all := set("a", "b", "c", "d", "e")
+			So(set("a", "b").AncestorClosedWithin(all), ShouldBeTrue)
+			So(set("a", "d").AncestorClosedWithin(all), ShouldBeTrue)
+			So(set().AncestorClosedWithin(all), ShouldBeTrue)
+			So(set("c").AncestorClosedWithin(all), ShouldBeFalse)
+			So(set("a", "c").AncestorClosedWithin(all), ShouldBeFalse)
+			So(set("c").AncestorClosedWithin(set("c", "d")), ShouldBeTrue)
+			So(all.AncestorClosedWithin(set("a", "b")), ShouldBeFalse)
+		})
+	})
+}
+
+// applyCommitDAG applies five empty commits to r, with the same deps as the commits in
+// TestCommitSet, and returns their hashes.
+func applyCommitDAG(r graph.Repo) (a, b, c, d, e string) {
+	commit := func(name string, deps ...string) string {
+		c := &jpb.Commit{Deps: deps, Metadata: &jpb.CommitMetadata{Message: name}}
+		So(graph.Apply(r, c), ShouldBeNil)
+		return r.HashAlgorithm().HashCommit(c)
+	}
+	a = commit("a")
+	b = commit("b", a)
+	c = commit("c", b)
+	d = commit("d", a)
+	e = commit("e", c, d)
+	return a, b, c, d, e
+}
+
+func TestAdvanceAndRetreat(t *testing.T) {
+	Convey("Advancing and retreating a CommitSet", t, func() {
+		r := testutils.MakeFakeRepo()
+		a, b, c, d, e := applyCommitDAG(r)
+		empty := graph.CommitSetOf(r, nil)
+
+		Convey("pulls in missing deps", func() {
+			s, err := empty.Advance(c)
+			So(err, ShouldBeNil)
+			So(s.Commits(), ShouldResemble, graph.CommitSetOf(r, []string{a, b, c}).Commits())
+			s, err = graph.CommitSetOf(r, []string{a, c}).Advance(e)
+			So(err, ShouldBeNil)
+			So(s.Excludes(), ShouldBeEmpty)
+			So(s.Heads(), ShouldResemble, []string{e})
+		})
+
+		Convey("rejects unknown commits", func() {
+			_, err := empty.Advance("unknown")
+			So(errors.Is(err, graph.ErrUnknownCommit), ShouldBeTrue)
+		})
+
+		Convey("removes everything that depends on the commit", func() {
+			all, err := empty.Advance(e)
+			So(err, ShouldBeNil)
+			So(all.Retreat(b).Commits(), ShouldResemble, graph.CommitSetOf(r, []string{a, d}).Commits())
+			So(all.Retreat(e).Heads(), ShouldResemble, graph.CommitSetOf(r, []string{c, d}).Heads())
+			So(all.Retreat(a).Commits(), ShouldBeEmpty)
+
+			// c depends on a through b, which isn't in the set.
+			So(graph.CommitSetOf(r, []string{a, c}).Retreat(a).Commits(), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestFrontierDelta(t *testing.T) {
+	Convey("FrontierDelta", t, func() {
+		r := testutils.MakeFakeRepo()
+		a, b, c, d, e := applyCommitDAG(r)
+
+		Convey("returns the commits in one frontier and not the other in dependency order", func() {
+			delta, err := graph.FrontierDelta(r, graph.NewCommitSet(r, []string{e}, nil), graph.NewCommitSet(r, []string{d}, nil))
+			So(err, ShouldBeNil)
+			So(delta, ShouldResemble, []string{b, c, e})
+
+			delta, err = graph.FrontierDelta(r, graph.NewCommitSet(r, []string{d}, nil), graph.NewCommitSet(r, []string{e}, nil))
+			So(err, ShouldBeNil)
+			So(delta, ShouldBeEmpty)
+		})
+
+		Convey("works with any Frontier", func() {
+			delta, err := graph.FrontierDelta(r, explicitFrontierStrings(a, b, c, d, e), explicitFrontierStrings(a, d))
+			So(err, ShouldBeNil)
+			So(delta, ShouldResemble, []string{b, c, e})
+		})
+	})
+}
@@ -0,0 +1,115 @@
+package graph
+
+import (
+	"strings"
+)
+
+// GCStats describes everything that was removed from a repo by GC.
+type GCStats struct {
+	Nodes       int
+	Contents    int
+	Refs        int
+	ReverseDeps int
+}
+
+// GC does a mark-and-sweep over r.  Every node reachable from a src or snk node, or referenced by a
+// stored commit, is marked, and anything that is not needed by a marked node or an existing commit
+// is deleted.  The sweep happens in a single transaction.
+func GC(r Repo) (stats GCStats, err error) {
+	nodes := listAll(r.ListNodes)
+
+	// Mark
+	live := make(map[string]bool)
+	var stack []string
+	for _, node := range nodes {
+		if strings.HasPrefix(node, "src:") || strings.HasPrefix(node, "snk:") {
+			stack = append(stack, node)
+		}
+	}
+	commits := listAll(r.ListCommits)
+	for _, commitHash := range commits {
+		c := r.GetCommit(commitHash)
+		if c == nil {
+			continue
+		}
+		for _, e := range c.EdgeRefs {
+			stack = append(stack, e.GetSrc().GetNode(), e.GetDst().GetNode())
+		}
+	}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[0 : len(stack)-1]
+		n := r.GetNode(node)
+		if n == nil {
+			// Edges into a node point at the tail of the previous node, so we may need to go through
+			// a ref to find it.
+			n = r.GetNode(r.GetRef(node))
+		}
+		if n == nil || live[n.Head] {
+			continue
+		}
+		live[n.Head] = true
+		for _, e := range n.In {
+			stack = append(stack, e.Node)
+		}
+		for _, e := range n.Out {
+			stack = append(stack, e.Node)
+		}
+	}
+
+	liveContent := make(map[string]bool)
+	for head := range live {
+		if contentHash := r.GetNode(head).GetContentHash(); contentHash != "" {
+			liveContent[contentHash] = true
+		}
+	}
+
+	// Sweep
+	r.StartTransaction()
+	defer endTransaction(r, &err)
+
+	for _, node := range nodes {
+		if !live[node] {
+			r.DeleteNode(node)
+			stats.Nodes++
+		}
+	}
+	for _, contentHash := range listAll(r.ListContents) {
+		if !liveContent[contentHash] {
+			r.DeleteContent(contentHash)
+			stats.Contents++
+		}
+	}
+	for _, ref := range listAll(r.ListRefs) {
+		if !live[r.GetRef(ref)] {
+			r.DeleteRef(ref)
+			stats.Refs++
+		}
+	}
+	for _, commitHash := range commits {
+		for _, rdep := range r.GetReverseDeps(commitHash) {
+			if r.GetCommit(rdep) == nil {
+				r.DeleteReverseDep(rdep, commitHash)
+				stats.ReverseDeps++
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// listAll repeatedly calls one of the List methods on a Repo until it has returned every key.
+func listAll(list func(start string, dst []string) int) []string {
+	var all []string
+	buf := make([]string, 100)
+	start := ""
+	for {
+		n := list(start, buf)
+		all = append(all, buf[0:n]...)
+		if n < len(buf) {
+			return all
+		}
+		// This is the smallest key that sorts after the last one we've seen.
+		start = buf[n-1] + "\x00"
+	}
+}
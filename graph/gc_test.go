@@ -0,0 +1,86 @@
+package graph_test
+
+import (
+	"testing"
+
+	"github.com/runningwild/jig/graph"
+	jpb "github.com/runningwild/jig/proto"
+	"github.com/runningwild/jig/testutils"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGC(t *testing.T) {
+	Convey("GC", t, func() {
+		r := testutils.MakeFakeRepo()
+		c0 := &jpb.Commit{
+			EdgeRefs: []*jpb.EdgeRef{
+				{
+					Src:    &jpb.NodeRef{Node: "src:foo.txt", Depth: 1},
+					Chunks: stringsToContent("alpha", "bravo", "charlie", "delta"),
+					Dst:    &jpb.NodeRef{Node: "snk:foo.txt"},
+				},
+			},
+		}
+		So(graph.Apply(r, c0), ShouldBeNil)
+		head := firstRangeNode(r, c0)
+
+		Convey("doesn't remove anything from a clean repo", func() {
+			stats, err := graph.GC(r)
+			So(err, ShouldBeNil)
+			So(stats, ShouldResemble, graph.GCStats{})
+		})
+
+		Convey("removes content orphaned by splitting nodes", func() {
+			originalContent := r.GetNode(head).GetContentHash()
+			c1 := &jpb.Commit{
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
+				EdgeRefs: []*jpb.EdgeRef{
+					{
+						Src:    &jpb.NodeRef{Node: head, Depth: 1},
+						Chunks: stringsToContent("BRAVO"),
+						Dst:    &jpb.NodeRef{Node: head, Depth: 2},
+					},
+				},
+			}
+			So(graph.Apply(r, c1), ShouldBeNil)
+			So(r.GetContent(originalContent), ShouldNotBeNil)
+
+			stats, err := graph.GC(r)
+			So(err, ShouldBeNil)
+			So(stats.Nodes, ShouldEqual, 0)
+			So(stats.Contents, ShouldBeGreaterThan, 0)
+			So(r.GetContent(originalContent), ShouldBeNil)
+			So(snippet{r, explicitFrontier(c0), "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.bravo.charlie.delta")
+			So(snippet{r, explicitFrontier(c0, c1), "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.BRAVO.charlie.delta")
+
+			stats, err = graph.GC(r)
+			So(err, ShouldBeNil)
+			So(stats, ShouldResemble, graph.GCStats{})
+		})
+
+		Convey("removes unreachable nodes and stale refs", func() {
+			contentHash := r.PutContent(stringsToContent("orphan"))
+			r.PutNode(&jpb.Node{Head: "orphan", Tail: "orphan", Content: &jpb.Node_ContentHash{ContentHash: contentHash}, Count: 1})
+			r.PutRef("orphan", "orphan")
+			r.PutRef("dangling", "missing")
+
+			stats, err := graph.GC(r)
+			So(err, ShouldBeNil)
+			So(stats, ShouldResemble, graph.GCStats{Nodes: 1, Contents: 1, Refs: 2})
+			So(r.GetNode("orphan"), ShouldBeNil)
+			So(r.GetRef("dangling"), ShouldEqual, "")
+			So(snippet{r, explicitFrontier(c0), "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.bravo.charlie.delta")
+		})
+	})
+}
+
+// firstRangeNode returns the node that starts the first ReadRange of foo.txt as seen by c, which
+// must have been applied to r already.
+func firstRangeNode(r graph.Repo, c *jpb.Commit) string {
+	var ranges []graph.ReadRange
+	if _, err := graph.ReadVersion(r, explicitFrontier(c), "src:foo.txt", "snk:foo.txt", &graph.ReadMetadata{Ranges: &ranges}); err != nil {
+		panic(err)
+	}
+	return ranges[0].Node
+}
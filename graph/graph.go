@@ -3,11 +3,14 @@ package graph
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	jpb "github.com/runningwild/jig/proto"
-	"golang.org/x/crypto/sha3"
 )
 
 type Repo interface {
@@ -15,8 +18,16 @@ type Repo interface {
 	GetNode(nodeHash string) *jpb.Node
 	GetContent(contentHash string) [][]byte
 	GetCommit(commitHash string) *jpb.Commit
+
+	// GetCommitMetadata returns only the metadata of the specified commit, which is much cheaper than
+	// getting the entire commit when that is all that's needed.  Returns nil if the commit has no
+	// metadata.
+	GetCommitMetadata(commitHash string) *jpb.CommitMetadata
 	GetReverseDeps(commitHash string) []string
 
+	// HashAlgorithm returns the algorithm used for every hash in this repo.
+	HashAlgorithm() HashAlgorithm
+
 	// List methods all fill out the given slice with as many hashes as possible of the specified,
 	// it returns the number of elements filled.
 	ListRefs(start string, refs []string) (n int)
@@ -24,39 +35,153 @@ type Repo interface {
 	ListContents(start string, contents []string) (n int)
 	ListCommits(start string, commits []string) (n int)
 
+	// StartTransaction begins a transaction.  All reads and writes made until the matching call to
+	// EndTransaction or AbortTransaction are grouped together and become visible to other users of
+	// the repo atomically.  Transactions may be nested, in which case only the outermost transaction
+	// actually commits anything.  Every call to StartTransaction must be paired with exactly one call
+	// to either EndTransaction or AbortTransaction.
 	StartTransaction()
+
+	// EndTransaction ends the current transaction.  If this is the outermost transaction all of its
+	// writes are committed.  If any transaction nested within the outermost one was aborted then
+	// the whole thing is rolled back and ErrTransactionAborted is returned.
 	EndTransaction() error
 
+	// AbortTransaction ends the current transaction and discards every write made since the
+	// outermost transaction was started.
+	AbortTransaction()
+
 	PutRef(ptr, val string)
+	DeleteRef(ptr string)
 
 	PutNode(n *jpb.Node)
 	DeleteNode(nodeHash string)
 
-	// TODO: Need to decide how to handle multiple references to a single content.  GC or reference counting?
+	// Content may be shared by any number of nodes, so nothing is deleted when a node stops using
+	// it.  Unreferenced content is cleaned up by GC.
 	PutContent(content [][]byte) string
 	DeleteContent(contentHash string)
 
 	PutCommit(c *jpb.Commit)
+	DeleteCommit(commitHash string)
 
 	PutReverseDep(newCommit, oldCommit string)
 	DeleteReverseDep(newCommit, oldCommit string)
-
-	// TODO: Need some kind of first-class handling of frontiers.  Perhaps something like this:
-	// CreateFrontier(commits []string) string  // creates a frontier out of commits and returns the ID
-	// DeltaSet(from, to string) string // given two frontiers, returns an ID for a set representing all
-	//									// commits in to that are not in from.
 }
 
 // A View represents an individual user's view of the repo
 type View interface {
-	ListFrontiers(start string, frontiers []string) (n int, err error)
+	// ListFrontiers fills out the given slice with as many frontiers as possible, in order of name
+	// starting with start, and returns the number of elements filled.
+	ListFrontiers(start string, frontiers []FrontierInfo) (n int, err error)
+	StatFrontier(frontier string) (FrontierInfo, error)
 	GetFrontier(frontier string) (Frontier, error)
+
+	// CurrentFrontier returns the name of the frontier that AdvanceFrontier and RetreatFrontier
+	// change.
 	CurrentFrontier() (string, error)
 	ChangeFrontiers(frontier string) error
+
+	// AdvanceFrontier adds commit to the current frontier along with everything it depends on.
 	AdvanceFrontier(commit string) error
+
+	// RetreatFrontier removes commit from the current frontier along with everything in the
+	// frontier that depends on it.
+	RetreatFrontier(commit string) error
+
+	// CreateFrontier creates a frontier that observes the same commits as the current frontier.
 	CreateFrontier(frontier string) error
+
+	// MergeFrontier adds every commit that from observes to the current frontier, and reports on
+	// the files that the newly observed commits touched.
+	MergeFrontier(from string) (*MergeReport, error)
+
+	// CreateTag creates a tag, which is a frontier that observes the same commits as frontier does
+	// now and can never be changed to observe anything else.  Tags can be used like any other
+	// frontier otherwise.
+	CreateTag(tag, frontier string) error
+
+	// DeleteFrontier deletes a frontier, which can't be the current frontier.
+	DeleteFrontier(frontier string) error
+
+	// RenameFrontier renames a frontier, if it is the current frontier it remains so.
+	RenameFrontier(from, to string) error
+
+	DescribeFrontier(frontier, description string) error
+
+	// FrontierLog returns every change made to a frontier, oldest first.
+	FrontierLog(frontier string) ([]FrontierLogEntry, error)
+
+	// RestoreFrontier makes a frontier observe the same commits it did right after the change
+	// recorded by the entry in its log with the given index.
+	RestoreFrontier(frontier string, index uint64) error
+
+	// PushStash adds commit, which must already be in the repo, to the end of the stash list.  No
+	// frontier is changed, so the commit isn't observed by anything until it is popped.
+	PushStash(commit string) error
+
+	// ListStashes returns the stash list, oldest first.
+	ListStashes() ([]StashEntry, error)
+
+	// PopStash advances the current frontier with the commit in the stash entry with the given
+	// index, removes the entry, and reports on the files that the newly observed commits touched.
+	PopStash(index uint64) (*MergeReport, error)
+
+	// DropStash removes an entry from the stash list without advancing any frontier.
+	DropStash(index uint64) error
 }
 
+// A StashEntry is a commit that has been set aside with View.PushStash.
+type StashEntry struct {
+	// Index identifies the entry within the stash list, later entries have larger indexes.
+	Index uint64
+	Time  time.Time
+
+	// Frontier is the frontier that was current when the commit was stashed.
+	Frontier string
+	Commit   string
+}
+
+// A FrontierLogEntry records a change made to a frontier.
+type FrontierLogEntry struct {
+	// Index identifies the entry within the frontier's log, later entries have larger indexes.
+	Index uint64
+	Time  time.Time
+
+	// Op is the kind of change, one of "create", "tag", "advance", "retreat", "merge", "switch",
+	// "rename", "restore" or "pop", and Commit is the commit it was made with, if any.
+	Op     string
+	Commit string
+
+	// Heads and Excludes describe the commits the frontier observed after the change, as in
+	// CommitSet.
+	Heads    []string
+	Excludes []string
+}
+
+// FrontierInfo describes a named frontier in a View.
+type FrontierInfo struct {
+	Name string
+
+	// Heads are the commits in the frontier that no other commit in it depends on.
+	Heads []string
+
+	// Created is when the frontier was created, it is the zero time if that isn't known.
+	Created     time.Time
+	Description string
+
+	// Tag is true iff the frontier is a tag, see View.CreateTag.
+	Tag bool
+}
+
+var (
+	ErrUnknownFrontier = errors.New("unknown frontier")
+	ErrFrontierExists  = errors.New("frontier already exists")
+	ErrCurrentFrontier = errors.New("frontier is the current frontier")
+	ErrTag             = errors.New("frontier is a tag and can't be changed")
+	ErrUnknownStash    = errors.New("unknown stash entry")
+)
+
 // SplitNode takes a node and a depth and replaces that node with two nodes, split at the specified
 // depth.  The first of those nodes will have the same Head hash, and the second will have the same
 // Tail hash.  This function will return the Tail hash of the first node and the Head hash of the second.
@@ -64,18 +189,19 @@ func SplitNode(r Repo, node string, depth int32) (tail, head string, err error)
 	if depth <= 0 {
 		return "", "", fmt.Errorf("cannot split a node at depth <= 0")
 	}
-	var n *jpb.Node
-	for n = r.GetNode(node); n != nil && depth > n.Count && len(n.Out) > 0; n = r.GetNode(n.Out[0].Node) {
-		depth -= n.Count
-	}
-	if n == nil || depth > n.Count {
-		return "", "", fmt.Errorf("depth is beyond original node's length")
+	n, depth, err := findDepth(r, node, depth)
+	if err != nil {
+		return "", "", err
 	}
 
 	// For simplicity we'll just special case splitting at the very beginning of a file...
 	if n.GetSrc() != nil && depth == 1 {
 		return n.Tail, "", nil
 	}
+	// ... right after the end of a file, which is where renames continue from ...
+	if n.GetSnk() != nil && depth == 1 {
+		return n.Tail, "", nil
+	}
 	// ... and the very end.
 	if n.GetSnk() != nil && depth == 0 {
 		return "", n.Head, nil
@@ -99,8 +225,9 @@ func SplitNode(r Repo, node string, depth int32) (tail, head string, err error)
 		return n.Tail, n.Out[0].Node, nil
 	}
 
-	head0, tail0 := CalculateNodeHashes(commitHash, n.In[0].Node, content[0:depth])
-	head1, tail1 := CalculateNodeHashes(commitHash, tail0, content[depth:])
+	alg := r.HashAlgorithm()
+	head0, tail0 := alg.CalculateNodeHashes(commitHash, n.In[0].Node, content[0:depth])
+	head1, tail1 := alg.CalculateNodeHashes(commitHash, tail0, content[depth:])
 	if head0 != n.Head {
 		return "", "", fmt.Errorf("failed to calculate node head hashes properly")
 	}
@@ -108,40 +235,19 @@ func SplitNode(r Repo, node string, depth int32) (tail, head string, err error)
 		return "", "", fmt.Errorf("failed to calculate node tail hashes properly")
 	}
 
+	// This is just to verify that things make sense.  Join edges should be present on both In and Out.
+	if err := checkJoins(n); err != nil {
+		return "", "", fmt.Errorf("%q is malformed: %v", n.Head, err)
+	}
+
 	r.StartTransaction()
-	defer func() {
-		tErr := r.EndTransaction()
-		if err == nil {
-			err = tErr
-		}
-	}()
+	defer endTransaction(r, &err)
 
 	// TODO: Return an error if we're trying to split a src or snk node.
 
 	contentA := r.PutContent(content[0:depth])
 	contentB := r.PutContent(content[depth:])
 
-	// This is just to verify that things make sense.  Join edges should be present on both In and Out.
-	internalCommits := make(map[string]bool)
-	for _, e := range n.In {
-		if e.Join {
-			internalCommits[e.Commit] = true
-		}
-	}
-	for _, e := range n.Out {
-		if e.Join {
-			if _, ok := internalCommits[e.Commit]; !ok {
-				panic("internal commit was present in Out but not In")
-			}
-			delete(internalCommits, e.Commit)
-		}
-	}
-	if len(internalCommits) != 0 {
-		panic("internal commit was present in In but not Out")
-	}
-
-	fmt.Sprintf("Splitting node %s with In %v and Out %v\n", n.Head, n.In, n.Out)
-
 	a := &jpb.Node{
 		Head: head0,
 		Tail: tail0,
@@ -162,12 +268,10 @@ func SplitNode(r Repo, node string, depth int32) (tail, head string, err error)
 		// In:      []Edge{{Commit: commitHash, Node: tail0}},
 		Out: n.Out,
 	}
-	fmt.Printf("For node %s\n", n.Head)
-	for i, e := range n.In {
+	for _, e := range n.In {
 		if e.Join {
 			a.Out = append(a.Out, &jpb.Edge{Commit: e.Commit, Node: head1, Join: true})
 			b.In = append(b.In, &jpb.Edge{Commit: e.Commit, Node: tail0, Join: true})
-			fmt.Printf("  Joining edge %d with %s\n", i, e.Commit)
 		}
 	}
 
@@ -182,70 +286,45 @@ func SplitNode(r Repo, node string, depth int32) (tail, head string, err error)
 	return tail0, head1, nil
 }
 
-func HumanReadable(r Repo, f, prev Frontier, path string, conflicts []Conflict, join []byte) ([]byte, error) {
-	conflictLookup := make(map[string]int)
-	for i, c := range conflicts {
-		conflictLookup[c.Start] = i
+// HumanReadable returns the file at path as of f with lines joined by join, with each of conflicts
+// written by a GitRenderer, or by a Diff3Renderer if base is set.  See Render for other formats.
+func HumanReadable(r Repo, f Frontier, path string, conflicts []Conflict, join []byte, base bool) ([]byte, error) {
+	var cr ConflictRenderer = GitRenderer{}
+	if base {
+		cr = Diff3Renderer{}
 	}
-	fmt.Printf("Lookups: %v\n", conflictLookup)
-	var lines [][]byte
-	nextNode := "src:" + path
-	for !strings.HasPrefix(nextNode, "snk:") {
-		next := r.GetNode(nextNode)
-		if next == nil {
-			return nil, fmt.Errorf("failed to find node %s", nextNode)
-		}
-		lines = append(lines, r.GetContent(next.GetContentHash())...)
-		fmt.Printf("Node: %v %v\n", nextNode, r.GetNode(nextNode).Tail)
-		if ci, ok := conflictLookup[next.Tail]; ok {
-			// We have to display a conflict here.
-			lines = lines[0 : len(lines)-1]
-			con := conflicts[ci]
-			fmt.Printf("ReadVersions with conflicts: %v\n", con.Commits)
-			vs, err := ReadVersions(r, f, prev, con.Start, con.End, conflicts[ci].Groups, join)
-			if err != nil {
-				return nil, err
-			}
-
-			lines = append(lines, []byte("<<<<<<<"))
-			for _, v := range vs {
-				lines = append(lines, []byte(fmt.Sprintf("======= %v", v.Commits)))
-				lines = append(lines, v.Data)
-			}
-			lines = append(lines, []byte(">>>>>>>"))
-			next = r.GetNode(con.End)
-			lines = append(lines, r.GetContent(r.GetNode(con.End).GetContentHash())[1:]...)
-
-		} else {
-		}
+	return Render(r, f, path, conflicts, cr, join)
+}
 
-		fmt.Printf("Content(%s): %s\n", next.Head, r.GetContent(next.GetContentHash()))
-		var edge *jpb.Edge
-		for i := len(next.Out) - 1; i >= 0; i-- {
-			obs, err := f.Observes(next.Out[i].Commit)
-			if err != nil {
-				return nil, err
-			}
-			if obs {
-				edge = next.Out[i]
-				break
-			}
-		}
-		if edge == nil {
-			return nil, fmt.Errorf("failed pathing through %q", path)
+// ReadVersions returns one Version for each of groups.  Each is what f has between start and end
+// when it observes the commits in that group and none of the commits in the other groups, so an
+// empty group gives the version that every other group started from.  If a group deletes the file
+// its Version is Deleted.  See ReadBase for the version before a conflict.
+func ReadVersions(r Repo, f Frontier, start, end string, groups [][]string, join []byte) ([]Version, error) {
+	vs, err := readVersions(r, f, start, end, groups)
+	if err != nil {
+		return nil, err
+	}
+	var versions []Version
+	for _, v := range vs {
+		if v.deleted {
+			versions = append(versions, Version{Commits: v.commits, Deleted: true})
+			continue
 		}
-		nextNode = edge.Node
+		versions = append(versions, Version{Commits: v.commits, Data: bytes.Join(v.lines, join)})
 	}
-	return bytes.Join(lines, join), nil
+	return versions, nil
+}
+
+// versionLines is a Version that hasn't had its lines joined.
+type versionLines struct {
+	lines   [][]byte
+	commits map[string]bool
+	deleted bool
 }
 
-// TODO: This comment isn't close to being correct, but I'm not sure what the correct comment is.
-// ReadVersions returns a []Version with one Version for each conflicting view of the file.  If prev
-// is not nil, then one of the Versions will correspond to the conflicting commits, and all other
-// Versions will contain a single conflicting commit.
-// TODO: The restriction below should be verified.
-// If prev is set it must be a frontier at which there is no conflict between start and end.
-func ReadVersions(r Repo, f, prev Frontier, start, end string, groups [][]string, join []byte) ([]Version, error) {
+// readVersions does the work for ReadVersions.
+func readVersions(r Repo, f Frontier, start, end string, groups [][]string) ([]versionLines, error) {
 	allCommits := make(map[string]bool)
 	var groupMaps []map[string]bool
 	for i := range groups {
@@ -257,43 +336,42 @@ func ReadVersions(r Repo, f, prev Frontier, start, end string, groups [][]string
 		groupMaps = append(groupMaps, groupMap)
 
 	}
-	var versions []Version
-	// used := make(map[string]bool)
-
-	// TODO: Figure out how this works with prev
-	// if prev != nil {
-	// 	allCommits := make(map[string]bool)
-	// 	lines, err := ReadVersion(r, prev, start, end, &ReadMetadata{Commits: allCommits})
-	// 	if err != nil {
-	// 		return nil, err
-	// 	}
-	// 	data := bytes.Join(lines, join)
-	// 	commits := make(map[string]bool)
-	// 	for c := range allCommits {
-	// 		if _, ok := conflicts[c]; ok {
-	// 			commits[c] = true
-	// 		}
-	// 	}
-	// 	versions = append(versions, Version{Commits: commits, Data: data})
-	// 	used = commits
-	// }
+	var versions []versionLines
 
 	// TODO: The way we're constructing the frontier here is a bit strange and I think it's not correct.
 	for _, groupMap := range groupMaps {
-		// if used[c] {
-		// 	continue
-		// }
 		next := &addToFrontier{f: &removeFromFrontier{f: f, remove: allCommits}, add: groupMap}
 		lines, err := ReadVersion(r, next, start, end, &ReadMetadata{})
+		if errors.Is(err, ErrDeleted) {
+			versions = append(versions, versionLines{commits: groupMap, deleted: true})
+			continue
+		}
 		if err != nil {
 			return nil, err
 		}
-		data := bytes.Join(lines, join)
-		versions = append(versions, Version{Commits: groupMap, Data: data})
+		versions = append(versions, versionLines{lines: lines, commits: groupMap})
 	}
 	return versions, nil
 }
 
+// ReadBase returns what c looked like before any of the commits in it, which is what f has between
+// c.Start and c.End when it observes none of c.Commits.  The returned Version has no Commits.
+func ReadBase(r Repo, f Frontier, c Conflict, join []byte) (Version, error) {
+	lines, err := readBase(r, f, c)
+	if err != nil {
+		return Version{}, err
+	}
+	return Version{Data: bytes.Join(lines, join), Commits: map[string]bool{}}, nil
+}
+
+func readBase(r Repo, f Frontier, c Conflict) ([][]byte, error) {
+	lines, err := ReadVersion(r, &removeFromFrontier{f: f, remove: c.Commits}, c.Start, c.End, &ReadMetadata{})
+	if err != nil {
+		return nil, fmt.Errorf("reading base: %w", err)
+	}
+	return lines, nil
+}
+
 type addToFrontier struct {
 	f   Frontier
 	add map[string]bool
@@ -318,16 +396,127 @@ func (f *removeFromFrontier) Observes(commit string) (bool, error) {
 	return f.f.Observes(commit)
 }
 
-func ReadFile(r Repo, f Frontier, path string, metadata *ReadMetadata) ([][]byte, error) {
-	return ReadVersion(r, f, "src:"+path, "snk:"+path, metadata)
+// FileState says what a frontier sees at a path.
+type FileState int
+
+const (
+	// FileAbsent means that no commit the frontier observes created the file.
+	FileAbsent FileState = iota
+
+	// FileDeleted means that the file was created and then deleted or renamed.
+	FileDeleted
+
+	// FileEmpty means that the file exists but has no lines.
+	FileEmpty
+
+	// FilePresent means that the file exists, has at least one line, and has no conflicts.
+	FilePresent
+
+	// FileConflicted means that the file exists but commits that don't depend on each other have
+	// made conflicting changes to it.
+	FileConflicted
+)
+
+func (s FileState) String() string {
+	switch s {
+	case FileAbsent:
+		return "absent"
+	case FileDeleted:
+		return "deleted"
+	case FileEmpty:
+		return "empty"
+	case FilePresent:
+		return "present"
+	case FileConflicted:
+		return "conflicted"
+	}
+	return fmt.Sprintf("FileState(%d)", int(s))
+}
+
+// A FileVersion is a file as seen by a frontier.
+type FileVersion struct {
+	State FileState
+
+	// Lines is nil unless the file exists.  If the file is conflicted it is the content that readers
+	// see by following the last edge they observe out of every line, and Conflicts holds the
+	// conflicts within it.  A file that was deleted while it was being edited has no Lines, and its
+	// only conflict is a DeleteEditConflict.
+	Lines     [][]byte
+	Conflicts []Conflict
+
+	// ConflictsErr is why the conflicts in the file couldn't be found, if they couldn't.  Lines are
+	// still read when that happens, but State can't say whether the file is conflicted.
+	ConflictsErr error
+}
+
+// ReadFile reads the file at path as seen by f.  A file that is absent or deleted is not an error,
+// the state of the file is reported in the returned FileVersion, and neither is failing to find its
+// conflicts, which is reported in ConflictsErr.  metadata is filled as in ReadVersion.
+func ReadFile(r Repo, f Frontier, path string, metadata *ReadMetadata) (*FileVersion, error) {
+	state, err := statFile(r, f, path)
+	if err != nil {
+		return nil, err
+	}
+	v := &FileVersion{State: state}
+	if state == FileDeleted {
+		// Edits that the deletion didn't know about conflict with it.
+		v.findConflicts(r, f, path)
+		if len(v.Conflicts) > 0 {
+			v.State = FileConflicted
+		}
+		return v, nil
+	}
+	if state != FilePresent {
+		return v, nil
+	}
+	if v.Lines, err = ReadVersion(r, f, "src:"+path, "snk:"+path, metadata); err != nil {
+		return nil, err
+	}
+	if len(v.Lines) == 0 {
+		v.State = FileEmpty
+		return v, nil
+	}
+	v.findConflicts(r, f, path)
+	if len(v.Conflicts) > 0 {
+		v.State = FileConflicted
+	}
+	return v, nil
+}
+
+// findConflicts fills in v.Conflicts, or v.ConflictsErr if they can't be found.
+func (v *FileVersion) findConflicts(r Repo, f Frontier, path string) {
+	v.Conflicts, v.ConflictsErr = FindConflicts(r, f, path)
+	if v.ConflictsErr != nil {
+		v.Conflicts = nil
+		v.ConflictsErr = fmt.Errorf("failed to find conflicts in %q: %w", path, v.ConflictsErr)
+	}
 }
 
 var (
-	ErrNoObserve = fmt.Errorf("not observable from this frontier")
+	ErrNoObserve          = fmt.Errorf("not observable from this frontier")
+	ErrDeleted            = fmt.Errorf("file has been deleted")
+	ErrTransactionAborted = fmt.Errorf("transaction was aborted")
 )
 
+// endTransaction is meant to be deferred immediately after a call to r.StartTransaction().  It will
+// abort the transaction if *err is non-nil or if the caller panics, otherwise it ends the
+// transaction and stores any resulting error in *err.
+func endTransaction(r Repo, err *error) {
+	if p := recover(); p != nil {
+		r.AbortTransaction()
+		panic(p)
+	}
+	if *err != nil {
+		r.AbortTransaction()
+		return
+	}
+	*err = r.EndTransaction()
+}
+
+// ReadVersion returns the lines between start and end as seen by f.  If start is a file's src node
+// and the file is absent or deleted as of f the returned error wraps ErrNoObserve or ErrDeleted
+// respectively, use ReadFile to read a whole file along with its state.
 // Any fields within metadata that are non-nil will be filled with the relevant data.
-// TODO: Need to be able to distinguish between an empty file, a non-existent file, and a conflict.
 func ReadVersion(r Repo, f Frontier, start, end string, metadata *ReadMetadata) ([][]byte, error) {
 	if metadata == nil {
 		metadata = &ReadMetadata{}
@@ -350,12 +539,21 @@ func ReadVersion(r Repo, f Frontier, start, end string, metadata *ReadMetadata)
 	if len(n.In) == 0 && len(n.Out) == 0 {
 		return nil, fmt.Errorf("start node was invalid, it had no input or output edges")
 	}
-	obs, err := f.Observes(n.GetOut()[0].GetCommit())
-	if err != nil {
+	if n.GetSrc() != nil {
+		state, err := srcState(f, n)
+		if err != nil {
+			return nil, err
+		}
+		switch state {
+		case FileAbsent:
+			return nil, fmt.Errorf("this file has not been created under this frontier: %w", ErrNoObserve)
+		case FileDeleted:
+			return nil, fmt.Errorf("%q: %w", start, ErrDeleted)
+		}
+	} else if obs, err := f.Observes(n.GetOut()[0].GetCommit()); err != nil {
 		return nil, err
-	}
-	if !obs {
-		return nil, fmt.Errorf("this file has not been created under this frontier: %w", ErrNoObserve)
+	} else if !obs {
+		return nil, fmt.Errorf("start node %q: %w", n.Head, ErrNoObserve)
 	}
 	prev := n
 
@@ -373,26 +571,25 @@ func ReadVersion(r Repo, f Frontier, start, end string, metadata *ReadMetadata)
 		metadata.Commits[n.In[0].Commit] = true
 	}
 
+	var renames []string
 	for {
-		for i := len(n.Out) - 1; i >= 0; i-- {
-			e := n.Out[i]
-			obs, err := f.Observes(e.Commit)
-			if err != nil {
-				return nil, err
-			}
-			if !obs {
-				fmt.Printf("Not taking edge from commit %s\n", e.Commit)
-				continue
-			}
-			if metadata.Commits != nil {
-				metadata.Commits[e.Commit] = true
-			}
-			n = r.GetNode(e.Node)
-			fmt.Printf("Took node with content: %q\n", r.GetContent(n.GetContentHash()))
-			if n == nil {
-				return nil, fmt.Errorf("failed to find node %s in the repo", e.Node)
+		e, err := nextEdge(r, f, n, renames)
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			if n.GetSnk() != nil {
+				return nil, fmt.Errorf("reached end of file without reaching the dst node")
 			}
-			break
+			return nil, fmt.Errorf("failed to find an outgoing edge from %s", prev.Head)
+		}
+		if metadata.Commits != nil {
+			metadata.Commits[e.Commit] = true
+		}
+		renames = followRenames(n, e, renames)
+		n = r.GetNode(e.Node)
+		if n == nil {
+			return nil, fmt.Errorf("failed to find node %s in the repo", e.Node)
 		}
 
 		// Prevents traversing cycles more than once.
@@ -401,14 +598,12 @@ func ReadVersion(r Repo, f Frontier, start, end string, metadata *ReadMetadata)
 		}
 		used[n.Head] = struct{}{}
 
-		if n == prev {
-			return nil, fmt.Errorf("failed to find an outgoing edge from %s", prev.Head)
-		}
 		if n.Head == end {
 			break
 		}
-		if strings.HasPrefix(n.Head, "snk:") {
-			return nil, fmt.Errorf("reached end of file without reaching the dst node")
+		if n.GetSrc() != nil || n.GetSnk() != nil {
+			// We followed a rename, these nodes have no content.
+			continue
 		}
 		content := r.GetContent(n.GetContentHash())
 		buf = append(buf, content...)
@@ -450,7 +645,6 @@ func nodeRef(r Repo, n *jpb.Node) (string, int) {
 // GetContent reads the content from the specified between depths specified by start and end.  This
 // will follow primary edges to do so, and so is the appropriate way to read content specified by ReadRanges.
 func GetContent(r Repo, nodeHash string, start, end int) [][]byte {
-	fmt.Printf("GetContent(node:%s, content:%s): %d,%d\n", nodeHash, r.GetNode(nodeHash).GetContentHash(), start, end)
 	n := r.GetNode(nodeHash)
 	count := int(n.Count)
 	if count < start {
@@ -486,24 +680,30 @@ type ReadRange struct {
 type Version struct {
 	Data    []byte
 	Commits map[string]bool
+
+	// Deleted is set if the commits delete the file, in which case there is no Data.
+	Deleted bool
 }
 
-func jigStandardHasher() *jigHasher {
-	return &jigHasher{Buffer: bytes.NewBuffer(nil)}
+func (a HashAlgorithm) hasher() *jigHasher {
+	return &jigHasher{Buffer: bytes.NewBuffer(nil), alg: a}
 }
 
 type jigHasher struct {
 	*bytes.Buffer
+	alg HashAlgorithm
 }
 
 func (j *jigHasher) Sum() string {
-	b := make([]byte, 4)
-	sha3.ShakeSum128(b, j.Bytes())
-	return fmt.Sprintf("%x", b)
+	spec, ok := hashAlgorithms[j.alg]
+	if !ok {
+		panic(fmt.Sprintf("unknown hash algorithm %q", j.alg))
+	}
+	return spec.prefix + hex.EncodeToString(spec.sum(j.Bytes()))
 }
 
-func HashContent(content [][]byte) string {
-	h := jigStandardHasher()
+func (a HashAlgorithm) HashContent(content [][]byte) string {
+	h := a.hasher()
 	for _, line := range content {
 		length := uint32(len(line))
 		h.Write([]byte{byte(length), byte(length >> 8), byte(length >> 16), byte(length >> 24)})
@@ -512,39 +712,21 @@ func HashContent(content [][]byte) string {
 	return h.Sum()
 }
 
-func Apply(r Repo, c *jpb.Commit) error {
-	// Validate that we haven't already applied this commit.
-	commitHash := HashCommit(c)
-	if c2 := r.GetCommit(commitHash); c2 != nil {
-		return fmt.Errorf("this commit has already been applied")
+// Apply adds the edges and content specified by c to the repo.  All changes are made within a single
+// transaction, so if Apply returns an error the repo is left exactly as it was.  Malformed commits
+// are rejected with an *InvalidCommitError before anything is modified.
+func Apply(r Repo, c *jpb.Commit) (err error) {
+	alg := r.HashAlgorithm()
+	commitHash := alg.HashCommit(c)
+	if err := validateCommit(r, commitHash, c); err != nil {
+		return err
 	}
 
-	// Validate that we have all of the commits this commit depends on.
-	for _, dep := range c.Deps {
-		if d := r.GetCommit(dep); d == nil {
-			return fmt.Errorf("this commit depends on %q which we don't have", dep)
-		}
-	}
-
-	// TODO: Validate at LEAST all of the following:
-	//   all new content has an outgoing edge (which may be to an EOF marker).
-	//   no cycles
-	//   no creation of two edges from or to the same node
-	//   really there are so many more...
-	//   new content nodes do not connect to other new content nodes
-	//   new content nodes have an input and output edge
-	//   refs that specify joins must come in pairs
-
-	for _, e := range c.EdgeRefs {
-		if e.Src.Depth < 1 && !strings.HasPrefix(e.Src.Node, "src:") {
-			return fmt.Errorf("cannot specify an EdgeRef with a content src node at depth < 1")
-		}
-		if e.Src.Depth < 0 && !strings.HasPrefix(e.Src.Node, "snk:") {
-			return fmt.Errorf("cannot specify an EdgeRef with a content dst node at depth < 0")
-		}
-	}
+	r.StartTransaction()
+	defer endTransaction(r, &err)
 
-	for _, e := range c.EdgeRefs {
+	tails := make([]string, len(c.EdgeRefs))
+	for i, e := range c.EdgeRefs {
 		var tail, head string
 
 		// TODO: Do there need to be any restrictions on when a src or snk node can be used as a noderef?
@@ -554,7 +736,7 @@ func Apply(r Repo, c *jpb.Commit) error {
 			r.PutNode(&jpb.Node{Head: e.Src.Node, Tail: e.Src.Node, Content: &jpb.Node_Src{Src: &jpb.Src{}}, Count: 1})
 			r.PutRef(e.Src.Node, e.Src.Node)
 		}
-		if strings.HasPrefix(e.Dst.Node, "snk:") && r.GetNode(e.Dst.Node) == nil {
+		if (strings.HasPrefix(e.Dst.Node, "snk:") || e.Dst.Node == DeletedNode) && r.GetNode(e.Dst.Node) == nil {
 			r.PutNode(&jpb.Node{Head: e.Dst.Node, Tail: e.Dst.Node, Content: &jpb.Node_Snk{Snk: &jpb.Snk{}}, Count: 1})
 			r.PutRef(e.Dst.Node, e.Dst.Node)
 		}
@@ -564,6 +746,7 @@ func Apply(r Repo, c *jpb.Commit) error {
 		if err != nil {
 			return fmt.Errorf("error splitting src node: %v", err)
 		}
+		tails[i] = tail
 
 		if e.Dst.Depth == 0 {
 			// This is fine, this just means an edge will connect directly to e.Dst and we don't
@@ -571,9 +754,7 @@ func Apply(r Repo, c *jpb.Commit) error {
 			head = e.Dst.Node
 		} else {
 			var err error
-			fmt.Printf("Splitting %s at depth %d\n", e.Dst.Node, e.Dst.Depth)
 			_, head, err = SplitNode(r, e.Dst.Node, e.Dst.Depth)
-			fmt.Printf("Using head %s\n", head)
 			if err != nil {
 				return fmt.Errorf("error splitting dst node: %v", err)
 			}
@@ -591,9 +772,7 @@ func Apply(r Repo, c *jpb.Commit) error {
 		if dst == nil {
 			return fmt.Errorf("failed to get dst node %s", head)
 		}
-		fmt.Printf("using dst node %s\n", dst.Head)
 		if len(e.Chunks) == 0 {
-			fmt.Printf("Pinning %t %t\n", e.Src.Join, e.Dst.Join)
 			src.Out = append(src.Out, &jpb.Edge{Commit: commitHash, Node: dst.Head, Join: e.Src.Join})
 			dst.In = append(dst.In, &jpb.Edge{Commit: commitHash, Node: src.Tail, Join: e.Dst.Join})
 			r.PutNode(src)
@@ -602,7 +781,7 @@ func Apply(r Repo, c *jpb.Commit) error {
 		}
 
 		content := r.PutContent(e.Chunks)
-		newHead, newTail := CalculateNodeHashes(commitHash, tail, e.Chunks)
+		newHead, newTail := alg.CalculateNodeHashes(commitHash, tail, e.Chunks)
 		middle := &jpb.Node{
 			Head:    newHead,
 			Tail:    newTail,
@@ -628,6 +807,18 @@ func Apply(r Repo, c *jpb.Commit) error {
 		r.PutNode(dst)
 	}
 
+	// A reader that observes this commit takes its edges wherever it meets them, since they are the
+	// newest, so if it can get through the file from each of them it can get through the whole file.
+	// Later splits may have moved the tails, so look up where they are now.
+	starts := make([]string, len(tails))
+	for i, tail := range tails {
+		starts[i] = r.GetRef(tail)
+	}
+	f := &addToFrontier{f: NewCommitSet(r, c.Deps, nil), add: map[string]bool{commitHash: true}}
+	if i, err := checkReads(r, f, starts); err != nil {
+		return invalidCommit(i, "%w", err)
+	}
+
 	for _, dep := range c.Deps {
 		r.PutReverseDep(commitHash, dep)
 	}
@@ -636,8 +827,8 @@ func Apply(r Repo, c *jpb.Commit) error {
 	return nil
 }
 
-func HashCommit(c *jpb.Commit) string {
-	h := jigStandardHasher()
+func (a HashAlgorithm) HashCommit(c *jpb.Commit) string {
+	h := a.hasher()
 
 	binary.Write(h, binary.LittleEndian, uint32(len(c.Deps)))
 	for _, d := range c.Deps {
@@ -647,8 +838,8 @@ func HashCommit(c *jpb.Commit) string {
 
 	binary.Write(h, binary.LittleEndian, uint32(len(c.EdgeRefs)))
 	for _, e := range c.EdgeRefs {
-		binary.Write(h, binary.LittleEndian, []byte(e.Src.Node))
-		binary.Write(h, binary.LittleEndian, uint32(e.Src.Depth))
+		binary.Write(h, binary.LittleEndian, []byte(e.GetSrc().GetNode()))
+		binary.Write(h, binary.LittleEndian, uint32(e.GetSrc().GetDepth()))
 
 		if len(e.Chunks) == 0 {
 			binary.Write(h, binary.LittleEndian, uint32(0))
@@ -660,8 +851,29 @@ func HashCommit(c *jpb.Commit) string {
 			}
 		}
 
-		binary.Write(h, binary.LittleEndian, []byte(e.Dst.Node))
-		binary.Write(h, binary.LittleEndian, uint32(e.Dst.Depth))
+		binary.Write(h, binary.LittleEndian, []byte(e.GetDst().GetNode()))
+		binary.Write(h, binary.LittleEndian, uint32(e.GetDst().GetDepth()))
+	}
+
+	// Metadata is deliberately part of the hash, see the comment on Commit.metadata.  Commits without
+	// metadata hash exactly the same as they did before it existed.
+	if md := c.Metadata; md != nil {
+		var keys []string
+		for key := range md.Headers {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fields := []string{md.Author, md.Committer, md.Message}
+		for _, key := range keys {
+			fields = append(fields, key, md.Headers[key])
+		}
+		h.Write([]byte("metadata"))
+		binary.Write(h, binary.LittleEndian, md.Timestamp)
+		binary.Write(h, binary.LittleEndian, uint32(len(fields)))
+		for _, field := range fields {
+			binary.Write(h, binary.LittleEndian, uint32(len(field)))
+			h.Write([]byte(field))
+		}
 	}
 
 	return h.Sum()
@@ -673,9 +885,11 @@ func HashCommit(c *jpb.Commit) string {
 //     internal nodes.
 // 3 - For each edge in EdgeRefs we need to find the corresponding src and dst nodes and insert edges.
 
-func CalculateNodeHashes(commit, prev string, content [][]byte) (head, tail string) {
+// CalculateNodeHashes returns the hashes of the first and last nodes made for content by commit,
+// where prev is the hash of whatever comes just before them.
+func (a HashAlgorithm) CalculateNodeHashes(commit, prev string, content [][]byte) (head, tail string) {
 	for _, line := range content {
-		h := jigStandardHasher()
+		h := a.hasher()
 		binary.Write(h, binary.LittleEndian, uint32(len(commit)))
 		h.Write([]byte(commit))
 		binary.Write(h, binary.LittleEndian, uint32(len(prev)))
@@ -691,15 +905,15 @@ func CalculateNodeHashes(commit, prev string, content [][]byte) (head, tail stri
 }
 
 // A Frontier indicates a view of the repo.  It is used when traversing a file to decide which
-// commits' edges should be used.
-// TODO: Implement this thing, probably want to store which commits the Frontier *doesn't* observe,
-// 		 since that set will typically be much smaller than those it *does* observe.
+// commits' edges should be used.  CommitSet is the usual implementation, CommitSetOf creates one
+// from a list of commits, and FrontierDelta gives the commits in one frontier that aren't in another.
 type Frontier interface {
 	Observes(commit string) (bool, error)
 }
 
-func HashEdge(e *jpb.Edge) string {
-	h := jigStandardHasher()
+// HashEdge hashes e.
+func (a HashAlgorithm) HashEdge(e *jpb.Edge) string {
+	h := a.hasher()
 
 	binary.Write(h, binary.LittleEndian, uint32(len(e.Commit)))
 	h.Write([]byte(e.Commit))
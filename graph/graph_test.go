@@ -2,6 +2,7 @@ package graph_test
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"sort"
 	"testing"
@@ -25,29 +26,100 @@ func contentToString(content [][]byte) string {
 	return string(bytes.Join(content, []byte{'.'}))
 }
 
+// editFile applies a commit, made by ChangeFiles as of f, that changes path to have lines, and returns
+// its hash.  The commit gets md as its metadata, which may be nil.
+func editFile(r graph.Repo, f graph.Frontier, md *jpb.CommitMetadata, path string, lines ...string) string {
+	c, err := graph.ChangeFiles(r, f, nil, []graph.FileChange{
+		{Path: path, Edit: true, Content: stringsToContent(lines...)},
+	})
+	So(err, ShouldBeNil)
+	c.Metadata = md
+	So(graph.Apply(r, c), ShouldBeNil)
+	So(graph.Check(r), ShouldBeEmpty)
+	return r.HashAlgorithm().HashCommit(c)
+}
+
 func nodeContent(r graph.Repo, node string) string {
 	return contentToString(r.GetContent(r.GetNode(node).GetContentHash()))
 }
 
+// next, prev, and conflicting call Next, Prev, and Conflicts on v and check that they succeed.
+func next(v *graph.Verge) []string {
+	nodes, err := v.Next()
+	So(err, ShouldBeNil)
+	return nodes
+}
+
+func prev(v *graph.Verge) []string {
+	nodes, err := v.Prev()
+	So(err, ShouldBeNil)
+	return nodes
+}
+
+func conflicting(v *graph.Verge) []string {
+	commits, err := v.Conflicts()
+	So(err, ShouldBeNil)
+	return commits
+}
+
 func TestNodeHashes(t *testing.T) {
 	Convey("CalculateNodeHashes", t, func() {
 		c0 := stringsToContent("foo", "bar", "wing")
 		c1 := stringsToContent("ding", "monkey", "ball")
-		head, tail := graph.CalculateNodeHashes("commit", "prev", append(c0, c1...))
+		head, tail := graph.DefaultHashAlgorithm.CalculateNodeHashes("commit", "prev", append(c0, c1...))
 		So(head, ShouldNotEqual, tail)
-		head2, middle := graph.CalculateNodeHashes("commit", "prev", c0)
+		head2, middle := graph.DefaultHashAlgorithm.CalculateNodeHashes("commit", "prev", c0)
 		So(head2, ShouldEqual, head)
-		_, tail2 := graph.CalculateNodeHashes("commit", middle, c1)
+		_, tail2 := graph.DefaultHashAlgorithm.CalculateNodeHashes("commit", middle, c1)
 		So(tail2, ShouldEqual, tail)
 	})
 }
 
+func TestCommitMetadata(t *testing.T) {
+	Convey("Commit metadata", t, func() {
+		r := testutils.MakeFakeRepo()
+		c := &jpb.Commit{
+			EdgeRefs: []*jpb.EdgeRef{
+				{
+					Src:    &jpb.NodeRef{Node: "src:foo.txt", Depth: 1},
+					Chunks: stringsToContent("alpha", "bravo"),
+					Dst:    &jpb.NodeRef{Node: "snk:foo.txt"},
+				},
+			},
+		}
+		bare := r.HashAlgorithm().HashCommit(c)
+		c.Metadata = &jpb.CommitMetadata{
+			Author:    "author",
+			Committer: "committer",
+			Timestamp: 1234567890,
+			Message:   "add foo.txt",
+			Headers:   map[string]string{"a": "b", "c": "d"},
+		}
+		withMetadata := r.HashAlgorithm().HashCommit(c)
+
+		Convey("is part of the commit hash", func() {
+			So(withMetadata, ShouldNotEqual, bare)
+			c.Metadata.Headers["c"] = "e"
+			So(r.HashAlgorithm().HashCommit(c), ShouldNotEqual, withMetadata)
+		})
+
+		Convey("is available from the repo", func() {
+			So(graph.Apply(r, c), ShouldBeNil)
+			md := r.GetCommitMetadata(withMetadata)
+			So(md, ShouldNotBeNil)
+			So(md.Author, ShouldEqual, "author")
+			So(md.Message, ShouldEqual, "add foo.txt")
+			So(md.Headers, ShouldResemble, map[string]string{"a": "b", "c": "d"})
+		})
+	})
+}
+
 func TestSplitNode(t *testing.T) {
 	Convey("SplitNode", t, func() {
 		r := testutils.MakeFakeRepo()
 		sampleContent := stringsToContent("alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf")
 		contentHash := r.PutContent(sampleContent)
-		head, tail := graph.CalculateNodeHashes("commit-0", "src:sample.txt", sampleContent)
+		head, tail := r.HashAlgorithm().CalculateNodeHashes("commit-0", "src:sample.txt", sampleContent)
 		r.PutNode(&jpb.Node{
 			Head:    head,
 			Tail:    tail,
@@ -129,12 +201,12 @@ func TestCommits(t *testing.T) {
 		So(err, ShouldBeNil)
 		So(contentToString(data), ShouldEqual, "alpha.bravo.charlie.delta.echo.foxtrot.golf")
 		So(ranges, ShouldHaveLength, 1)
-		So(ranges[0].Commit, ShouldEqual, graph.HashCommit(c0))
+		So(ranges[0].Commit, ShouldEqual, r.HashAlgorithm().HashCommit(c0))
 		head := ranges[0].Node
 
 		Convey("can delete the first line of a file", func() {
 			c1 := &jpb.Commit{
-				Deps: []string{graph.HashCommit(c0)},
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 				EdgeRefs: []*jpb.EdgeRef{
 					{
 						Src: &jpb.NodeRef{
@@ -157,7 +229,7 @@ func TestCommits(t *testing.T) {
 
 		Convey("can modify the first line of a file", func() {
 			c1 := &jpb.Commit{
-				Deps: []string{graph.HashCommit(c0)},
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 				EdgeRefs: []*jpb.EdgeRef{
 					{
 						Src: &jpb.NodeRef{
@@ -180,7 +252,7 @@ func TestCommits(t *testing.T) {
 
 		Convey("can delete the last line of a file", func() {
 			c1 := &jpb.Commit{
-				Deps: []string{graph.HashCommit(c0)},
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 				EdgeRefs: []*jpb.EdgeRef{
 					{
 						Src: &jpb.NodeRef{
@@ -203,7 +275,7 @@ func TestCommits(t *testing.T) {
 
 		Convey("can modify the last line of a file", func() {
 			c1 := &jpb.Commit{
-				Deps: []string{graph.HashCommit(c0)},
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 				EdgeRefs: []*jpb.EdgeRef{
 					{
 						Src: &jpb.NodeRef{
@@ -226,7 +298,7 @@ func TestCommits(t *testing.T) {
 
 		Convey("can move the first line of a file into the middle of the file", func() {
 			c1 := &jpb.Commit{
-				Deps: []string{graph.HashCommit(c0)},
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 				EdgeRefs: []*jpb.EdgeRef{
 					{
 						Src: &jpb.NodeRef{
@@ -271,7 +343,7 @@ func TestCommits(t *testing.T) {
 
 		Convey("can move the last line of a file into the middle of the file", func() {
 			c1 := &jpb.Commit{
-				Deps: []string{graph.HashCommit(c0)},
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 				EdgeRefs: []*jpb.EdgeRef{
 					{
 						Src: &jpb.NodeRef{
@@ -316,7 +388,7 @@ func TestCommits(t *testing.T) {
 
 		Convey("can move the first two lines of a file into the middle of the file", func() {
 			c1 := &jpb.Commit{
-				Deps: []string{graph.HashCommit(c0)},
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 				EdgeRefs: []*jpb.EdgeRef{
 					{
 						Src: &jpb.NodeRef{
@@ -361,7 +433,7 @@ func TestCommits(t *testing.T) {
 
 		Convey("can move the last two lines of a file into the middle of the file", func() {
 			c1 := &jpb.Commit{
-				Deps: []string{graph.HashCommit(c0)},
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 				EdgeRefs: []*jpb.EdgeRef{
 					{
 						Src: &jpb.NodeRef{
@@ -406,7 +478,7 @@ func TestCommits(t *testing.T) {
 
 		Convey("can insert two lines in the middle of the file", func() {
 			c1 := &jpb.Commit{
-				Deps: []string{graph.HashCommit(c0)},
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 				EdgeRefs: []*jpb.EdgeRef{
 					{
 						Src: &jpb.NodeRef{
@@ -433,9 +505,9 @@ func TestCommits(t *testing.T) {
 				_, err := graph.ReadVersion(r, allFrontier{}, "src:foo.txt", "snk:foo.txt", &graph.ReadMetadata{Ranges: &ranges})
 				So(err, ShouldBeNil)
 				var relevant []graph.ReadRange
-				for _, r := range ranges {
-					if r.Commit == graph.HashCommit(c1) {
-						relevant = append(relevant, r)
+				for _, rng := range ranges {
+					if rng.Commit == r.HashAlgorithm().HashCommit(c1) {
+						relevant = append(relevant, rng)
 					}
 				}
 				So(relevant, ShouldHaveLength, 1)
@@ -444,7 +516,7 @@ func TestCommits(t *testing.T) {
 				// Now we're going to delete the line before the thunder.buttons. This will require
 				// creating an edge that points directly at that node.
 				c2 := &jpb.Commit{
-					Deps: []string{graph.HashCommit(c0)},
+					Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 					EdgeRefs: []*jpb.EdgeRef{
 						{
 							Src: &jpb.NodeRef{
@@ -496,7 +568,7 @@ func TestVerge(t *testing.T) {
 
 		// This capitalizes charlie through foxtrot.
 		c1 := &jpb.Commit{
-			Deps: []string{graph.HashCommit(c0)},
+			Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 			EdgeRefs: []*jpb.EdgeRef{
 				{
 					Src: &jpb.NodeRef{
@@ -515,7 +587,7 @@ func TestVerge(t *testing.T) {
 
 		//  This inserts some text between delta and echo.
 		c2 := &jpb.Commit{
-			Deps: []string{graph.HashCommit(c0)},
+			Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 			EdgeRefs: []*jpb.EdgeRef{
 				{
 					Src: &jpb.NodeRef{
@@ -534,7 +606,7 @@ func TestVerge(t *testing.T) {
 
 		// This commit resolves the conflict between c1 and c2.
 		c3 := &jpb.Commit{
-			Deps: []string{graph.HashCommit(c0), graph.HashCommit(c1), graph.HashCommit(c2)},
+			Deps: []string{r.HashAlgorithm().HashCommit(c0), r.HashAlgorithm().HashCommit(c1), r.HashAlgorithm().HashCommit(c2)},
 			EdgeRefs: []*jpb.EdgeRef{
 				{
 					Src: &jpb.NodeRef{
@@ -551,35 +623,44 @@ func TestVerge(t *testing.T) {
 		}
 		So(graph.Apply(r, c3), ShouldBeNil)
 
+		Convey("errors from the frontier are returned rather than panicking", func() {
+			_, err := graph.MakeVerge(r, failingFrontier{}, "foo.txt")
+			So(errors.Is(err, errFrontierFailed), ShouldBeTrue)
+			_, err = graph.FindConflicts(r, failingFrontier{}, "foo.txt")
+			So(errors.Is(err, errFrontierFailed), ShouldBeTrue)
+		})
+
 		Convey("if the frontier doesn't see conflicts then the verge shouldn't see conflicts", func() {
-			v := graph.MakeVerge(r, explicitFrontier(c0, c1), "foo.txt")
+			v, err := graph.MakeVerge(r, explicitFrontier(c0, c1), "foo.txt")
+			So(err, ShouldBeNil)
 			// Should be able to advance until we get to the snk node.
-			for n := v.Next()[0]; n != "snk:foo.txt"; n = v.Next()[0] {
-				v.Advance(n)
-				So(v.Prev(), ShouldContain, r.GetNode(n).Tail)
-				So(len(v.Next()), ShouldBeGreaterThan, 0)
-				So(len(v.Conflicts()), ShouldBeZeroValue)
+			for n := next(v)[0]; n != "snk:foo.txt"; n = next(v)[0] {
+				So(v.Advance(n), ShouldBeNil)
+				So(prev(v), ShouldContain, r.GetNode(n).Tail)
+				So(len(next(v)), ShouldBeGreaterThan, 0)
+				So(len(conflicting(v)), ShouldBeZeroValue)
 			}
 
 			// Should be able to retract until we get to the snk node.
-			for n := v.Prev()[0]; n != "src:foo.txt"; n = v.Prev()[0] {
-				v.Retract(n)
-				So(v.Next(), ShouldContain, r.GetRef(n))
-				So(len(v.Prev()), ShouldBeGreaterThan, 0)
-				So(len(v.Conflicts()), ShouldBeZeroValue)
+			for n := prev(v)[0]; n != "src:foo.txt"; n = prev(v)[0] {
+				So(v.Retract(n), ShouldBeNil)
+				So(next(v), ShouldContain, r.GetRef(n))
+				So(len(prev(v)), ShouldBeGreaterThan, 0)
+				So(len(conflicting(v)), ShouldBeZeroValue)
 			}
 		})
 
 		Convey("if the frontier can see conflicts then the verge should see conflicts", func() {
-			v := graph.MakeVerge(r, explicitFrontier(c0, c1, c2), "foo.txt")
+			v, err := graph.MakeVerge(r, explicitFrontier(c0, c1, c2), "foo.txt")
+			So(err, ShouldBeNil)
 			foundConflict := false
 			// Should be able to advance until we get to the snk node.
-			for n := v.Next()[0]; n != "snk:foo.txt"; n = v.Next()[0] {
-				v.Advance(n)
-				fmt.Printf("prev: %v\n", v.Prev())
-				So(v.Prev(), ShouldContain, r.GetNode(n).Tail)
-				So(len(v.Next()), ShouldBeGreaterThan, 0)
-				if len(v.Conflicts()) > 0 {
+			for n := next(v)[0]; n != "snk:foo.txt"; n = next(v)[0] {
+				So(v.Advance(n), ShouldBeNil)
+				fmt.Printf("prev: %v\n", prev(v))
+				So(prev(v), ShouldContain, r.GetNode(n).Tail)
+				So(len(next(v)), ShouldBeGreaterThan, 0)
+				if len(conflicting(v)) > 0 {
 					foundConflict = true
 				}
 			}
@@ -587,44 +668,45 @@ func TestVerge(t *testing.T) {
 
 			foundConflict = false
 			// Should be able to retract until we get to the snk node.
-			for n := v.Prev()[0]; n != "src:foo.txt"; n = v.Prev()[0] {
-				v.Retract(n)
-				So(v.Next(), ShouldContain, r.GetRef(n))
-				So(len(v.Prev()), ShouldBeGreaterThan, 0)
-				if len(v.Conflicts()) > 0 {
+			for n := prev(v)[0]; n != "src:foo.txt"; n = prev(v)[0] {
+				So(v.Retract(n), ShouldBeNil)
+				So(next(v), ShouldContain, r.GetRef(n))
+				So(len(prev(v)), ShouldBeGreaterThan, 0)
+				if len(conflicting(v)) > 0 {
 					foundConflict = true
-					So(len(v.Conflicts()), ShouldEqual, 2)
-					So(v.Conflicts(), ShouldContain, graph.HashCommit(c1))
-					So(v.Conflicts(), ShouldContain, graph.HashCommit(c2))
+					So(len(conflicting(v)), ShouldEqual, 2)
+					So(conflicting(v), ShouldContain, r.HashAlgorithm().HashCommit(c1))
+					So(conflicting(v), ShouldContain, r.HashAlgorithm().HashCommit(c2))
 				}
 			}
 			So(foundConflict, ShouldBeTrue)
 		})
 
 		Convey("if the frontier can see a commit that resolves a conflict then it shouldn't see the conflict", func() {
-			v := graph.MakeVerge(r, explicitFrontier(c0, c1, c2, c3), "foo.txt")
+			v, err := graph.MakeVerge(r, explicitFrontier(c0, c1, c2, c3), "foo.txt")
+			So(err, ShouldBeNil)
 			// Should be able to advance until we get to the snk node.
-			for n := v.Next()[0]; n != "snk:foo.txt"; n = v.Next()[0] {
-				v.Advance(n)
-				fmt.Printf("prev: %v\n", v.Prev())
-				So(v.Prev(), ShouldContain, r.GetNode(n).Tail)
-				So(len(v.Next()), ShouldBeGreaterThan, 0)
-				So(len(v.Conflicts()), ShouldBeZeroValue)
+			for n := next(v)[0]; n != "snk:foo.txt"; n = next(v)[0] {
+				So(v.Advance(n), ShouldBeNil)
+				fmt.Printf("prev: %v\n", prev(v))
+				So(prev(v), ShouldContain, r.GetNode(n).Tail)
+				So(len(next(v)), ShouldBeGreaterThan, 0)
+				So(len(conflicting(v)), ShouldBeZeroValue)
 			}
 
 			// Should be able to retract until we get to the snk node.
-			for n := v.Prev()[0]; n != "src:foo.txt"; n = v.Prev()[0] {
-				v.Retract(n)
-				So(v.Next(), ShouldContain, r.GetRef(n))
-				So(len(v.Prev()), ShouldBeGreaterThan, 0)
-				So(len(v.Conflicts()), ShouldBeZeroValue)
+			for n := prev(v)[0]; n != "src:foo.txt"; n = prev(v)[0] {
+				So(v.Retract(n), ShouldBeNil)
+				So(next(v), ShouldContain, r.GetRef(n))
+				So(len(prev(v)), ShouldBeGreaterThan, 0)
+				So(len(conflicting(v)), ShouldBeZeroValue)
 			}
 		})
 
 		Convey("advancement functions can find conflicts", func() {
 			// This inserts some text between delta and echo, just like c2, but in all caps.
 			c2x := &jpb.Commit{
-				Deps: []string{graph.HashCommit(c0)},
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 				EdgeRefs: []*jpb.EdgeRef{
 					{
 						Src: &jpb.NodeRef{
@@ -643,7 +725,7 @@ func TestVerge(t *testing.T) {
 
 			// Deletes 'hotel'
 			c4 := &jpb.Commit{
-				Deps: []string{graph.HashCommit(c0)},
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 				EdgeRefs: []*jpb.EdgeRef{
 					{
 						Src: &jpb.NodeRef{
@@ -659,12 +741,13 @@ func TestVerge(t *testing.T) {
 			}
 			So(graph.Apply(r, c4), ShouldBeNil)
 
-			v := graph.MakeVerge(r, explicitFrontier(c0, c1, c2, c2x, c4), "foo.txt")
+			v, err := graph.MakeVerge(r, explicitFrontier(c0, c1, c2, c2x, c4), "foo.txt")
+			So(err, ShouldBeNil)
 			allConflicts := make(map[string]bool)
-			for n := v.Next()[0]; n != "snk:foo.txt"; n = v.Next()[0] {
-				v.Advance(n)
-				for _, c0 := range v.Conflicts() {
-					for _, c1 := range v.Conflicts() {
+			for n := next(v)[0]; n != "snk:foo.txt"; n = next(v)[0] {
+				So(v.Advance(n), ShouldBeNil)
+				for _, c0 := range conflicting(v) {
+					for _, c1 := range conflicting(v) {
 						if c0 == c1 {
 							continue
 						}
@@ -676,7 +759,7 @@ func TestVerge(t *testing.T) {
 
 			// capitalizes 'bravo' and 'charlie'
 			c5a := &jpb.Commit{
-				Deps: []string{graph.HashCommit(c0)},
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 				EdgeRefs: []*jpb.EdgeRef{
 					{
 						Src: &jpb.NodeRef{
@@ -695,7 +778,7 @@ func TestVerge(t *testing.T) {
 
 			// capitalizes 'echo' and 'foxtrot'
 			c5b := &jpb.Commit{
-				Deps: []string{graph.HashCommit(c0)},
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 				EdgeRefs: []*jpb.EdgeRef{
 					{
 						Src: &jpb.NodeRef{
@@ -714,7 +797,7 @@ func TestVerge(t *testing.T) {
 
 			// munges 'bravo', 'charlie', and 'delta'
 			c6a := &jpb.Commit{
-				Deps: []string{graph.HashCommit(c0)},
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 				EdgeRefs: []*jpb.EdgeRef{
 					{
 						Src: &jpb.NodeRef{
@@ -733,7 +816,7 @@ func TestVerge(t *testing.T) {
 
 			// munges 'echo' and 'foxtrot'
 			c6b := &jpb.Commit{
-				Deps: []string{graph.HashCommit(c0)},
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 				EdgeRefs: []*jpb.EdgeRef{
 					{
 						Src: &jpb.NodeRef{
@@ -759,15 +842,18 @@ func TestVerge(t *testing.T) {
 			var conflictsList []string
 			var conflicts map[string]bool
 			Convey("we can find conflicts by going forward and then backward", func() {
-				v := graph.MakeVerge(r, f, "foo.txt")
-				for n := v.Next()[0]; len(v.Conflicts()) == 0; n = v.Next()[0] {
-					v.Advance(n)
+				v, err := graph.MakeVerge(r, f, "foo.txt")
+				So(err, ShouldBeNil)
+				for n := next(v)[0]; len(conflicting(v)) == 0; n = next(v)[0] {
+					So(v.Advance(n), ShouldBeNil)
 					fmt.Printf("%v\n", v)
 				}
-				end, _ = v.AdvanceUntilConverged()
+				end, _, err = v.AdvanceUntilConverged()
+				So(err, ShouldBeNil)
 				cont := r.GetContent(r.GetNode(end).GetContentHash())
 				So(string(cont[0]), ShouldEqual, "golf")
-				start, conflicts = v.RetractUntilConverged()
+				start, conflicts, err = v.RetractUntilConverged()
+				So(err, ShouldBeNil)
 				cont = r.GetContent(r.GetNode(r.GetRef(start)).GetContentHash())
 				So(string(cont[len(cont)-1]), ShouldEqual, "alpha")
 				for c := range conflicts {
@@ -775,37 +861,40 @@ func TestVerge(t *testing.T) {
 				}
 			})
 			Convey("we can find conflicts by going backward and then forward", func() {
-				v := graph.MakeVerge(r, f, "foo.txt")
-				for n := v.Next()[0]; len(v.Conflicts()) == 0; n = v.Next()[0] {
-					v.Advance(n)
+				v, err := graph.MakeVerge(r, f, "foo.txt")
+				So(err, ShouldBeNil)
+				for n := next(v)[0]; len(conflicting(v)) == 0; n = next(v)[0] {
+					So(v.Advance(n), ShouldBeNil)
 					fmt.Printf("%v\n", v)
 				}
-				start, _ = v.RetractUntilConverged()
+				start, _, err = v.RetractUntilConverged()
+				So(err, ShouldBeNil)
 				startRef := r.GetRef(start)
 				cont := r.GetContent(r.GetNode(startRef).GetContentHash())
 				So(string(cont[0]), ShouldEqual, "alpha")
-				end, conflicts = v.AdvanceUntilConverged()
+				end, conflicts, err = v.AdvanceUntilConverged()
+				So(err, ShouldBeNil)
 				cont = r.GetContent(r.GetNode(end).GetContentHash())
 				So(string(cont[len(cont)-1]), ShouldEqual, "golf")
 				for c := range conflicts {
 					conflictsList = append(conflictsList, c)
 				}
 			})
-			So(conflictsList, ShouldNotContain, graph.HashCommit(c0))
-			So(conflictsList, ShouldNotContain, graph.HashCommit(c1))
-			So(conflictsList, ShouldNotContain, graph.HashCommit(c2))
-			So(conflictsList, ShouldNotContain, graph.HashCommit(c2x))
-			So(conflictsList, ShouldNotContain, graph.HashCommit(c4))
-			So(conflictsList, ShouldContain, graph.HashCommit(c5a))
-			So(conflictsList, ShouldContain, graph.HashCommit(c5b))
-			So(conflictsList, ShouldContain, graph.HashCommit(c6a))
-			So(conflictsList, ShouldContain, graph.HashCommit(c6b))
+			So(conflictsList, ShouldNotContain, r.HashAlgorithm().HashCommit(c0))
+			So(conflictsList, ShouldNotContain, r.HashAlgorithm().HashCommit(c1))
+			So(conflictsList, ShouldNotContain, r.HashAlgorithm().HashCommit(c2))
+			So(conflictsList, ShouldNotContain, r.HashAlgorithm().HashCommit(c2x))
+			So(conflictsList, ShouldNotContain, r.HashAlgorithm().HashCommit(c4))
+			So(conflictsList, ShouldContain, r.HashAlgorithm().HashCommit(c5a))
+			So(conflictsList, ShouldContain, r.HashAlgorithm().HashCommit(c5b))
+			So(conflictsList, ShouldContain, r.HashAlgorithm().HashCommit(c6a))
+			So(conflictsList, ShouldContain, r.HashAlgorithm().HashCommit(c6b))
 			groups := [][]string{
 				{},
-				{graph.HashCommit(c5a), graph.HashCommit(c5b)},
-				{graph.HashCommit(c6a), graph.HashCommit(c6b)},
+				{r.HashAlgorithm().HashCommit(c5a), r.HashAlgorithm().HashCommit(c5b)},
+				{r.HashAlgorithm().HashCommit(c6a), r.HashAlgorithm().HashCommit(c6b)},
 			}
-			versions, err := graph.ReadVersions(r, f, explicitFrontier(c0, c5a, c5b), r.GetRef(start), end, groups, []byte("."))
+			versions, err := graph.ReadVersions(r, f, r.GetRef(start), end, groups, []byte("."))
 			So(err, ShouldBeNil)
 			So(versions, ShouldNotBeNil)
 			So(len(versions), ShouldEqual, 3)
@@ -821,12 +910,12 @@ func TestVerge(t *testing.T) {
 					So(len(versions[i].Commits), ShouldEqual, 0)
 				} else if s == "alpha.BRAVO.CHARLIE.delta.ECHO.FOXTROT.golf" {
 					So(len(versions[i].Commits), ShouldEqual, 2)
-					So(versions[i].Commits[graph.HashCommit(c5a)], ShouldBeTrue)
-					So(versions[i].Commits[graph.HashCommit(c5b)], ShouldBeTrue)
+					So(versions[i].Commits[r.HashAlgorithm().HashCommit(c5a)], ShouldBeTrue)
+					So(versions[i].Commits[r.HashAlgorithm().HashCommit(c5b)], ShouldBeTrue)
 				} else if s == "alpha.brAvO.chArlIE.dEltA.echo.fOxtrOt.golf" {
 					So(len(versions[i].Commits), ShouldEqual, 2)
-					So(versions[i].Commits[graph.HashCommit(c6a)], ShouldBeTrue)
-					So(versions[i].Commits[graph.HashCommit(c6b)], ShouldBeTrue)
+					So(versions[i].Commits[r.HashAlgorithm().HashCommit(c6a)], ShouldBeTrue)
+					So(versions[i].Commits[r.HashAlgorithm().HashCommit(c6b)], ShouldBeTrue)
 				} else {
 					t.Errorf("unexpected version %q", s)
 				}
@@ -859,7 +948,7 @@ func TestSplitNodeProperties(t *testing.T) {
 		So(snippet{r, explicitFrontier(c0), "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.bravo.charlie.delta.echo")
 
 		c1 := &jpb.Commit{
-			Deps: []string{graph.HashCommit(c0)},
+			Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 			EdgeRefs: []*jpb.EdgeRef{
 				{
 					Src: &jpb.NodeRef{
@@ -878,7 +967,7 @@ func TestSplitNodeProperties(t *testing.T) {
 		So(snippet{r, explicitFrontier(c0, c1), "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.brAvO.chArlIE.dEltA.echo")
 
 		c2 := &jpb.Commit{
-			Deps: []string{graph.HashCommit(c0)},
+			Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 			EdgeRefs: []*jpb.EdgeRef{
 				{
 					Src: &jpb.NodeRef{
@@ -901,7 +990,7 @@ func TestSplitNodeProperties(t *testing.T) {
 		So(conflicts, ShouldHaveLength, 1)
 
 		c3 := &jpb.Commit{
-			Deps: []string{graph.HashCommit(c0)},
+			Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 			EdgeRefs: []*jpb.EdgeRef{
 				{
 					Src: &jpb.NodeRef{
@@ -926,14 +1015,14 @@ func TestSplitNodeProperties(t *testing.T) {
 		_, err = graph.ReadVersion(r, explicitFrontier(c0, c1, c2, c3), "src:foo.txt", "snk:foo.txt", metadata)
 		So(err, ShouldBeNil)
 		var n string
-		for _, r := range *metadata.Ranges {
-			if r.Commit == graph.HashCommit(c3) {
-				n = r.Node
+		for _, rng := range *metadata.Ranges {
+			if rng.Commit == r.HashAlgorithm().HashCommit(c3) {
+				n = rng.Node
 			}
 		}
 		So(n, ShouldNotEqual, "")
 		c4 := &jpb.Commit{
-			Deps: []string{graph.HashCommit(c0), graph.HashCommit(c1), graph.HashCommit(c2), graph.HashCommit(c3)},
+			Deps: []string{r.HashAlgorithm().HashCommit(c0), r.HashAlgorithm().HashCommit(c1), r.HashAlgorithm().HashCommit(c2), r.HashAlgorithm().HashCommit(c3)},
 			EdgeRefs: []*jpb.EdgeRef{
 				{
 					Src: &jpb.NodeRef{
@@ -966,7 +1055,7 @@ func TestSplitNodeProperties(t *testing.T) {
 
 		// c5 is irrelevant, but it will split a node that c3 must keep its edges on
 		c5 := &jpb.Commit{
-			Deps: []string{graph.HashCommit(c3)},
+			Deps: []string{r.HashAlgorithm().HashCommit(c3)},
 			EdgeRefs: []*jpb.EdgeRef{
 				{
 					Src: &jpb.NodeRef{
@@ -984,7 +1073,7 @@ func TestSplitNodeProperties(t *testing.T) {
 		So(graph.Apply(r, c5), ShouldBeNil)
 		So(snippet{r, explicitFrontier(c0, c1, c2, c3, c5), "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.BRAVO.CHARLIE.i do not belong here.DELTA.echo")
 
-		fmt.Printf("c3(%s)\nc4(%s)\n", graph.HashCommit(c3), graph.HashCommit(c4))
+		fmt.Printf("c3(%s)\nc4(%s)\n", r.HashAlgorithm().HashCommit(c3), r.HashAlgorithm().HashCommit(c4))
 		// This line is failing because SplitNode doesn't properly propagate edges, we probably need
 		// a new way to indicate that an edge should propagate completely through a node.
 		conflicts, err = graph.FindConflicts(r, explicitFrontier(c0, c1, c2, c3, c4), "foo.txt")
@@ -992,6 +1081,64 @@ func TestSplitNodeProperties(t *testing.T) {
 		So(conflicts, ShouldHaveLength, 0)
 	})
 }
+func TestReadFile(t *testing.T) {
+	Convey("ReadFile reports the state of a file", t, func() {
+		r := testutils.MakeFakeRepo()
+		c0, err := graph.ChangeFiles(r, allFrontier{}, nil, []graph.FileChange{
+			{Path: "foo.txt", Content: stringsToContent("alpha", "bravo", "charlie")},
+			{Path: "empty.txt"},
+		})
+		So(err, ShouldBeNil)
+		So(graph.Apply(r, c0), ShouldBeNil)
+		f0 := explicitFrontier(c0)
+
+		shouldHaveState := func(f graph.Frontier, path string, state graph.FileState, content string) {
+			v, err := graph.ReadFile(r, f, path, nil)
+			So(err, ShouldBeNil)
+			So(v.State, ShouldEqual, state)
+			So(contentToString(v.Lines), ShouldEqual, content)
+		}
+
+		shouldHaveState(f0, "foo.txt", graph.FilePresent, "alpha.bravo.charlie")
+		shouldHaveState(f0, "empty.txt", graph.FileEmpty, "")
+		shouldHaveState(f0, "missing.txt", graph.FileAbsent, "")
+		shouldHaveState(explicitFrontier(), "foo.txt", graph.FileAbsent, "")
+
+		c1, err := graph.ChangeFiles(r, f0, []string{r.HashAlgorithm().HashCommit(c0)}, []graph.FileChange{
+			{Path: "foo.txt", Delete: true},
+		})
+		So(err, ShouldBeNil)
+		So(graph.Apply(r, c1), ShouldBeNil)
+		shouldHaveState(explicitFrontier(c0, c1), "foo.txt", graph.FileDeleted, "")
+		_, err = graph.ReadVersion(r, explicitFrontier(c0, c1), "src:foo.txt", "snk:foo.txt", nil)
+		So(errors.Is(err, graph.ErrDeleted), ShouldBeTrue)
+		_, err = graph.ReadVersion(r, explicitFrontier(), "src:foo.txt", "snk:foo.txt", nil)
+		So(errors.Is(err, graph.ErrNoObserve), ShouldBeTrue)
+
+		var edits []*jpb.Commit
+		for _, line := range []string{"BRAVO", "bRaVo"} {
+			c := &jpb.Commit{
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
+				EdgeRefs: []*jpb.EdgeRef{
+					{
+						Src:    &jpb.NodeRef{Node: "src:foo.txt", Depth: 2},
+						Chunks: stringsToContent(line),
+						Dst:    &jpb.NodeRef{Node: "src:foo.txt", Depth: 3},
+					},
+				},
+			}
+			So(graph.Apply(r, c), ShouldBeNil)
+			edits = append(edits, c)
+		}
+		shouldHaveState(explicitFrontier(c0, edits[0]), "foo.txt", graph.FilePresent, "alpha.BRAVO.charlie")
+		v, err := graph.ReadFile(r, explicitFrontier(c0, edits[0], edits[1]), "foo.txt", nil)
+		So(err, ShouldBeNil)
+		So(v.State, ShouldEqual, graph.FileConflicted)
+		So(v.Conflicts, ShouldHaveLength, 1)
+		So(v.Lines, ShouldNotBeEmpty)
+	})
+}
+
 func TestReadVersions(t *testing.T) {
 	Convey("ReadVersions", t, func() {
 		r := testutils.MakeFakeRepo()
@@ -1017,11 +1164,11 @@ func TestReadVersions(t *testing.T) {
 		So(contentToString(data), ShouldEqual, "alpha.bravo.charlie.delta.echo.foxtrot.golf.hotel.india.juliet")
 		So(ranges, ShouldHaveLength, 1)
 		head := ranges[0].Node
-		fmt.Printf("c0: %v\n", graph.HashCommit(c0))
+		fmt.Printf("c0: %v\n", r.HashAlgorithm().HashCommit(c0))
 
 		// capitalize bravo through delta
 		c1 := &jpb.Commit{
-			Deps: []string{graph.HashCommit(c0)},
+			Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 			EdgeRefs: []*jpb.EdgeRef{
 				{
 					Src: &jpb.NodeRef{
@@ -1037,12 +1184,12 @@ func TestReadVersions(t *testing.T) {
 			},
 		}
 		So(graph.Apply(r, c1), ShouldBeNil)
-		fmt.Printf("c1: %v\n", graph.HashCommit(c1))
+		fmt.Printf("c1: %v\n", r.HashAlgorithm().HashCommit(c1))
 		So(snippet{r, explicitFrontier(c0, c1), "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.BRAVO.CHARLIE.DELTA.echo.foxtrot.golf.hotel.india.juliet")
 
 		// capitalize charlie through echo
 		c2 := &jpb.Commit{
-			Deps: []string{graph.HashCommit(c0)},
+			Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 			EdgeRefs: []*jpb.EdgeRef{
 				{
 					Src: &jpb.NodeRef{
@@ -1058,12 +1205,12 @@ func TestReadVersions(t *testing.T) {
 			},
 		}
 		So(graph.Apply(r, c2), ShouldBeNil)
-		fmt.Printf("c2: %v\n", graph.HashCommit(c2))
+		fmt.Printf("c2: %v\n", r.HashAlgorithm().HashCommit(c2))
 		So(snippet{r, explicitFrontier(c0, c2), "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.bravo.CHARLIE.DELTA.ECHO.foxtrot.golf.hotel.india.juliet")
 
 		// capitalize juliet
 		c3 := &jpb.Commit{
-			Deps: []string{graph.HashCommit(c0)},
+			Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 			EdgeRefs: []*jpb.EdgeRef{
 				{
 					Src: &jpb.NodeRef{
@@ -1079,11 +1226,11 @@ func TestReadVersions(t *testing.T) {
 		}
 		So(graph.Apply(r, c3), ShouldBeNil)
 		So(snippet{r, explicitFrontier(c0, c3), "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.bravo.charlie.delta.echo.foxtrot.golf.hotel.india.JULIET")
-		fmt.Printf("c3: %v\n", graph.HashCommit(c3))
+		fmt.Printf("c3: %v\n", r.HashAlgorithm().HashCommit(c3))
 
 		// capitalize india and delete juliet
 		c4 := &jpb.Commit{
-			Deps: []string{graph.HashCommit(c0)},
+			Deps: []string{r.HashAlgorithm().HashCommit(c0)},
 			EdgeRefs: []*jpb.EdgeRef{
 				{
 					Src: &jpb.NodeRef{
@@ -1107,11 +1254,11 @@ func TestReadVersions(t *testing.T) {
 			So(err, ShouldBeNil)
 			So(conflicts, ShouldHaveLength, 1)
 			So(conflicts[0].Commits, ShouldHaveLength, 2)
-			So(conflicts[0].Commits, ShouldContainKey, graph.HashCommit(c1))
-			So(conflicts[0].Commits, ShouldContainKey, graph.HashCommit(c2))
+			So(conflicts[0].Commits, ShouldContainKey, r.HashAlgorithm().HashCommit(c1))
+			So(conflicts[0].Commits, ShouldContainKey, r.HashAlgorithm().HashCommit(c2))
 			So(snippet{r, explicitFrontier(c0, c1), conflicts[0].Start, conflicts[0].End}, shouldRead, "alpha.BRAVO.CHARLIE.DELTA.echo.foxtrot")
 			So(snippet{r, explicitFrontier(c0, c2), conflicts[0].Start, conflicts[0].End}, shouldRead, "alpha.bravo.CHARLIE.DELTA.ECHO.foxtrot")
-			versions, err := graph.ReadVersions(r, allFrontier{}, nil, conflicts[0].Start, conflicts[0].End, conflicts[0].Groups, []byte{'.'})
+			versions, err := graph.ReadVersions(r, allFrontier{}, conflicts[0].Start, conflicts[0].End, conflicts[0].Groups, []byte{'.'})
 			So(err, ShouldBeNil)
 			So(versions, ShouldHaveLength, 2)
 		}
@@ -1120,11 +1267,11 @@ func TestReadVersions(t *testing.T) {
 			So(err, ShouldBeNil)
 			So(conflicts, ShouldHaveLength, 1)
 			So(conflicts[0].Commits, ShouldHaveLength, 2)
-			So(conflicts[0].Commits, ShouldContainKey, graph.HashCommit(c3))
-			So(conflicts[0].Commits, ShouldContainKey, graph.HashCommit(c4))
+			So(conflicts[0].Commits, ShouldContainKey, r.HashAlgorithm().HashCommit(c3))
+			So(conflicts[0].Commits, ShouldContainKey, r.HashAlgorithm().HashCommit(c4))
 			So(snippet{r, explicitFrontier(c0, c3), conflicts[0].Start, conflicts[0].End}, shouldRead, "hotel.india.JULIET")
 			So(snippet{r, explicitFrontier(c0, c4), conflicts[0].Start, conflicts[0].End}, shouldRead, "hotel.INDIA")
-			versions, err := graph.ReadVersions(r, allFrontier{}, nil, conflicts[0].Start, conflicts[0].End, conflicts[0].Groups, []byte{'.'})
+			versions, err := graph.ReadVersions(r, allFrontier{}, conflicts[0].Start, conflicts[0].End, conflicts[0].Groups, []byte{'.'})
 			So(err, ShouldBeNil)
 			So(versions, ShouldHaveLength, 2)
 		}
@@ -1136,9 +1283,9 @@ func TestReadVersions(t *testing.T) {
 		_, err = graph.ReadVersion(r, explicitFrontier(c0, c1), "src:foo.txt", "snk:foo.txt", metadata)
 		So(err, ShouldBeNil)
 		var n1 string
-		for _, r := range *metadata.Ranges {
-			if r.Commit == graph.HashCommit(c1) {
-				n1 = r.Node
+		for _, rng := range *metadata.Ranges {
+			if rng.Commit == r.HashAlgorithm().HashCommit(c1) {
+				n1 = rng.Node
 			}
 		}
 		So(n1, ShouldNotEqual, "")
@@ -1150,7 +1297,7 @@ func TestReadVersions(t *testing.T) {
 		// commits, but also the parts covered by only one where the verge can detect the conflict beginning
 		// or ending.
 		cR12a := &jpb.Commit{
-			Deps: []string{graph.HashCommit(c0), graph.HashCommit(c1), graph.HashCommit(c2)},
+			Deps: []string{r.HashAlgorithm().HashCommit(c0), r.HashAlgorithm().HashCommit(c1), r.HashAlgorithm().HashCommit(c2)},
 			EdgeRefs: []*jpb.EdgeRef{
 				{
 					// This edge is required to make sure that the verge doesn't detect a conflict
@@ -1190,13 +1337,57 @@ func TestReadVersions(t *testing.T) {
 		}
 		So(graph.Apply(r, cR12a), ShouldBeNil)
 		fmt.Printf("Relevant commits:\n")
-		for _, c := range []string{graph.HashCommit(c0), graph.HashCommit(c1), graph.HashCommit(c2), graph.HashCommit(cR12a)} {
+		for _, c := range []string{r.HashAlgorithm().HashCommit(c0), r.HashAlgorithm().HashCommit(c1), r.HashAlgorithm().HashCommit(c2), r.HashAlgorithm().HashCommit(cR12a)} {
 			fmt.Printf("  %s\n", c)
 		}
 		So(snippet{r, explicitFrontier(c0, c1, c2, cR12a), "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.bravo.CHARLIE.DELTA.echo.foxtrot.golf.hotel.india.juliet")
 	})
 }
 
+func TestHumanReadable(t *testing.T) {
+	Convey("HumanReadable", t, func() {
+		r := testutils.MakeFakeRepo()
+		c0, err := graph.ChangeFiles(r, allFrontier{}, nil, []graph.FileChange{
+			{Path: "a.txt", Content: stringsToContent("alpha", "bravo", "charlie", "delta")},
+		})
+		So(err, ShouldBeNil)
+		So(graph.Apply(r, c0), ShouldBeNil)
+		var commits []string
+		for _, line := range []string{"BRAVO", "bravo!"} {
+			c, err := graph.ChangeFiles(r, explicitFrontier(c0), nil, []graph.FileChange{
+				{Path: "a.txt", Edit: true, Content: stringsToContent("alpha", line, "charlie", "delta")},
+			})
+			So(err, ShouldBeNil)
+			So(graph.Apply(r, c), ShouldBeNil)
+			commits = append(commits, r.HashAlgorithm().HashCommit(c))
+		}
+		f := explicitFrontierStrings(append(commits, r.HashAlgorithm().HashCommit(c0))...)
+		conflicts, err := graph.FindConflicts(r, f, "a.txt")
+		So(err, ShouldBeNil)
+		So(conflicts, ShouldHaveLength, 1)
+
+		Convey("reads the base of a conflict", func() {
+			base, err := graph.ReadBase(r, f, conflicts[0], []byte("."))
+			So(err, ShouldBeNil)
+			So(string(base.Data), ShouldEqual, "alpha.bravo.charlie")
+			So(base.Commits, ShouldBeEmpty)
+		})
+
+		Convey("shows the base only when asked to", func() {
+			data, err := graph.HumanReadable(r, f, "a.txt", conflicts, []byte("\n"), false)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldNotContainSubstring, "|||||||")
+			So(string(data), ShouldContainSubstring, "alpha\nBRAVO\ncharlie")
+			So(string(data), ShouldContainSubstring, "alpha\nbravo!\ncharlie")
+
+			data, err = graph.HumanReadable(r, f, "a.txt", conflicts, []byte("\n"), true)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldStartWith, "<<<<<<<\n||||||| base\nalpha\nbravo\ncharlie\n=======")
+			So(string(data), ShouldEndWith, ">>>>>>>\ndelta")
+		})
+	})
+}
+
 type snippet struct {
 	r     graph.Repo
 	f     graph.Frontier
@@ -1231,10 +1422,12 @@ type allFrontier struct{}
 
 func (allFrontier) Observes(string) (bool, error) { return true, nil }
 
+// explicitFrontier observes commits, which must have been applied to a repo made by
+// testutils.MakeFakeRepo, which uses graph.DefaultHashAlgorithm.
 func explicitFrontier(commits ...*jpb.Commit) simpleFrontier {
 	s := make(simpleFrontier)
 	for _, c := range commits {
-		s[graph.HashCommit(c)] = true
+		s[graph.DefaultHashAlgorithm.HashCommit(c)] = true
 	}
 	return s
 }
@@ -1247,6 +1440,13 @@ func explicitFrontierStrings(commits ...string) simpleFrontier {
 	return s
 }
 
+var errFrontierFailed = errors.New("frontier failed")
+
+// failingFrontier can't tell whether it observes anything.
+type failingFrontier struct{}
+
+func (failingFrontier) Observes(string) (bool, error) { return false, errFrontierFailed }
+
 type simpleFrontier map[string]bool
 
 func (s simpleFrontier) Observes(c string) (bool, error) { return s[c], nil }
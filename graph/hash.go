@@ -0,0 +1,73 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// A HashAlgorithm determines how node, content, and commit hashes are calculated.  Every hash other
+// than those made with Shake128_32 starts with a prefix that identifies the algorithm that made it.
+type HashAlgorithm string
+
+const (
+	// Shake128_32 is the original algorithm.  Its hashes are only 32 bits, so collisions are likely
+	// in any repo of real size, and it exists only so that old repos can be read and migrated.
+	Shake128_32 HashAlgorithm = "shake128-32"
+
+	Shake256 HashAlgorithm = "shake256"
+	SHA3_256 HashAlgorithm = "sha3-256"
+
+	// DefaultHashAlgorithm is used for new repos.
+	DefaultHashAlgorithm = Shake256
+)
+
+var hashAlgorithms = map[HashAlgorithm]struct {
+	prefix string
+	sum    func(data []byte) []byte
+}{
+	Shake128_32: {"", func(data []byte) []byte {
+		b := make([]byte, 4)
+		sha3.ShakeSum128(b, data)
+		return b
+	}},
+	Shake256: {"shake256:", func(data []byte) []byte {
+		b := make([]byte, 32)
+		sha3.ShakeSum256(b, data)
+		return b
+	}},
+	SHA3_256: {"sha3-256:", func(data []byte) []byte {
+		b := sha3.Sum256(data)
+		return b[:]
+	}},
+}
+
+// ParseHashAlgorithm returns the HashAlgorithm with the specified name.
+func ParseHashAlgorithm(name string) (HashAlgorithm, error) {
+	if _, ok := hashAlgorithms[HashAlgorithm(name)]; !ok {
+		return "", fmt.Errorf("unknown hash algorithm %q, must be one of %v", name, HashAlgorithms())
+	}
+	return HashAlgorithm(name), nil
+}
+
+// HashAlgorithms returns the names of all supported hash algorithms.
+func HashAlgorithms() []string {
+	var names []string
+	for alg := range hashAlgorithms {
+		names = append(names, string(alg))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HashAlgorithmOf returns the algorithm that was used to make hash.
+func HashAlgorithmOf(hash string) HashAlgorithm {
+	for alg, spec := range hashAlgorithms {
+		if spec.prefix != "" && strings.HasPrefix(hash, spec.prefix) {
+			return alg
+		}
+	}
+	return Shake128_32
+}
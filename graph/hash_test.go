@@ -0,0 +1,49 @@
+package graph_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/runningwild/jig/graph"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHashAlgorithms(t *testing.T) {
+	Convey("Hash algorithms", t, func() {
+		content := stringsToContent("alpha", "bravo")
+
+		Convey("prefix their hashes with their name", func() {
+			for _, alg := range []graph.HashAlgorithm{graph.Shake256, graph.SHA3_256} {
+				h := alg.HashContent(content)
+				So(h, ShouldEqual, string(alg)+":"+h[len(alg)+1:])
+				So(h[len(alg)+1:], ShouldHaveLength, 64)
+				So(graph.HashAlgorithmOf(h), ShouldEqual, alg)
+			}
+		})
+
+		Convey("include the legacy algorithm, which is unprefixed", func() {
+			h := graph.Shake128_32.HashContent(content)
+			So(h, ShouldHaveLength, 8)
+			So(strings.Contains(h, ":"), ShouldBeFalse)
+			So(graph.HashAlgorithmOf(h), ShouldEqual, graph.Shake128_32)
+		})
+
+		Convey("all produce different hashes", func() {
+			seen := make(map[string]bool)
+			for _, name := range graph.HashAlgorithms() {
+				alg, err := graph.ParseHashAlgorithm(name)
+				So(err, ShouldBeNil)
+				h := alg.HashContent(content)
+				So(seen[h], ShouldBeFalse)
+				seen[h] = true
+			}
+			So(seen, ShouldHaveLength, 3)
+		})
+
+		Convey("can't be parsed from unknown names", func() {
+			_, err := graph.ParseHashAlgorithm("md5")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
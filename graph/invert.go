@@ -0,0 +1,64 @@
+package graph
+
+import (
+	"fmt"
+
+	jpb "github.com/runningwild/jig/proto"
+)
+
+// Invert returns a commit that cancels the effect of the commit specified by commitHash.  Unlike
+// Unapply it doesn't modify r, the returned commit depends on the original and can be applied and
+// shared like any other commit.
+//
+// Readers always take the last edge out of a line that they observe, so for every EdgeRef in the
+// original commit the inverse adds an edge from the same line to wherever that line led before the
+// original commit, as seen by the original commit's deps.  Inverting the commit that created a file
+// deletes it.
+func Invert(r Repo, commitHash string) (*jpb.Commit, error) {
+	c := r.GetCommit(commitHash)
+	if c == nil {
+		return nil, fmt.Errorf("commit %q: %w", commitHash, ErrUnknownCommit)
+	}
+	base := depClosure(r, c.Deps)
+
+	inv := &jpb.Commit{Deps: []string{commitHash}}
+	for i, e := range c.EdgeRefs {
+		line, err := lineHash(r, e.GetSrc().GetNode(), e.GetSrc().GetDepth())
+		if err != nil {
+			return nil, fmt.Errorf("EdgeRef %d: %w", i, err)
+		}
+		n := r.GetNode(r.GetRef(line))
+		if n == nil {
+			return nil, fmt.Errorf("EdgeRef %d: line %q: %w", i, line, ErrMissingNode)
+		}
+
+		var next *jpb.Node
+		for j := len(n.Out) - 1; j >= 0 && next == nil; j-- {
+			if base[n.Out[j].Commit] {
+				next = r.GetNode(n.Out[j].Node)
+			}
+		}
+		var dst *jpb.NodeRef
+		switch {
+		case next == nil && n.GetSrc() != nil:
+			// The original commit created this file.
+			dst = &jpb.NodeRef{Node: DeletedNode}
+		case next == nil && n.GetSnk() != nil:
+			// The original commit renamed this file, which is undone by inverting the edge out of
+			// the new name's src node.  Nothing will follow this edge once that is done.
+			continue
+		case next == nil:
+			return nil, fmt.Errorf("EdgeRef %d: line %q doesn't lead anywhere without this commit", i, line)
+		case next.GetSnk() != nil:
+			dst = &jpb.NodeRef{Node: next.Head}
+		default:
+			ref, depth := nodeRef(r, next)
+			dst = &jpb.NodeRef{Node: ref, Depth: int32(depth)}
+		}
+		inv.EdgeRefs = append(inv.EdgeRefs, &jpb.EdgeRef{
+			Src: &jpb.NodeRef{Node: e.Src.Node, Depth: e.Src.Depth},
+			Dst: dst,
+		})
+	}
+	return inv, nil
+}
@@ -0,0 +1,95 @@
+package graph_test
+
+import (
+	"testing"
+
+	"github.com/runningwild/jig/graph"
+	jpb "github.com/runningwild/jig/proto"
+	"github.com/runningwild/jig/testutils"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestInvert(t *testing.T) {
+	Convey("Invert", t, func() {
+		r := testutils.MakeFakeRepo()
+		c0 := &jpb.Commit{
+			EdgeRefs: []*jpb.EdgeRef{
+				{
+					Src:    &jpb.NodeRef{Node: "src:foo.txt", Depth: 1},
+					Chunks: stringsToContent("alpha", "bravo", "charlie", "delta", "echo"),
+					Dst:    &jpb.NodeRef{Node: "snk:foo.txt"},
+				},
+			},
+		}
+		So(graph.Apply(r, c0), ShouldBeNil)
+		head := firstRangeNode(r, c0)
+
+		// c1 replaces bravo and deletes delta.
+		c1 := &jpb.Commit{
+			Deps: []string{r.HashAlgorithm().HashCommit(c0)},
+			EdgeRefs: []*jpb.EdgeRef{
+				{
+					Src:    &jpb.NodeRef{Node: head, Depth: 1},
+					Chunks: stringsToContent("BRAVO"),
+					Dst:    &jpb.NodeRef{Node: head, Depth: 2},
+				},
+				{
+					Src: &jpb.NodeRef{Node: head, Depth: 3},
+					Dst: &jpb.NodeRef{Node: head, Depth: 4},
+				},
+			},
+		}
+		So(graph.Apply(r, c1), ShouldBeNil)
+
+		Convey("cancels insertions and deletions", func() {
+			inv, err := graph.Invert(r, r.HashAlgorithm().HashCommit(c1))
+			So(err, ShouldBeNil)
+			So(inv.Deps, ShouldResemble, []string{r.HashAlgorithm().HashCommit(c1)})
+			So(graph.Apply(r, inv), ShouldBeNil)
+			So(graph.Check(r), ShouldBeEmpty)
+			So(snippet{r, explicitFrontier(c0, c1), "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.BRAVO.charlie.echo")
+			So(snippet{r, explicitFrontier(c0, c1, inv), "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.bravo.charlie.delta.echo")
+
+			Convey("and can itself be inverted", func() {
+				inv2, err := graph.Invert(r, r.HashAlgorithm().HashCommit(inv))
+				So(err, ShouldBeNil)
+				So(graph.Apply(r, inv2), ShouldBeNil)
+				So(snippet{r, explicitFrontier(c0, c1, inv, inv2), "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.BRAVO.charlie.echo")
+			})
+		})
+
+		Convey("puts moved lines back", func() {
+			// move puts delta between alpha and bravo.
+			move := &jpb.Commit{
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
+				EdgeRefs: []*jpb.EdgeRef{
+					{Src: &jpb.NodeRef{Node: head, Depth: 1}, Dst: &jpb.NodeRef{Node: head, Depth: 3}},
+					{Src: &jpb.NodeRef{Node: head, Depth: 4}, Dst: &jpb.NodeRef{Node: head, Depth: 1}},
+					{Src: &jpb.NodeRef{Node: head, Depth: 3}, Dst: &jpb.NodeRef{Node: head, Depth: 4}},
+				},
+			}
+			So(graph.Apply(r, move), ShouldBeNil)
+			So(snippet{r, explicitFrontier(c0, move), "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.delta.bravo.charlie.echo")
+			inv, err := graph.Invert(r, r.HashAlgorithm().HashCommit(move))
+			So(err, ShouldBeNil)
+			So(graph.Apply(r, inv), ShouldBeNil)
+			So(graph.Check(r), ShouldBeEmpty)
+			So(snippet{r, explicitFrontier(c0, move, inv), "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.bravo.charlie.delta.echo")
+		})
+
+		Convey("deletes files created by the commit", func() {
+			inv, err := graph.Invert(r, r.HashAlgorithm().HashCommit(c0))
+			So(err, ShouldBeNil)
+			So(graph.Apply(r, inv), ShouldBeNil)
+			exists, err := graph.FileExists(r, explicitFrontier(c0, inv), "foo.txt")
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeFalse)
+		})
+
+		Convey("fails for unknown commits", func() {
+			_, err := graph.Invert(r, "missing")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,153 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	jpb "github.com/runningwild/jig/proto"
+)
+
+// A MergeReport describes what happened to the files touched by commits that a frontier observes
+// after another frontier was merged into it.
+type MergeReport struct {
+	// Commits are the commits the frontier observes now that it didn't before, every commit comes
+	// after all of its deps.
+	Commits []string
+
+	// Paths of the files the commits touched, sorted, split up by the state of each file after the
+	// merge.  Files that no longer exist are in Deleted, which includes files that were renamed by
+	// commits that aren't part of the merge.
+	Clean      []string
+	Conflicted []string
+	Deleted    []string
+}
+
+// ReportMerge returns a MergeReport for the files touched by commits as of f.  Each file is checked
+// for conflicts with FindConflicts.
+func ReportMerge(r Repo, f Frontier, commits []string) (*MergeReport, error) {
+	sorted, err := sortCommits(r, commits)
+	if err != nil {
+		return nil, err
+	}
+	report := &MergeReport{Commits: sorted}
+	paths, err := TouchedFiles(r, f, commits)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		v, err := ReadFile(r, f, path, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", path, err)
+		}
+		switch v.State {
+		case FileConflicted:
+			report.Conflicted = append(report.Conflicted, path)
+		case FileAbsent, FileDeleted:
+			report.Deleted = append(report.Deleted, path)
+		default:
+			report.Clean = append(report.Clean, path)
+		}
+	}
+	return report, nil
+}
+
+// TouchedFiles returns the paths, as of f, of every file that commits have edges in, in sorted
+// order.  If a file has been renamed since one of the commits touched it, it is listed under the
+// name f knows it by.
+func TouchedFiles(r Repo, f Frontier, commits []string) ([]string, error) {
+	touched := make(map[string]bool)
+	for _, commitHash := range commits {
+		c := r.GetCommit(commitHash)
+		if c == nil {
+			return nil, fmt.Errorf("commit %q: %w", commitHash, ErrUnknownCommit)
+		}
+		for i, e := range c.EdgeRefs {
+			line, err := lineHash(r, e.GetSrc().GetNode(), e.GetSrc().GetDepth())
+			if err != nil {
+				return nil, fmt.Errorf("commit %s EdgeRef %d: %w", commitHash, i, err)
+			}
+			path, err := fileOf(r, f, line)
+			if err != nil {
+				return nil, fmt.Errorf("commit %s EdgeRef %d: %w", commitHash, i, err)
+			}
+			touched[path] = true
+		}
+	}
+	var paths []string
+	for path := range touched {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// fileOf returns the path of the file that line is in as of f.  It follows edges backwards from line
+// to the src node of the file it was created in, and then follows any renames of that file that f
+// observes.
+func fileOf(r Repo, f Frontier, line string) (string, error) {
+	n := r.GetNode(r.GetRef(line))
+	seen := make(map[string]bool)
+	for n != nil && n.GetSrc() == nil {
+		if seen[n.Head] || len(n.In) == 0 {
+			return "", fmt.Errorf("line %q isn't reachable from any file", line)
+		}
+		seen[n.Head] = true
+		n = r.GetNode(r.GetRef(n.In[0].Node))
+	}
+	if n == nil {
+		return "", fmt.Errorf("line %q: %w", line, ErrMissingNode)
+	}
+
+	// A rename adds an edge from the new name's src node to the old one's.
+	for {
+		state, err := srcState(f, n)
+		if err != nil {
+			return "", err
+		}
+		if state != FileDeleted {
+			break
+		}
+		var renamed *jpb.Edge
+		for i := len(n.In) - 1; i >= 0 && renamed == nil; i-- {
+			obs, err := f.Observes(n.In[i].Commit)
+			if err != nil {
+				return "", err
+			}
+			if obs && strings.HasPrefix(n.In[i].Node, "src:") && !seen[n.In[i].Node] {
+				renamed = n.In[i]
+			}
+		}
+		if renamed == nil {
+			break
+		}
+		seen[renamed.Node] = true
+		n = r.GetNode(renamed.Node)
+		if n == nil {
+			return "", fmt.Errorf("%q: %w", renamed.Node, ErrMissingNode)
+		}
+	}
+	return strings.TrimPrefix(n.Head, "src:"), nil
+}
+
+// PreviewPick returns a MergeReport for adding commit to f along with everything it depends on that
+// f doesn't observe.  Those are the report's Commits, and its files are as they would be once f
+// observes them.
+func PreviewPick(r Repo, f Frontier, commit string) (*MergeReport, error) {
+	missing := make(map[string]bool)
+	var commits []string
+	for dep := range depClosure(r, []string{commit}) {
+		if r.GetCommit(dep) == nil {
+			return nil, fmt.Errorf("commit %q: %w", dep, ErrUnknownCommit)
+		}
+		obs, err := f.Observes(dep)
+		if err != nil {
+			return nil, err
+		}
+		if !obs {
+			missing[dep] = true
+			commits = append(commits, dep)
+		}
+	}
+	return ReportMerge(r, &addToFrontier{f: f, add: missing}, commits)
+}
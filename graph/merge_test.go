@@ -0,0 +1,96 @@
+package graph_test
+
+import (
+	"testing"
+
+	"github.com/runningwild/jig/graph"
+	"github.com/runningwild/jig/testutils"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReportMerge(t *testing.T) {
+	Convey("Merging frontiers", t, func() {
+		r := testutils.MakeFakeRepo()
+		c0, err := graph.ChangeFiles(r, allFrontier{}, nil, []graph.FileChange{
+			{Path: "a.txt", Content: stringsToContent("alpha", "bravo", "charlie")},
+			{Path: "b.txt", Content: stringsToContent("alpha", "bravo", "charlie")},
+			{Path: "c.txt", Content: stringsToContent("alpha", "bravo", "charlie")},
+			{Path: "d.txt", Content: stringsToContent("alpha", "bravo", "charlie")},
+		})
+		So(err, ShouldBeNil)
+		So(graph.Apply(r, c0), ShouldBeNil)
+		h0 := r.HashAlgorithm().HashCommit(c0)
+
+		f0 := explicitFrontierStrings(h0)
+
+		// edit replaces the second line of path.
+		edit := func(path, line string) string {
+			return editFile(r, f0, nil, path, "alpha", line, "charlie")
+		}
+
+		// Ours edits a.txt and renames c.txt, theirs edits a.txt, b.txt and c.txt and deletes d.txt.
+		ours := []string{h0, edit("a.txt", "OURS")}
+		c, err := graph.ChangeFiles(r, explicitFrontierStrings(ours...), []string{h0}, []graph.FileChange{
+			{Path: "e.txt", RenamedFrom: "c.txt"},
+		})
+		So(err, ShouldBeNil)
+		So(graph.Apply(r, c), ShouldBeNil)
+		ours = append(ours, r.HashAlgorithm().HashCommit(c))
+
+		theirs := []string{edit("a.txt", "THEIRS"), edit("b.txt", "THEIRS"), edit("c.txt", "THEIRS")}
+		c, err = graph.ChangeFiles(r, explicitFrontierStrings(h0), []string{h0}, []graph.FileChange{
+			{Path: "d.txt", Delete: true},
+		})
+		So(err, ShouldBeNil)
+		So(graph.Apply(r, c), ShouldBeNil)
+		theirs = append(theirs, r.HashAlgorithm().HashCommit(c))
+
+		merged := explicitFrontierStrings(append(append([]string(nil), ours...), theirs...)...)
+		paths, err := graph.TouchedFiles(r, merged, theirs)
+		So(err, ShouldBeNil)
+		So(paths, ShouldResemble, []string{"a.txt", "b.txt", "d.txt", "e.txt"})
+
+		report, err := graph.ReportMerge(r, merged, theirs)
+		So(err, ShouldBeNil)
+		So(report.Commits, ShouldHaveLength, len(theirs))
+		So(report.Clean, ShouldResemble, []string{"b.txt", "e.txt"})
+		So(report.Conflicted, ShouldResemble, []string{"a.txt"})
+		So(report.Deleted, ShouldResemble, []string{"d.txt"})
+	})
+}
+
+func TestPreviewPick(t *testing.T) {
+	Convey("Picking a commit", t, func() {
+		r := testutils.MakeFakeRepo()
+		c0, err := graph.ChangeFiles(r, allFrontier{}, nil, []graph.FileChange{
+			{Path: "a.txt", Content: stringsToContent("alpha", "bravo", "charlie")},
+		})
+		So(err, ShouldBeNil)
+		So(graph.Apply(r, c0), ShouldBeNil)
+		h0 := r.HashAlgorithm().HashCommit(c0)
+
+		first := editFile(r, explicitFrontierStrings(h0), nil, "a.txt", "alpha", "first", "charlie")
+		second := editFile(r, explicitFrontierStrings(h0, first), nil, "a.txt", "alpha", "second", "charlie")
+		other := editFile(r, explicitFrontierStrings(h0), nil, "a.txt", "alpha", "other", "charlie")
+
+		Convey("adds the commits it depends on", func() {
+			report, err := graph.PreviewPick(r, explicitFrontierStrings(h0), second)
+			So(err, ShouldBeNil)
+			So(report.Commits, ShouldResemble, []string{first, second})
+			So(report.Clean, ShouldResemble, []string{"a.txt"})
+			So(report.Conflicted, ShouldBeEmpty)
+
+			report, err = graph.PreviewPick(r, explicitFrontierStrings(h0, first, second), second)
+			So(err, ShouldBeNil)
+			So(report.Commits, ShouldBeEmpty)
+		})
+
+		Convey("previews conflicts", func() {
+			report, err := graph.PreviewPick(r, explicitFrontierStrings(h0, other), first)
+			So(err, ShouldBeNil)
+			So(report.Commits, ShouldResemble, []string{first})
+			So(report.Conflicted, ShouldResemble, []string{"a.txt"})
+		})
+	})
+}
@@ -0,0 +1,143 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	jpb "github.com/runningwild/jig/proto"
+)
+
+// Migrate applies every commit in from to to, which may use a different hash algorithm.  Since
+// commits refer to nodes and other commits by hash, each one is rewritten to refer to the new hashes
+// before it is applied.  It returns a map from each commit's hash in from to its hash in to.
+func Migrate(from, to Repo) (map[string]string, error) {
+	commits, err := sortCommits(from, listAll(from.ListCommits))
+	if err != nil {
+		return nil, err
+	}
+	fromAlg, toAlg := from.HashAlgorithm(), to.HashAlgorithm()
+
+	commitMap := make(map[string]string)
+	lineMap := make(map[string]string)
+	mapLine := func(line string) (string, error) {
+		if strings.HasPrefix(line, "src:") || strings.HasPrefix(line, "snk:") || line == DeletedNode {
+			return line, nil
+		}
+		if newLine, ok := lineMap[line]; ok {
+			return newLine, nil
+		}
+		return "", fmt.Errorf("node %q: %w", line, ErrMissingNode)
+	}
+
+	for _, commitHash := range commits {
+		c := proto.Clone(from.GetCommit(commitHash)).(*jpb.Commit)
+		for i, dep := range c.Deps {
+			c.Deps[i] = commitMap[dep]
+		}
+
+		// The hash of the line before each EdgeRef's content, in both repos.
+		prevs := make([][2]string, len(c.EdgeRefs))
+		for i, e := range c.EdgeRefs {
+			if len(e.Chunks) > 0 {
+				if prevs[i][0], err = lineHash(from, e.Src.Node, e.Src.Depth); err != nil {
+					return nil, fmt.Errorf("commit %s: %w", commitHash, err)
+				}
+				if prevs[i][1], err = mapLine(prevs[i][0]); err != nil {
+					return nil, fmt.Errorf("commit %s: %w", commitHash, err)
+				}
+			}
+			for _, ref := range []*jpb.NodeRef{e.Src, e.Dst} {
+				if ref.Node, err = mapLine(ref.Node); err != nil {
+					return nil, fmt.Errorf("commit %s: %w", commitHash, err)
+				}
+			}
+		}
+
+		if err := Apply(to, c); err != nil {
+			return nil, fmt.Errorf("failed to apply commit %s: %w", commitHash, err)
+		}
+		newHash := toAlg.HashCommit(c)
+		commitMap[commitHash] = newHash
+
+		// Every line of new content gets a new hash, and any of them may be referred to by later
+		// commits.
+		for i, e := range c.EdgeRefs {
+			oldPrev, newPrev := prevs[i][0], prevs[i][1]
+			for _, line := range e.Chunks {
+				_, oldPrev = fromAlg.CalculateNodeHashes(commitHash, oldPrev, [][]byte{line})
+				_, newPrev = toAlg.CalculateNodeHashes(newHash, newPrev, [][]byte{line})
+				lineMap[oldPrev] = newPrev
+			}
+		}
+	}
+	return commitMap, nil
+}
+
+// lineHash returns the hash of the line at depth, as counted the same way as SplitNode, starting
+// from node.
+func lineHash(r Repo, node string, depth int32) (string, error) {
+	n, depth, err := findDepth(r, node, depth)
+	if err != nil {
+		return "", err
+	}
+	if n.GetSrc() != nil || depth == n.Count {
+		return n.Tail, nil
+	}
+	if depth <= 0 || len(n.In) == 0 {
+		return "", fmt.Errorf("node %q at depth %d: %w", n.Head, depth, ErrBadDepth)
+	}
+	content := r.GetContent(n.GetContentHash())
+	if len(content) != int(n.Count) {
+		return "", fmt.Errorf("%q is malformed", n.Head)
+	}
+	_, tail := r.HashAlgorithm().CalculateNodeHashes(n.In[0].Commit, n.In[0].Node, content[0:depth])
+	return tail, nil
+}
+
+// sortCommits returns commits sorted so that every commit comes after all of its deps.  Deps that
+// aren't in commits are ignored.  Commits that don't depend on each other are sorted by hash so that
+// the result is deterministic.
+func sortCommits(r Repo, commits []string) ([]string, error) {
+	deps := make(map[string][]string)
+	for _, commitHash := range commits {
+		c := r.GetCommit(commitHash)
+		if c == nil {
+			return nil, fmt.Errorf("commit %q not found", commitHash)
+		}
+		deps[commitHash] = c.Deps
+	}
+	sorted := append([]string(nil), commits...)
+	sort.Strings(sorted)
+
+	var order []string
+	done := make(map[string]bool)
+	visiting := make(map[string]bool)
+	var visit func(commitHash string) error
+	visit = func(commitHash string) error {
+		if done[commitHash] {
+			return nil
+		}
+		if visiting[commitHash] {
+			return fmt.Errorf("commit %s: %w", commitHash, ErrCycle)
+		}
+		visiting[commitHash] = true
+		for _, dep := range deps[commitHash] {
+			if _, ok := deps[dep]; ok {
+				if err := visit(dep); err != nil {
+					return err
+				}
+			}
+		}
+		done[commitHash] = true
+		order = append(order, commitHash)
+		return nil
+	}
+	for _, commitHash := range sorted {
+		if err := visit(commitHash); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
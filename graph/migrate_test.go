@@ -0,0 +1,125 @@
+package graph_test
+
+import (
+	"testing"
+
+	"github.com/runningwild/jig/graph"
+	jpb "github.com/runningwild/jig/proto"
+	"github.com/runningwild/jig/testutils"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMigrate(t *testing.T) {
+	Convey("Migrate", t, func() {
+		from := testutils.MakeFakeRepoWithHashAlgorithm(graph.Shake128_32)
+		c0 := &jpb.Commit{
+			EdgeRefs: []*jpb.EdgeRef{
+				{
+					Src:    &jpb.NodeRef{Node: "src:foo.txt", Depth: 1},
+					Chunks: stringsToContent("alpha", "bravo", "charlie", "delta"),
+					Dst:    &jpb.NodeRef{Node: "snk:foo.txt"},
+				},
+			},
+		}
+		So(graph.Apply(from, c0), ShouldBeNil)
+		h0 := graph.Shake128_32.HashCommit(c0)
+		var ranges []graph.ReadRange
+		_, err := graph.ReadVersion(from, explicitFrontierStrings(h0), "src:foo.txt", "snk:foo.txt", &graph.ReadMetadata{Ranges: &ranges})
+		So(err, ShouldBeNil)
+		head := ranges[0].Node
+
+		// c1 replaces bravo, and c2 deletes from the middle of the content c1 added.
+		c1 := &jpb.Commit{
+			Deps: []string{h0},
+			EdgeRefs: []*jpb.EdgeRef{
+				{
+					Src:    &jpb.NodeRef{Node: head, Depth: 1},
+					Chunks: stringsToContent("bravo-1", "bravo-2", "bravo-3"),
+					Dst:    &jpb.NodeRef{Node: head, Depth: 2},
+				},
+			},
+			Metadata: &jpb.CommitMetadata{Author: "author", Message: "replace bravo"},
+		}
+		So(graph.Apply(from, c1), ShouldBeNil)
+		h1 := graph.Shake128_32.HashCommit(c1)
+		first, _ := graph.Shake128_32.CalculateNodeHashes(h1, head, stringsToContent("bravo-1"))
+		c2 := &jpb.Commit{
+			Deps: []string{h0, h1},
+			EdgeRefs: []*jpb.EdgeRef{
+				{
+					Src: &jpb.NodeRef{Node: first, Depth: 2},
+					Dst: &jpb.NodeRef{Node: head, Depth: 3},
+				},
+			},
+		}
+		So(graph.Apply(from, c2), ShouldBeNil)
+		h2 := graph.Shake128_32.HashCommit(c2)
+		So(snippet{from, explicitFrontierStrings(h0, h1, h2), "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.bravo-1.bravo-2.delta")
+
+		to := testutils.MakeFakeRepoWithHashAlgorithm(graph.SHA3_256)
+		commits, err := graph.Migrate(from, to)
+		So(err, ShouldBeNil)
+		So(commits, ShouldHaveLength, 3)
+
+		Convey("maps every commit to a commit in the new repo", func() {
+			for oldHash, newHash := range commits {
+				So(graph.HashAlgorithmOf(oldHash), ShouldEqual, graph.Shake128_32)
+				So(graph.HashAlgorithmOf(newHash), ShouldEqual, graph.SHA3_256)
+				So(to.GetCommit(newHash), ShouldNotBeNil)
+			}
+			So(to.GetCommit(commits[h2]).Deps, ShouldResemble, []string{commits[h0], commits[h1]})
+			So(to.GetCommitMetadata(commits[h1]).GetMessage(), ShouldEqual, "replace bravo")
+		})
+
+		Convey("preserves the content of every version", func() {
+			So(snippet{to, explicitFrontierStrings(commits[h0]), "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.bravo.charlie.delta")
+			So(snippet{to, explicitFrontierStrings(commits[h0], commits[h1]), "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.bravo-1.bravo-2.bravo-3.charlie.delta")
+			So(snippet{to, explicitFrontierStrings(commits[h0], commits[h1], commits[h2]), "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.bravo-1.bravo-2.delta")
+		})
+
+		Convey("leaves a consistent repo", func() {
+			So(graph.Check(to), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestMigrateTree(t *testing.T) {
+	Convey("Migrate keeps deleted and renamed files", t, func() {
+		from := testutils.MakeFakeRepoWithHashAlgorithm(graph.Shake128_32)
+		var hashes []string
+		change := func(changes ...graph.FileChange) {
+			c, err := graph.ChangeFiles(from, explicitFrontierStrings(hashes...), nil, changes)
+			So(err, ShouldBeNil)
+			So(graph.Apply(from, c), ShouldBeNil)
+			hashes = append(hashes, graph.Shake128_32.HashCommit(c))
+		}
+		change(
+			graph.FileChange{Path: "a.txt", Content: stringsToContent("alpha")},
+			graph.FileChange{Path: "b.txt", Content: stringsToContent("bravo")},
+		)
+		change(graph.FileChange{Path: "a.txt", Delete: true})
+		change(graph.FileChange{Path: "c.txt", RenamedFrom: "b.txt"})
+
+		to := testutils.MakeFakeRepoWithHashAlgorithm(graph.Shake256)
+		commits, err := graph.Migrate(from, to)
+		So(err, ShouldBeNil)
+		So(commits, ShouldHaveLength, 3)
+		So(graph.Check(to), ShouldBeEmpty)
+
+		var migrated []string
+		for _, h := range hashes {
+			migrated = append(migrated, commits[h])
+		}
+		f := explicitFrontierStrings(migrated...)
+		files, err := graph.ListFiles(to, f, "")
+		So(err, ShouldBeNil)
+		So(files, ShouldResemble, []string{"c.txt"})
+		v, err := graph.ReadFile(to, f, "c.txt", nil)
+		So(err, ShouldBeNil)
+		So(contentToString(v.Lines), ShouldEqual, "bravo")
+		v, err = graph.ReadFile(to, explicitFrontierStrings(migrated[0]), "a.txt", nil)
+		So(err, ShouldBeNil)
+		So(contentToString(v.Lines), ShouldEqual, "alpha")
+	})
+}
@@ -0,0 +1,372 @@
+package graph
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	jpb "github.com/runningwild/jig/proto"
+)
+
+// A ConflictRenderer decides what the conflicts in a file look like when Render writes it out.
+type ConflictRenderer interface {
+	// NeedsBase returns true if RenderConflict uses the Base of a conflict, reading it costs another
+	// read of the file so it is left out otherwise.
+	NeedsBase() bool
+
+	// RenderConflict returns the lines to write in place of c, including its Before and After lines
+	// if it wants to keep them.
+	RenderConflict(c *ConflictContent) ([][]byte, error)
+}
+
+// ConflictContent is a conflict as it is handed to a ConflictRenderer.
+type ConflictContent struct {
+	Kind ConflictKind
+
+	// Before and After hold the line right before and the line right after the conflict, which every
+	// version of it shares.  Either is empty if the conflict is at that end of the file.
+	Before, After [][]byte
+
+	// Base is what the conflict looked like before any of the commits in it.  It is only set if the
+	// renderer's NeedsBase returns true.
+	Base *VersionContent
+
+	Versions []VersionContent
+}
+
+// VersionContent is one version of a conflict.
+type VersionContent struct {
+	// Label describes Commits in a single line, using their metadata, for use in conflict markers.
+	// It is empty if there are no Commits.
+	Label   string
+	Commits []CommitInfo
+
+	// Lines doesn't include the conflict's Before and After lines.  Deleted is set if the commits
+	// delete the file, in which case there are no Lines.
+	Lines   [][]byte
+	Deleted bool
+}
+
+// Render returns the file at path as of f with lines joined by join, with each of conflicts written
+// the way cr says.
+func Render(r Repo, f Frontier, path string, conflicts []Conflict, cr ConflictRenderer, join []byte) ([]byte, error) {
+	lines, _, err := render(r, f, path, conflicts, cr)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Join(lines, join), nil
+}
+
+func toStrings(lines [][]byte) []string {
+	ss := make([]string, len(lines))
+	for i, line := range lines {
+		ss[i] = string(line)
+	}
+	return ss
+}
+
+// A renderedConflict is a conflict as Render wrote it, first and last are the line numbers, starting
+// from 1, of the first and last lines that the ConflictRenderer returned for it.
+type renderedConflict struct {
+	content     *ConflictContent
+	first, last int
+}
+
+// render does the work for Render, returning the lines of the file and where each conflict is in
+// them.
+func render(r Repo, f Frontier, path string, conflicts []Conflict, cr ConflictRenderer) ([][]byte, []renderedConflict, error) {
+	conflictLookup := make(map[string]int)
+	for i, c := range conflicts {
+		conflictLookup[c.Start] = i
+	}
+	state, err := statFile(r, f, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch state {
+	case FileAbsent:
+		return nil, nil, fmt.Errorf("%q: %w", path, ErrNoObserve)
+	case FileDeleted:
+		if len(conflicts) == 0 || conflicts[0].Kind != DeleteEditConflict {
+			return nil, nil, fmt.Errorf("%q: %w", path, ErrDeleted)
+		}
+	}
+	var lines [][]byte
+	rendered := make([]renderedConflict, len(conflicts))
+	var renames []string
+	nextNode := "src:" + path
+	for nextNode != "snk:"+path {
+		next := r.GetNode(nextNode)
+		if next == nil {
+			return nil, nil, fmt.Errorf("failed to find node %s", nextNode)
+		}
+		lines = append(lines, r.GetContent(next.GetContentHash())...)
+		if ci, ok := conflictLookup[next.Tail]; ok {
+			con := conflicts[ci]
+			end := r.GetNode(con.End)
+			if end == nil {
+				return nil, nil, fmt.Errorf("failed to find node %s", con.End)
+			}
+			content, err := readConflictContent(r, f, con, next, end, cr.NeedsBase())
+			if err != nil {
+				return nil, nil, err
+			}
+			// The renderer decides where the lines on either side of the conflict go.
+			lines = lines[0 : len(lines)-len(content.Before)]
+			out, err := cr.RenderConflict(content)
+			if err != nil {
+				return nil, nil, err
+			}
+			rendered[ci] = renderedConflict{content: content, first: len(lines) + 1}
+			lines = append(lines, out...)
+			rendered[ci].last = len(lines)
+			next = end
+			if content := r.GetContent(next.GetContentHash()); len(content) > 0 {
+				lines = append(lines, content[1:]...)
+			}
+			if next.GetSnk() != nil {
+				break
+			}
+		}
+
+		edge, err := nextEdge(r, f, next, renames)
+		if err != nil {
+			return nil, nil, err
+		}
+		if edge == nil {
+			return nil, nil, fmt.Errorf("failed pathing through %q", path)
+		}
+		renames = followRenames(next, edge, renames)
+		nextNode = edge.Node
+	}
+	return lines, rendered, nil
+}
+
+// readConflictContent reads c, which goes from start to end.  Each version of c starts with the last
+// line of start and ends with the first line of end, if they have any, and those become the Before
+// and After lines.
+func readConflictContent(r Repo, f Frontier, c Conflict, start, end *jpb.Node, base bool) (*ConflictContent, error) {
+	content := &ConflictContent{Kind: c.Kind}
+	if lines := r.GetContent(start.GetContentHash()); len(lines) > 0 {
+		content.Before = lines[len(lines)-1:]
+	}
+	if lines := r.GetContent(end.GetContentHash()); len(lines) > 0 {
+		content.After = lines[:1]
+	}
+	trim := func(lines [][]byte) [][]byte {
+		if len(lines) < len(content.Before)+len(content.After) {
+			return nil
+		}
+		return lines[len(content.Before) : len(lines)-len(content.After)]
+	}
+	if base {
+		lines, err := readBase(r, f, c)
+		if err != nil {
+			return nil, err
+		}
+		content.Base = &VersionContent{Lines: trim(lines)}
+	}
+	vs, err := readVersions(r, f, c.Start, c.End, c.Groups)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vs {
+		var commits []string
+		for commit := range v.commits {
+			commits = append(commits, commit)
+		}
+		sort.Strings(commits)
+		version := VersionContent{Deleted: v.deleted}
+		for _, commit := range commits {
+			version.Commits = append(version.Commits, commitInfo(r, commit))
+		}
+		version.Label = commitsLabel(version.Commits)
+		if !v.deleted {
+			version.Lines = trim(v.lines)
+		}
+		content.Versions = append(content.Versions, version)
+	}
+	return content, nil
+}
+
+// commitsLabel describes commits by their hashes, authors and the first lines of their messages.
+func commitsLabel(commits []CommitInfo) string {
+	var labels []string
+	for _, c := range commits {
+		label := c.Hash
+		if i := strings.Index(label, ":"); i >= 0 {
+			label = label[i+1:]
+		}
+		if len(label) > 12 {
+			label = label[:12]
+		}
+		if c.Author != "" {
+			label += " (" + c.Author + ")"
+		}
+		if subject := strings.TrimSpace(strings.SplitN(c.Message, "\n", 2)[0]); subject != "" {
+			label += " " + subject
+		}
+		labels = append(labels, label)
+	}
+	return strings.Join(labels, ", ")
+}
+
+// startMarker is the first line of a conflict written with markers, it names the kind of conflict
+// unless it is an EditConflict.
+func startMarker(kind ConflictKind) []byte {
+	if kind == EditConflict {
+		return []byte("<<<<<<<")
+	}
+	return []byte("<<<<<<< " + kind.String())
+}
+
+// withContext returns lines with before and after on either side of them.
+func withContext(before, lines, after [][]byte) [][]byte {
+	out := append([][]byte{}, before...)
+	out = append(out, lines...)
+	return append(out, after...)
+}
+
+// GitRenderer writes each conflict between "<<<<<<<" and ">>>>>>>" lines, with every version of it
+// after a "=======" line that has its Label.  Each version includes the conflict's Before and After
+// lines, so that it can be kept by deleting the others and the markers.  Conflicts other than
+// EditConflicts have their kind after the "<<<<<<<", and a version that deletes the file is just a
+// "=======" line that says so.
+type GitRenderer struct{}
+
+func (GitRenderer) NeedsBase() bool { return false }
+
+func (GitRenderer) RenderConflict(c *ConflictContent) ([][]byte, error) {
+	return gitMarkers(c, false), nil
+}
+
+// Diff3Renderer is a GitRenderer that also shows the Base of each conflict, after a "||||||| base"
+// line before the versions, so that it is clear what each version changed.
+type Diff3Renderer struct{}
+
+func (Diff3Renderer) NeedsBase() bool { return true }
+
+func (Diff3Renderer) RenderConflict(c *ConflictContent) ([][]byte, error) {
+	return gitMarkers(c, true), nil
+}
+
+func gitMarkers(c *ConflictContent, base bool) [][]byte {
+	lines := [][]byte{startMarker(c.Kind)}
+	if base {
+		lines = append(lines, []byte("||||||| base"))
+		lines = append(lines, withContext(c.Before, c.Base.Lines, c.After)...)
+	}
+	for _, v := range c.Versions {
+		marker := "======="
+		if v.Deleted {
+			marker += " deleted by"
+		}
+		if v.Label != "" {
+			marker += " " + v.Label
+		}
+		lines = append(lines, []byte(marker))
+		if !v.Deleted {
+			lines = append(lines, withContext(c.Before, v.Lines, c.After)...)
+		}
+	}
+	return append(lines, []byte(">>>>>>>"))
+}
+
+// SideBySideRenderer writes the versions of each conflict next to each other in columns separated by
+// " | ", with their Labels at the top, between "<<<<<<<" and ">>>>>>>" lines.  The conflict's Before
+// and After lines are left where they are in the file.  Columns are padded by counting runes, so
+// they only line up for text where every rune takes up the same width.
+type SideBySideRenderer struct{}
+
+func (SideBySideRenderer) NeedsBase() bool { return false }
+
+func (SideBySideRenderer) RenderConflict(c *ConflictContent) ([][]byte, error) {
+	columns := make([][]string, len(c.Versions))
+	widths := make([]int, len(c.Versions))
+	rows := 0
+	for i, v := range c.Versions {
+		column := []string{v.Label}
+		if v.Deleted {
+			column = append(column, "(deleted)")
+		}
+		column = append(column, toStrings(v.Lines)...)
+		for _, s := range column {
+			if n := utf8.RuneCountInString(s); n > widths[i] {
+				widths[i] = n
+			}
+		}
+		if len(column) > rows {
+			rows = len(column)
+		}
+		columns[i] = column
+	}
+
+	lines := append(append([][]byte{}, c.Before...), startMarker(c.Kind))
+	for row := 0; row < rows; row++ {
+		var b strings.Builder
+		for i, column := range columns {
+			var s string
+			if row < len(column) {
+				s = column[row]
+			}
+			if i > 0 {
+				b.WriteString(" | ")
+			}
+			b.WriteString(s)
+			if i < len(columns)-1 {
+				b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(s)))
+			}
+		}
+		lines = append(lines, []byte(b.String()))
+	}
+	lines = append(lines, []byte(">>>>>>>"))
+	return append(lines, c.After...), nil
+}
+
+// WinnerRenderer resolves each conflict in favor of one side of a merge by writing out just the
+// version that side has, with no markers.  The versions whose commits Theirs all observes are theirs
+// and the rest are ours, so Theirs is usually the frontier that was merged in.  If the winning side
+// has no version of a conflict then it didn't change those lines, and the conflict's Base is written
+// instead.  If it has more than one, it had the conflict before the merge, and the conflict is
+// written by a GitRenderer.  A version that deletes the file leaves nothing in place of the conflict
+// but its Before and After lines.
+type WinnerRenderer struct {
+	Theirs Frontier
+
+	// TheirsWin is set if their versions should be written rather than ours.
+	TheirsWin bool
+}
+
+func (WinnerRenderer) NeedsBase() bool { return true }
+
+func (w WinnerRenderer) RenderConflict(c *ConflictContent) ([][]byte, error) {
+	var winners []*VersionContent
+	for i := range c.Versions {
+		v := &c.Versions[i]
+		if len(v.Commits) == 0 {
+			continue
+		}
+		theirs := true
+		for _, commit := range v.Commits {
+			obs, err := w.Theirs.Observes(commit.Hash)
+			if err != nil {
+				return nil, err
+			}
+			theirs = theirs && obs
+		}
+		if theirs == w.TheirsWin {
+			winners = append(winners, v)
+		}
+	}
+	winner := c.Base
+	switch len(winners) {
+	case 0:
+	case 1:
+		winner = winners[0]
+	default:
+		return gitMarkers(c, false), nil
+	}
+	return withContext(c.Before, winner.Lines, c.After), nil
+}
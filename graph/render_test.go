@@ -0,0 +1,138 @@
+package graph_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/runningwild/jig/graph"
+	jpb "github.com/runningwild/jig/proto"
+	"github.com/runningwild/jig/testutils"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// recordingRenderer writes every conflict as a single line, without the lines around it, and keeps
+// what it was given.
+type recordingRenderer struct {
+	base     bool
+	contents []*graph.ConflictContent
+}
+
+func (r *recordingRenderer) NeedsBase() bool { return r.base }
+
+func (r *recordingRenderer) RenderConflict(c *graph.ConflictContent) ([][]byte, error) {
+	r.contents = append(r.contents, c)
+	return [][]byte{[]byte(fmt.Sprintf("conflict %d", len(r.contents)))}, nil
+}
+
+func TestRender(t *testing.T) {
+	Convey("Render", t, func() {
+		r := testutils.MakeFakeRepo()
+		c0, err := graph.ChangeFiles(r, allFrontier{}, nil, []graph.FileChange{
+			{Path: "a.txt", Content: stringsToContent("alpha", "", "bravo", "charlie", "delta")},
+		})
+		So(err, ShouldBeNil)
+		So(graph.Apply(r, c0), ShouldBeNil)
+		h0 := r.HashAlgorithm().HashCommit(c0)
+
+		edit := func(author, line string) string {
+			md := &jpb.CommitMetadata{Author: author, Message: "make it " + line + "\n\nmore details"}
+			return editFile(r, explicitFrontierStrings(h0), md, "a.txt", "alpha", "", line, "charlie", "delta")
+		}
+		ours := edit("one", "BRAVO")
+		theirs := edit("two", "bravo!")
+		f := explicitFrontierStrings(h0, ours, theirs)
+		v, err := graph.ReadFile(r, f, "a.txt", nil)
+		So(err, ShouldBeNil)
+		So(v.Conflicts, ShouldHaveLength, 1)
+		render := func(cr graph.ConflictRenderer) string {
+			data, err := graph.Render(r, f, "a.txt", v.Conflicts, cr, []byte("\n"))
+			So(err, ShouldBeNil)
+			return string(data)
+		}
+		// short is how a commit is named in a label.
+		short := func(commit string) string {
+			return commit[strings.Index(commit, ":")+1:][:12]
+		}
+		ourLabel := short(ours) + " (one) make it BRAVO"
+		theirLabel := short(theirs) + " (two) make it bravo!"
+
+		Convey("hands renderers the lines around each conflict separately", func() {
+			rec := &recordingRenderer{base: true}
+			So(render(rec), ShouldEqual, "alpha\nconflict 1\ndelta")
+			So(rec.contents, ShouldHaveLength, 1)
+			c := rec.contents[0]
+			So(c.Kind, ShouldEqual, graph.EditConflict)
+			So(c.Before, ShouldResemble, [][]byte{{}})
+			So(c.After, ShouldResemble, stringsToContent("charlie"))
+			So(contentToString(c.Base.Lines), ShouldEqual, "bravo")
+			So(c.Versions, ShouldHaveLength, 2)
+			lines := map[string]string{}
+			for _, version := range c.Versions {
+				So(version.Commits, ShouldHaveLength, 1)
+				lines[version.Label] = contentToString(version.Lines)
+			}
+			So(lines, ShouldResemble, map[string]string{ourLabel: "BRAVO", theirLabel: "bravo!"})
+
+			rec = &recordingRenderer{}
+			render(rec)
+			So(rec.contents[0].Base, ShouldBeNil)
+		})
+
+		Convey("labels git-style markers with commit metadata", func() {
+			data := render(graph.GitRenderer{})
+			So(data, ShouldNotContainSubstring, "map[")
+			So(data, ShouldStartWith, "alpha\n<<<<<<<\n=======")
+			So(data, ShouldContainSubstring, "======= "+ourLabel+"\n\nBRAVO\ncharlie\n")
+			So(data, ShouldContainSubstring, "======= "+theirLabel+"\n\nbravo!\ncharlie\n")
+			So(data, ShouldEndWith, "charlie\n>>>>>>>\ndelta")
+
+			human, err := graph.HumanReadable(r, f, "a.txt", v.Conflicts, []byte("\n"), false)
+			So(err, ShouldBeNil)
+			So(string(human), ShouldEqual, data)
+		})
+
+		Convey("shows the base with diff3", func() {
+			data := render(graph.Diff3Renderer{})
+			So(data, ShouldStartWith, "alpha\n<<<<<<<\n||||||| base\n\nbravo\ncharlie\n=======")
+			human, err := graph.HumanReadable(r, f, "a.txt", v.Conflicts, []byte("\n"), true)
+			So(err, ShouldBeNil)
+			So(string(human), ShouldEqual, data)
+		})
+
+		Convey("puts versions side by side", func() {
+			first, second := ourLabel, theirLabel
+			firstLine, secondLine := "BRAVO", "bravo!"
+			if !strings.Contains(render(graph.GitRenderer{}), "======= "+ourLabel+"\n\nBRAVO\ncharlie\n======= ") {
+				first, second = second, first
+				firstLine, secondLine = secondLine, firstLine
+			}
+			pad := strings.Repeat(" ", len(first)-len(firstLine))
+			So(render(graph.SideBySideRenderer{}), ShouldEqual, strings.Join([]string{
+				"alpha",
+				"",
+				"<<<<<<<",
+				first + " | " + second,
+				firstLine + pad + " | " + secondLine,
+				">>>>>>>",
+				"charlie",
+				"delta",
+			}, "\n"))
+		})
+
+		Convey("resolves conflicts in favor of one side", func() {
+			theirFrontier := explicitFrontierStrings(h0, theirs)
+			So(render(graph.WinnerRenderer{Theirs: theirFrontier}), ShouldEqual, "alpha\n\nBRAVO\ncharlie\ndelta")
+			So(render(graph.WinnerRenderer{Theirs: theirFrontier, TheirsWin: true}), ShouldEqual, "alpha\n\nbravo!\ncharlie\ndelta")
+
+			Convey("using the base if that side didn't change the conflict", func() {
+				So(render(graph.WinnerRenderer{Theirs: explicitFrontierStrings(h0), TheirsWin: true}), ShouldEqual, "alpha\n\nbravo\ncharlie\ndelta")
+			})
+
+			Convey("leaving the markers if that side already had the conflict", func() {
+				So(render(graph.WinnerRenderer{Theirs: explicitFrontierStrings(h0)}), ShouldEqual, render(graph.GitRenderer{}))
+			})
+		})
+	})
+}
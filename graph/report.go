@@ -0,0 +1,164 @@
+package graph
+
+import (
+	"encoding/binary"
+	"sort"
+	"strings"
+)
+
+// A ConflictReport describes the conflicts in a file in a form that can be handed to other programs,
+// it is meant to be encoded as JSON and the field names used there won't change.  Line numbers start
+// at 1 and refer to the file as HumanReadable writes it with a newline as the join.
+type ConflictReport struct {
+	Path      string         `json:"path"`
+	Conflicts []ConflictInfo `json:"conflicts"`
+}
+
+// ConflictInfo describes one conflict.
+type ConflictInfo struct {
+	// ID identifies the conflict no matter what else is in the file or which frontier it is read
+	// from, see HashAlgorithm.HashConflict.
+	ID string `json:"id"`
+
+	// Kind is what sort of conflict it is, as given by ConflictKind.String.
+	Kind string `json:"kind"`
+
+	// StartLine and EndLine are the lines of the "<<<<<<<" and ">>>>>>>" markers around the conflict.
+	StartLine int `json:"start_line"`
+	EndLine   int `json:"end_line"`
+
+	// Base is what the conflict looked like before any of the commits in it, it is only set if the
+	// report was made with base set.
+	Base *ConflictGroup `json:"base,omitempty"`
+
+	// Groups are the versions of the conflict, in the order they are in the file.
+	Groups []ConflictGroup `json:"groups"`
+}
+
+// A ConflictGroup is one version of a conflict and the commits that agree on it.  Like the versions
+// that HumanReadable writes out, Lines includes the line before the conflict and the line after it,
+// if there are any.
+type ConflictGroup struct {
+	// StartLine and EndLine are the first and last of Lines in the file, not counting the marker
+	// before them.  If there are no lines EndLine is the marker.
+	StartLine int      `json:"start_line"`
+	EndLine   int      `json:"end_line"`
+	Lines     []string `json:"lines"`
+
+	// Deleted is set if the commits delete the file, in which case there are no Lines.
+	Deleted bool         `json:"deleted,omitempty"`
+	Commits []CommitInfo `json:"commits,omitempty"`
+}
+
+// CommitInfo is a commit and its metadata, the metadata is left out if the repo doesn't have it.
+type CommitInfo struct {
+	Hash      string `json:"hash"`
+	Author    string `json:"author,omitempty"`
+	Committer string `json:"committer,omitempty"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// ReportConflicts returns a ConflictReport for the file at path as of f.  If base is set each
+// conflict includes its Base, and the line numbers are those of a file written with base set.
+func ReportConflicts(r Repo, f Frontier, path string, base bool) (*ConflictReport, error) {
+	report := &ConflictReport{Path: path, Conflicts: []ConflictInfo{}}
+	v, err := ReadFile(r, f, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if v.ConflictsErr != nil {
+		return nil, v.ConflictsErr
+	}
+	if v.State != FileConflicted {
+		return report, nil
+	}
+	var cr ConflictRenderer = GitRenderer{}
+	if base {
+		cr = Diff3Renderer{}
+	}
+	_, rendered, err := render(r, f, path, v.Conflicts, cr)
+	if err != nil {
+		return nil, err
+	}
+	alg := r.HashAlgorithm()
+	for i, c := range v.Conflicts {
+		content := rendered[i].content
+		info := ConflictInfo{
+			ID:        alg.HashConflict(c),
+			Kind:      c.Kind.String(),
+			StartLine: rendered[i].first,
+			EndLine:   rendered[i].last,
+		}
+
+		// line is the marker before the next section of the conflict.
+		line := rendered[i].first + 1
+		group := func(v *VersionContent) ConflictGroup {
+			g := ConflictGroup{StartLine: line + 1, Lines: []string{}, Deleted: v.Deleted, Commits: v.Commits}
+			if !v.Deleted {
+				g.Lines = toStrings(withContext(content.Before, v.Lines, content.After))
+			}
+			g.EndLine = g.StartLine + len(g.Lines) - 1
+			line = g.EndLine + 1
+			return g
+		}
+		if base {
+			g := group(content.Base)
+			info.Base = &g
+		}
+		for j := range content.Versions {
+			info.Groups = append(info.Groups, group(&content.Versions[j]))
+		}
+		report.Conflicts = append(report.Conflicts, info)
+	}
+	return report, nil
+}
+
+// ListConflicts returns a ConflictReport for every file within dir that has conflicts as of f, in
+// order of path.  Unlike ListFiles it includes files that were deleted while they were being edited.
+// If dir is empty every file is checked.
+func ListConflicts(r Repo, f Frontier, dir string, base bool) ([]*ConflictReport, error) {
+	prefix := "src:"
+	if dir != "" {
+		prefix += dir + "/"
+	}
+	var reports []*ConflictReport
+	for _, node := range listPrefix(r.ListNodes, prefix) {
+		report, err := ReportConflicts(r, f, strings.TrimPrefix(node, "src:"), base)
+		if err != nil {
+			return nil, err
+		}
+		if len(report.Conflicts) > 0 {
+			reports = append(reports, report)
+		}
+	}
+	return reports, nil
+}
+
+func commitInfo(r Repo, commit string) CommitInfo {
+	info := CommitInfo{Hash: commit}
+	if md := r.GetCommitMetadata(commit); md != nil {
+		info.Author = md.Author
+		info.Committer = md.Committer
+		info.Timestamp = md.Timestamp
+		info.Message = md.Message
+	}
+	return info
+}
+
+// HashConflict hashes where c is and the commits in it.  Lines keep their hashes when nodes are split,
+// so the hash stays the same as other commits change the rest of the file, until one of them changes
+// the lines around c or the set of commits that conflict.
+func (a HashAlgorithm) HashConflict(c Conflict) string {
+	h := a.hasher()
+	var commits []string
+	for commit := range c.Commits {
+		commits = append(commits, commit)
+	}
+	sort.Strings(commits)
+	for _, s := range append([]string{c.Start, c.End}, commits...) {
+		binary.Write(h, binary.LittleEndian, uint32(len(s)))
+		h.Write([]byte(s))
+	}
+	return h.Sum()
+}
@@ -0,0 +1,129 @@
+package graph_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/runningwild/jig/graph"
+	jpb "github.com/runningwild/jig/proto"
+	"github.com/runningwild/jig/testutils"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReportConflicts(t *testing.T) {
+	Convey("ReportConflicts", t, func() {
+		r := testutils.MakeFakeRepo()
+		c0, err := graph.ChangeFiles(r, allFrontier{}, nil, []graph.FileChange{
+			{Path: "a.txt", Content: stringsToContent("zero", "alpha", "bravo", "charlie", "delta")},
+			{Path: "b.txt", Content: stringsToContent("alpha")},
+		})
+		So(err, ShouldBeNil)
+		So(graph.Apply(r, c0), ShouldBeNil)
+		h0 := r.HashAlgorithm().HashCommit(c0)
+
+		by := func(author string) *jpb.CommitMetadata {
+			return &jpb.CommitMetadata{Author: author, Timestamp: 1234, Message: "edit by " + author}
+		}
+		h1 := editFile(r, explicitFrontierStrings(h0), by("one"), "a.txt", "zero", "alpha", "BRAVO", "charlie", "delta")
+		h2 := editFile(r, explicitFrontierStrings(h0), by("two"), "a.txt", "zero", "alpha", "bravo!", "charlie", "delta")
+		f := explicitFrontierStrings(h0, h1, h2)
+
+		// shouldMatchFile checks the report's line numbers against the file HumanReadable writes.
+		shouldMatchFile := func(report *graph.ConflictReport, base bool) {
+			v, err := graph.ReadFile(r, f, "a.txt", nil)
+			So(err, ShouldBeNil)
+			data, err := graph.HumanReadable(r, f, "a.txt", v.Conflicts, []byte("\n"), base)
+			So(err, ShouldBeNil)
+			lines := strings.Split(string(data), "\n")
+			for _, c := range report.Conflicts {
+				So(lines[c.StartLine-1], ShouldEqual, "<<<<<<<")
+				So(lines[c.EndLine-1], ShouldEqual, ">>>>>>>")
+				groups := c.Groups
+				if c.Base != nil {
+					groups = append([]graph.ConflictGroup{*c.Base}, groups...)
+				}
+				for _, g := range groups {
+					So(lines[g.StartLine-1:g.EndLine], ShouldResemble, g.Lines)
+				}
+			}
+		}
+
+		Convey("describes each version and the commits in it", func() {
+			report, err := graph.ReportConflicts(r, f, "a.txt", false)
+			So(err, ShouldBeNil)
+			So(report.Path, ShouldEqual, "a.txt")
+			So(report.Conflicts, ShouldHaveLength, 1)
+			c := report.Conflicts[0]
+			So(c.Kind, ShouldEqual, "edit")
+			So(c.StartLine, ShouldEqual, 2)
+			So(c.EndLine, ShouldEqual, 11)
+			So(c.Base, ShouldBeNil)
+			So(c.Groups, ShouldHaveLength, 2)
+			So(c.Groups[0].StartLine, ShouldEqual, 4)
+			So(c.Groups[0].EndLine, ShouldEqual, 6)
+			So(c.Groups[1].StartLine, ShouldEqual, 8)
+			So(c.Groups[1].EndLine, ShouldEqual, 10)
+			for _, g := range c.Groups {
+				So(g.Commits, ShouldHaveLength, 1)
+				So(g.Commits[0].Timestamp, ShouldEqual, 1234)
+				So(g.Commits[0].Message, ShouldEqual, "edit by "+g.Commits[0].Author)
+				switch g.Commits[0].Author {
+				case "one":
+					So(g.Commits[0].Hash, ShouldEqual, h1)
+					So(g.Lines, ShouldResemble, []string{"alpha", "BRAVO", "charlie"})
+				case "two":
+					So(g.Commits[0].Hash, ShouldEqual, h2)
+					So(g.Lines, ShouldResemble, []string{"alpha", "bravo!", "charlie"})
+				default:
+					t.Errorf("unexpected author %q", g.Commits[0].Author)
+				}
+			}
+			shouldMatchFile(report, false)
+		})
+
+		Convey("includes the base when asked to", func() {
+			report, err := graph.ReportConflicts(r, f, "a.txt", true)
+			So(err, ShouldBeNil)
+			So(report.Conflicts, ShouldHaveLength, 1)
+			c := report.Conflicts[0]
+			So(c.Base, ShouldNotBeNil)
+			So(c.Base.Lines, ShouldResemble, []string{"alpha", "bravo", "charlie"})
+			So(c.Base.Commits, ShouldBeEmpty)
+			So(c.EndLine, ShouldEqual, 15)
+			shouldMatchFile(report, true)
+		})
+
+		Convey("gives conflicts an ID that doesn't depend on the rest of the file", func() {
+			report, err := graph.ReportConflicts(r, f, "a.txt", false)
+			So(err, ShouldBeNil)
+
+			c3, err := graph.ChangeFiles(r, explicitFrontierStrings(h0), nil, []graph.FileChange{
+				{Path: "a.txt", Edit: true, Content: stringsToContent("first", "second", "zero", "alpha", "bravo", "charlie", "delta")},
+			})
+			So(err, ShouldBeNil)
+			So(graph.Apply(r, c3), ShouldBeNil)
+			f = explicitFrontierStrings(h0, h1, h2, r.HashAlgorithm().HashCommit(c3))
+			moved, err := graph.ReportConflicts(r, f, "a.txt", false)
+			So(err, ShouldBeNil)
+			So(moved.Conflicts, ShouldHaveLength, 1)
+			So(moved.Conflicts[0].ID, ShouldEqual, report.Conflicts[0].ID)
+			So(moved.Conflicts[0].StartLine, ShouldEqual, report.Conflicts[0].StartLine+2)
+			shouldMatchFile(moved, false)
+
+			v, err := graph.ReadFile(r, f, "a.txt", nil)
+			So(err, ShouldBeNil)
+			So(moved.Conflicts[0].ID, ShouldEqual, r.HashAlgorithm().HashConflict(v.Conflicts[0]))
+			other := v.Conflicts[0]
+			other.Commits = map[string]bool{h1: true}
+			So(r.HashAlgorithm().HashConflict(other), ShouldNotEqual, moved.Conflicts[0].ID)
+		})
+
+		Convey("has no conflicts for a clean file", func() {
+			report, err := graph.ReportConflicts(r, f, "b.txt", false)
+			So(err, ShouldBeNil)
+			So(report.Conflicts, ShouldNotBeNil)
+			So(report.Conflicts, ShouldBeEmpty)
+		})
+	})
+}
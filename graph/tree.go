@@ -0,0 +1,368 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	jpb "github.com/runningwild/jig/proto"
+)
+
+// DeletedNode is the node that a file's src node leads to after the file has been deleted.  It is
+// shared by every file, and is created by Apply the first time anything is deleted.
+const DeletedNode = "0"
+
+var (
+	ErrBadPath         = errors.New("invalid path")
+	ErrFileExists      = errors.New("file already exists")
+	ErrFileNotFound    = errors.New("file does not exist")
+	ErrPathConflict    = errors.New("path is both a file and a directory")
+	ErrConflictMarkers = errors.New("conflict markers were left in")
+)
+
+// ValidatePath returns an error if path can't be used to identify a file.  Paths are made of one or
+// more components separated by '/'.  A directory exists exactly when some file within it exists, so
+// there is no way to create or delete a directory on its own.
+func ValidatePath(path string) error {
+	if path == "" {
+		return fmt.Errorf("empty path: %w", ErrBadPath)
+	}
+	for _, component := range strings.Split(path, "/") {
+		if component == "" || component == "." || component == ".." {
+			return fmt.Errorf("%q: %w", path, ErrBadPath)
+		}
+	}
+	if strings.ContainsRune(path, 0) {
+		return fmt.Errorf("%q contains a null byte: %w", path, ErrBadPath)
+	}
+	return nil
+}
+
+// FileExists returns true iff the file at path was created and not since deleted as of f.
+func FileExists(r Repo, f Frontier, path string) (bool, error) {
+	state, err := statFile(r, f, path)
+	return state == FilePresent, err
+}
+
+// statFile returns FileAbsent if the file at path was never created as of f, FileDeleted if it was
+// deleted or renamed, and FilePresent otherwise.  It doesn't read the file, so it can't tell whether
+// it is empty or conflicted.
+func statFile(r Repo, f Frontier, path string) (FileState, error) {
+	n := r.GetNode("src:" + path)
+	if n == nil {
+		return FileAbsent, nil
+	}
+	return srcState(f, n)
+}
+
+// srcState is statFile for the file whose src node is n.
+func srcState(f Frontier, n *jpb.Node) (FileState, error) {
+	for i := len(n.Out) - 1; i >= 0; i-- {
+		obs, err := f.Observes(n.Out[i].Commit)
+		if err != nil {
+			return FileAbsent, err
+		}
+		if obs {
+			if n.Out[i].Node == DeletedNode {
+				return FileDeleted, nil
+			}
+			return FilePresent, nil
+		}
+	}
+	return FileAbsent, nil
+}
+
+// nextEdge returns the edge out of n that a reader should follow, which is the last one that f
+// observes, or nil if there isn't one.  Deletions are never followed, they only matter when deciding
+// whether a file exists.  renames holds the commits that renamed the file the reader is following
+// to get it to n.  Once a file has been renamed its old name may be reused for a new file, so on the
+// src and snk nodes of the old name, edges from commits that came after the rename are ignored.
+func nextEdge(r Repo, f Frontier, n *jpb.Node, renames []string) (*jpb.Edge, error) {
+	var rename string
+	if len(renames) > 0 && (n.GetSrc() != nil || n.GetSnk() != nil) {
+		rename = renames[len(renames)-1]
+	}
+	for i := len(n.Out) - 1; i >= 0; i-- {
+		e := n.Out[i]
+		if e.Node == DeletedNode {
+			continue
+		}
+		obs, err := f.Observes(e.Commit)
+		if err != nil {
+			return nil, err
+		}
+		if !obs {
+			continue
+		}
+		if rename != "" && e.Commit != rename && depClosure(r, []string{e.Commit})[rename] {
+			continue
+		}
+		return e, nil
+	}
+	return nil, nil
+}
+
+// followRenames returns the renames a reader has followed after it takes e out of n.  A rename is
+// entered at the new name's src node, and exited at the old name's snk node.
+func followRenames(n *jpb.Node, e *jpb.Edge, renames []string) []string {
+	switch {
+	case n.GetSrc() != nil && strings.HasPrefix(e.Node, "src:"):
+		return append(renames, e.Commit)
+	case n.GetSnk() != nil && strings.HasPrefix(e.Node, "snk:") && len(renames) > 0:
+		return renames[0 : len(renames)-1]
+	}
+	return renames
+}
+
+// ListFiles returns the path of every file within dir that exists as of f, in sorted order.  If dir
+// is empty every file is listed.
+func ListFiles(r Repo, f Frontier, dir string) ([]string, error) {
+	prefix := "src:"
+	if dir != "" {
+		prefix += dir + "/"
+	}
+	var files []string
+	for _, node := range listPrefix(r.ListNodes, prefix) {
+		path := strings.TrimPrefix(node, "src:")
+		exists, err := FileExists(r, f, path)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// A TreeEntry is a file or directory immediately within another directory.
+type TreeEntry struct {
+	Name string
+	Dir  bool
+}
+
+// ReadDir returns every file and directory immediately within dir as of f, sorted by name.  If dir
+// is empty the top level of the tree is listed.
+func ReadDir(r Repo, f Frontier, dir string) ([]TreeEntry, error) {
+	files, err := ListFiles(r, f, dir)
+	if err != nil {
+		return nil, err
+	}
+	var entries []TreeEntry
+	for _, path := range files {
+		if dir != "" {
+			path = strings.TrimPrefix(path, dir+"/")
+		}
+		entry := TreeEntry{Name: path}
+		if i := strings.Index(path, "/"); i >= 0 {
+			entry = TreeEntry{Name: path[0:i], Dir: true}
+		}
+		if len(entries) > 0 && entries[len(entries)-1] == entry {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+// A FileChange creates, deletes, renames, or copies a single file.
+type FileChange struct {
+	Path string
+
+	// If Delete is set the file is deleted.  If RenamedFrom is set the file at that path is renamed
+	// to Path, which keeps its history, and edits made concurrently to the file under its old name
+	// will show up under its new name.  If CopiedFrom is set the file is created with the current
+	// content of that file, but since the two may diverge the copy shares none of its history.  If
+	// Edit is set the file, which must already exist, is changed to have Content, and the lines it
+	// has in common with Content keep their history.  Editing a file with conflicts resolves them
+	// with whatever Content has in their place, so Content can't have any conflict markers left in
+	// it.  A file that was deleted while it was being edited can be edited or deleted again to
+	// resolve the conflict.  Otherwise the file is created with Content.
+	Delete      bool
+	RenamedFrom string
+	CopiedFrom  string
+	Edit        bool
+	Content     [][]byte
+}
+
+// ChangeFiles returns a single commit that makes every one of changes to the tree as of f.  Since it
+// is one commit, either all of the changes are applied or none of them are.  The commit depends on
+// deps, which should include whatever commits f observes that the changes rely on.  Files that
+// already exist can't be created, and files that don't exist can't be deleted or edited, and the
+// resulting tree can't have a path that is both a file and a directory.  Edits and deletions also
+// add every commit that the file's content came from to the commit's deps, and deleting a file that
+// has a DeleteEditConflict adds every commit in it.
+func ChangeFiles(r Repo, f Frontier, deps []string, changes []FileChange) (*jpb.Commit, error) {
+	existing, err := ListFiles(r, f, "")
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]bool)
+	for _, path := range existing {
+		files[path] = true
+	}
+
+	c := &jpb.Commit{Deps: append([]string(nil), deps...)}
+	hasDep := make(map[string]bool)
+	for _, dep := range deps {
+		hasDep[dep] = true
+	}
+	addDeps := func(commits []string) {
+		for _, dep := range commits {
+			if !hasDep[dep] {
+				hasDep[dep] = true
+				c.Deps = append(c.Deps, dep)
+			}
+		}
+	}
+
+	// deleteEditConflict returns the commits in the DeleteEditConflict on the file at path, or nil
+	// if it doesn't have one.  Such a file doesn't exist, but it can be edited or deleted again.
+	deleteEditConflict := func(path string) ([]string, error) {
+		if files[path] {
+			return nil, nil
+		}
+		v, err := ReadFile(r, f, path, nil)
+		if err != nil || v.State != FileConflicted {
+			return nil, err
+		}
+		var commits []string
+		for commit := range v.Conflicts[0].Commits {
+			commits = append(commits, commit)
+		}
+		sort.Strings(commits)
+		return commits, nil
+	}
+
+	changed := make(map[string]bool)
+	for _, change := range changes {
+		if err := ValidatePath(change.Path); err != nil {
+			return nil, err
+		}
+		if changed[change.Path] {
+			return nil, fmt.Errorf("%q was changed more than once", change.Path)
+		}
+		changed[change.Path] = true
+		if change.Delete {
+			conflicted, err := deleteEditConflict(change.Path)
+			if err != nil {
+				return nil, fmt.Errorf("cannot delete %q: %w", change.Path, err)
+			}
+			if !files[change.Path] && conflicted == nil {
+				return nil, fmt.Errorf("cannot delete %q: %w", change.Path, ErrFileNotFound)
+			}
+			addDeps(conflicted)
+			if files[change.Path] {
+				// The deletion only conflicts with edits it doesn't know about.
+				commits := make(map[string]bool)
+				if _, err := ReadFile(r, f, change.Path, &ReadMetadata{Commits: commits}); err != nil {
+					return nil, fmt.Errorf("cannot delete %q: %w", change.Path, err)
+				}
+				var fileDeps []string
+				for commit := range commits {
+					fileDeps = append(fileDeps, commit)
+				}
+				sort.Strings(fileDeps)
+				addDeps(fileDeps)
+			}
+			delete(files, change.Path)
+			c.EdgeRefs = append(c.EdgeRefs, &jpb.EdgeRef{
+				Src: &jpb.NodeRef{Node: "src:" + change.Path, Depth: 1},
+				Dst: &jpb.NodeRef{Node: DeletedNode},
+			})
+			continue
+		}
+		if change.Edit {
+			conflicted, err := deleteEditConflict(change.Path)
+			if err != nil {
+				return nil, fmt.Errorf("cannot edit %q: %w", change.Path, err)
+			}
+			if !files[change.Path] && conflicted == nil {
+				return nil, fmt.Errorf("cannot edit %q: %w", change.Path, ErrFileNotFound)
+			}
+			files[change.Path] = true
+			edges, editDeps, err := diffFile(r, f, change.Path, change.Content)
+			if err != nil {
+				return nil, fmt.Errorf("cannot edit %q: %w", change.Path, err)
+			}
+			c.EdgeRefs = append(c.EdgeRefs, edges...)
+			addDeps(editDeps)
+			continue
+		}
+		if files[change.Path] {
+			return nil, fmt.Errorf("cannot create %q: %w", change.Path, ErrFileExists)
+		}
+		files[change.Path] = true
+
+		if from := change.RenamedFrom; from != "" {
+			if !files[from] || changed[from] {
+				return nil, fmt.Errorf("cannot rename %q: %w", from, ErrFileNotFound)
+			}
+			changed[from] = true
+			delete(files, from)
+			c.EdgeRefs = append(c.EdgeRefs,
+				&jpb.EdgeRef{
+					Src: &jpb.NodeRef{Node: "src:" + change.Path, Depth: 1},
+					Dst: &jpb.NodeRef{Node: "src:" + from},
+				},
+				&jpb.EdgeRef{
+					Src: &jpb.NodeRef{Node: "snk:" + from, Depth: 1},
+					Dst: &jpb.NodeRef{Node: "snk:" + change.Path},
+				},
+				&jpb.EdgeRef{
+					Src: &jpb.NodeRef{Node: "src:" + from, Depth: 1},
+					Dst: &jpb.NodeRef{Node: DeletedNode},
+				})
+			continue
+		}
+
+		content := change.Content
+		if from := change.CopiedFrom; from != "" {
+			if !files[from] || changed[from] {
+				return nil, fmt.Errorf("cannot copy %q: %w", from, ErrFileNotFound)
+			}
+			v, err := ReadFile(r, f, from, nil)
+			if err != nil {
+				return nil, fmt.Errorf("cannot copy %q: %w", from, err)
+			}
+			content = v.Lines
+		}
+		c.EdgeRefs = append(c.EdgeRefs, &jpb.EdgeRef{
+			Src:    &jpb.NodeRef{Node: "src:" + change.Path, Depth: 1},
+			Chunks: content,
+			Dst:    &jpb.NodeRef{Node: "snk:" + change.Path},
+		})
+	}
+
+	for path := range files {
+		components := strings.Split(path, "/")
+		for i := 1; i < len(components); i++ {
+			if dir := strings.Join(components[0:i], "/"); files[dir] {
+				return nil, fmt.Errorf("%q and %q: %w", dir, path, ErrPathConflict)
+			}
+		}
+	}
+	return c, nil
+}
+
+// listPrefix is like listAll but only returns the keys that start with prefix.
+func listPrefix(list func(start string, dst []string) int, prefix string) []string {
+	var all []string
+	buf := make([]string, 100)
+	start := prefix
+	for {
+		n := list(start, buf)
+		for _, key := range buf[0:n] {
+			if !strings.HasPrefix(key, prefix) {
+				return all
+			}
+			all = append(all, key)
+		}
+		if n < len(buf) {
+			return all
+		}
+		start = buf[n-1] + "\x00"
+	}
+}
@@ -0,0 +1,237 @@
+package graph_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/runningwild/jig/graph"
+	jpb "github.com/runningwild/jig/proto"
+	"github.com/runningwild/jig/testutils"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTree(t *testing.T) {
+	Convey("The file tree", t, func() {
+		r := testutils.MakeFakeRepo()
+		c0, err := graph.ChangeFiles(r, allFrontier{}, nil, []graph.FileChange{
+			{Path: "README", Content: stringsToContent("read", "me")},
+			{Path: "src/main.go", Content: stringsToContent("package main")},
+			{Path: "src/util/util.go", Content: stringsToContent("package util")},
+			{Path: "empty"},
+		})
+		So(err, ShouldBeNil)
+		So(graph.Apply(r, c0), ShouldBeNil)
+		f0 := explicitFrontier(c0)
+
+		Convey("lists every file created in a single commit", func() {
+			files, err := graph.ListFiles(r, f0, "")
+			So(err, ShouldBeNil)
+			So(files, ShouldResemble, []string{"README", "empty", "src/main.go", "src/util/util.go"})
+			files, err = graph.ListFiles(r, f0, "src")
+			So(err, ShouldBeNil)
+			So(files, ShouldResemble, []string{"src/main.go", "src/util/util.go"})
+			So(snippet{r, f0, "src:README", "snk:README"}, shouldRead, "read.me")
+			So(snippet{r, f0, "src:empty", "snk:empty"}, shouldRead, "")
+		})
+
+		Convey("lists directories", func() {
+			entries, err := graph.ReadDir(r, f0, "")
+			So(err, ShouldBeNil)
+			So(entries, ShouldResemble, []graph.TreeEntry{{Name: "README"}, {Name: "empty"}, {Name: "src", Dir: true}})
+			entries, err = graph.ReadDir(r, f0, "src")
+			So(err, ShouldBeNil)
+			So(entries, ShouldResemble, []graph.TreeEntry{{Name: "main.go"}, {Name: "util", Dir: true}})
+		})
+
+		Convey("only contains files observed by the frontier", func() {
+			files, err := graph.ListFiles(r, explicitFrontierStrings(), "")
+			So(err, ShouldBeNil)
+			So(files, ShouldBeEmpty)
+		})
+
+		Convey("deletes and creates files atomically", func() {
+			c1, err := graph.ChangeFiles(r, f0, []string{r.HashAlgorithm().HashCommit(c0)}, []graph.FileChange{
+				{Path: "src/util/util.go", Delete: true},
+				{Path: "README", Delete: true},
+				{Path: "src/util", Content: stringsToContent("now a file")},
+			})
+			So(err, ShouldBeNil)
+			So(graph.Apply(r, c1), ShouldBeNil)
+			So(graph.Check(r), ShouldBeEmpty)
+			f1 := explicitFrontier(c0, c1)
+			files, err := graph.ListFiles(r, f1, "")
+			So(err, ShouldBeNil)
+			So(files, ShouldResemble, []string{"empty", "src/main.go", "src/util"})
+			v, err := graph.ReadFile(r, f1, "README", nil)
+			So(err, ShouldBeNil)
+			So(v.State, ShouldEqual, graph.FileDeleted)
+			So(snippet{r, f0, "src:README", "snk:README"}, shouldRead, "read.me")
+
+			Convey("after which they can be created again", func() {
+				c2, err := graph.ChangeFiles(r, f1, []string{r.HashAlgorithm().HashCommit(c1)}, []graph.FileChange{
+					{Path: "README", Content: stringsToContent("new")},
+				})
+				So(err, ShouldBeNil)
+				So(graph.Apply(r, c2), ShouldBeNil)
+				So(snippet{r, explicitFrontier(c0, c1, c2), "src:README", "snk:README"}, shouldRead, "new")
+			})
+		})
+
+		Convey("rejects invalid changes", func() {
+			for _, change := range []graph.FileChange{
+				{Path: "README"},
+				{Path: "missing", Delete: true},
+				{Path: "README/foo"},
+				{Path: "src"},
+				{Path: "/abs"},
+				{Path: "a//b"},
+				{Path: "a/../b"},
+			} {
+				_, err := graph.ChangeFiles(r, f0, nil, []graph.FileChange{change})
+				So(err, ShouldNotBeNil)
+			}
+		})
+
+		Convey("rejects deletions that aren't from a file's src node", func() {
+			err := graph.Apply(r, &jpb.Commit{
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
+				EdgeRefs: []*jpb.EdgeRef{
+					{Src: &jpb.NodeRef{Node: "src:README", Depth: 2}, Dst: &jpb.NodeRef{Node: graph.DeletedNode}},
+				},
+			})
+			So(errors.Is(err, graph.ErrBadDeletion), ShouldBeTrue)
+		})
+	})
+}
+
+func TestRename(t *testing.T) {
+	Convey("Renaming a file", t, func() {
+		r := testutils.MakeFakeRepo()
+		c0, err := graph.ChangeFiles(r, allFrontier{}, nil, []graph.FileChange{
+			{Path: "old.txt", Content: stringsToContent("alpha", "bravo", "charlie")},
+		})
+		So(err, ShouldBeNil)
+		So(graph.Apply(r, c0), ShouldBeNil)
+		f0 := explicitFrontier(c0)
+		var ranges []graph.ReadRange
+		_, err = graph.ReadFile(r, f0, "old.txt", &graph.ReadMetadata{Ranges: &ranges})
+		So(err, ShouldBeNil)
+		head := ranges[0].Node
+
+		c1, err := graph.ChangeFiles(r, f0, []string{r.HashAlgorithm().HashCommit(c0)}, []graph.FileChange{
+			{Path: "new.txt", RenamedFrom: "old.txt"},
+		})
+		So(err, ShouldBeNil)
+		So(graph.Apply(r, c1), ShouldBeNil)
+		So(graph.Check(r), ShouldBeEmpty)
+		f1 := explicitFrontier(c0, c1)
+
+		shouldReadFile := func(f graph.Frontier, path, want string) {
+			v, err := graph.ReadFile(r, f, path, nil)
+			So(err, ShouldBeNil)
+			So(v.State, ShouldEqual, graph.FilePresent)
+			So(contentToString(v.Lines), ShouldEqual, want)
+		}
+
+		Convey("makes it visible under only the new name", func() {
+			files, err := graph.ListFiles(r, f1, "")
+			So(err, ShouldBeNil)
+			So(files, ShouldResemble, []string{"new.txt"})
+			shouldReadFile(f1, "new.txt", "alpha.bravo.charlie")
+			v, err := graph.ReadFile(r, f1, "old.txt", nil)
+			So(err, ShouldBeNil)
+			So(v.State, ShouldEqual, graph.FileDeleted)
+			shouldReadFile(f0, "old.txt", "alpha.bravo.charlie")
+		})
+
+		Convey("carries over concurrent edits to the old name", func() {
+			c2 := &jpb.Commit{
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
+				EdgeRefs: []*jpb.EdgeRef{
+					{
+						Src:    &jpb.NodeRef{Node: "src:old.txt", Depth: 1},
+						Chunks: stringsToContent("first"),
+						Dst:    &jpb.NodeRef{Node: head, Depth: 0},
+					},
+					{
+						Src:    &jpb.NodeRef{Node: head, Depth: 1},
+						Chunks: stringsToContent("BRAVO"),
+						Dst:    &jpb.NodeRef{Node: head, Depth: 2},
+					},
+					{
+						Src:    &jpb.NodeRef{Node: head, Depth: 3},
+						Chunks: stringsToContent("last"),
+						Dst:    &jpb.NodeRef{Node: "snk:old.txt"},
+					},
+				},
+			}
+			So(graph.Apply(r, c2), ShouldBeNil)
+			shouldReadFile(explicitFrontier(c0, c2), "old.txt", "first.alpha.BRAVO.charlie.last")
+			shouldReadFile(explicitFrontier(c0, c1, c2), "new.txt", "first.alpha.BRAVO.charlie.last")
+		})
+
+		Convey("lets the old name be reused", func() {
+			c2, err := graph.ChangeFiles(r, f1, []string{r.HashAlgorithm().HashCommit(c1)}, []graph.FileChange{
+				{Path: "old.txt", Content: stringsToContent("unrelated")},
+			})
+			So(err, ShouldBeNil)
+			So(graph.Apply(r, c2), ShouldBeNil)
+			f2 := explicitFrontier(c0, c1, c2)
+			shouldReadFile(f2, "old.txt", "unrelated")
+			shouldReadFile(f2, "new.txt", "alpha.bravo.charlie")
+
+			Convey("even when the new name is renamed again", func() {
+				c3, err := graph.ChangeFiles(r, f2, []string{r.HashAlgorithm().HashCommit(c2)}, []graph.FileChange{
+					{Path: "newer.txt", RenamedFrom: "new.txt"},
+					{Path: "other.txt", RenamedFrom: "old.txt"},
+				})
+				So(err, ShouldBeNil)
+				So(graph.Apply(r, c3), ShouldBeNil)
+				f3 := explicitFrontier(c0, c1, c2, c3)
+				files, err := graph.ListFiles(r, f3, "")
+				So(err, ShouldBeNil)
+				So(files, ShouldResemble, []string{"newer.txt", "other.txt"})
+				shouldReadFile(f3, "newer.txt", "alpha.bravo.charlie")
+				shouldReadFile(f3, "other.txt", "unrelated")
+			})
+		})
+
+		Convey("can be inverted", func() {
+			inv, err := graph.Invert(r, r.HashAlgorithm().HashCommit(c1))
+			So(err, ShouldBeNil)
+			So(graph.Apply(r, inv), ShouldBeNil)
+			f2 := explicitFrontier(c0, c1, inv)
+			files, err := graph.ListFiles(r, f2, "")
+			So(err, ShouldBeNil)
+			So(files, ShouldResemble, []string{"old.txt"})
+			shouldReadFile(f2, "old.txt", "alpha.bravo.charlie")
+		})
+
+		Convey("can be unapplied", func() {
+			So(graph.Unapply(r, r.HashAlgorithm().HashCommit(c1)), ShouldBeNil)
+			So(graph.Check(r), ShouldBeEmpty)
+			So(r.GetNode("src:new.txt"), ShouldBeNil)
+			shouldReadFile(allFrontier{}, "old.txt", "alpha.bravo.charlie")
+		})
+	})
+
+	Convey("Copying a file", t, func() {
+		r := testutils.MakeFakeRepo()
+		c0, err := graph.ChangeFiles(r, allFrontier{}, nil, []graph.FileChange{
+			{Path: "a.txt", Content: stringsToContent("alpha", "bravo")},
+		})
+		So(err, ShouldBeNil)
+		So(graph.Apply(r, c0), ShouldBeNil)
+		c1, err := graph.ChangeFiles(r, explicitFrontier(c0), []string{r.HashAlgorithm().HashCommit(c0)}, []graph.FileChange{
+			{Path: "b.txt", CopiedFrom: "a.txt"},
+		})
+		So(err, ShouldBeNil)
+		So(graph.Apply(r, c1), ShouldBeNil)
+		f1 := explicitFrontier(c0, c1)
+		files, err := graph.ListFiles(r, f1, "")
+		So(err, ShouldBeNil)
+		So(files, ShouldResemble, []string{"a.txt", "b.txt"})
+		So(snippet{r, f1, "src:b.txt", "snk:b.txt"}, shouldRead, "alpha.bravo")
+	})
+}
@@ -0,0 +1,169 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+
+	jpb "github.com/runningwild/jig/proto"
+)
+
+var (
+	ErrUnknownCommit  = errors.New("unknown commit")
+	ErrHasReverseDeps = errors.New("other commits depend on it")
+	ErrCommitInUse    = errors.New("content is used by other commits")
+)
+
+// Unapply removes the commit specified by commitHash from r, undoing everything Apply did.  The
+// edges it added and the nodes it created are removed, as is the commit itself, and any nodes that
+// were split for it are merged back together unless some other commit still needs them to be split.
+// Commits that other commits depend on, or whose content other commits have edges to, can't be
+// unapplied.  Like Apply, it happens in a single transaction.
+func Unapply(r Repo, commitHash string) (err error) {
+	c := r.GetCommit(commitHash)
+	if c == nil {
+		return fmt.Errorf("commit %q: %w", commitHash, ErrUnknownCommit)
+	}
+	if rdeps := r.GetReverseDeps(commitHash); len(rdeps) > 0 {
+		return fmt.Errorf("cannot unapply %s, %w: %v", commitHash, ErrHasReverseDeps, rdeps)
+	}
+
+	// Nodes created by this commit, which may have since been split, all start with a join edge from
+	// it.  Any other node with an edge from it was only touched by it.
+	var created, touched []*jpb.Node
+	for _, head := range listAll(r.ListNodes) {
+		n := r.GetNode(head)
+		if n == nil {
+			continue
+		}
+		if createdBy(n) == commitHash {
+			for _, edges := range [][]*jpb.Edge{n.In, n.Out} {
+				for _, e := range edges {
+					if e.Commit != commitHash {
+						return fmt.Errorf("cannot unapply %s, commit %s has an edge to %q: %w", commitHash, e.Commit, n.Head, ErrCommitInUse)
+					}
+				}
+			}
+			created = append(created, n)
+			continue
+		}
+		if hasCommitEdge(n.In, commitHash) || hasCommitEdge(n.Out, commitHash) {
+			touched = append(touched, n)
+		}
+	}
+
+	r.StartTransaction()
+	defer endTransaction(r, &err)
+
+	for _, n := range created {
+		r.DeleteNode(n.Head)
+		r.DeleteRef(n.Tail)
+	}
+	for _, n := range touched {
+		n.In = withoutCommit(n.In, commitHash)
+		n.Out = withoutCommit(n.Out, commitHash)
+		if (n.GetSrc() != nil && len(n.Out) == 0) || (n.GetSnk() != nil && len(n.In) == 0) {
+			// Apply creates src and snk nodes as needed, so there's no reason to keep unused ones.
+			r.DeleteNode(n.Head)
+			r.DeleteRef(n.Tail)
+			continue
+		}
+		r.PutNode(n)
+	}
+	for _, n := range touched {
+		if r.GetNode(n.Head) != nil {
+			mergeSplits(r, n.Head)
+		}
+	}
+
+	for _, dep := range c.Deps {
+		r.DeleteReverseDep(commitHash, dep)
+	}
+	r.DeleteCommit(commitHash)
+	return nil
+}
+
+// mergeSplits merges node with the nodes before and after it that it was split from by SplitNode, as
+// long as no edge other than the join edges between them still requires them to be separate.
+func mergeSplits(r Repo, node string) {
+	n := r.GetNode(node)
+	for len(n.In) > 0 {
+		prev := r.GetNode(r.GetRef(n.In[0].Node))
+		if prev == nil || !canMerge(prev, n) {
+			break
+		}
+		n = prev
+	}
+	for len(n.Out) > 0 {
+		next := r.GetNode(n.Out[0].Node)
+		if next == nil || !canMerge(n, next) {
+			break
+		}
+		n = merge(r, n, next)
+	}
+}
+
+// canMerge returns true iff a and b are adjacent pieces of a node that was split, and the only edges
+// between them are the join edges added when they were split.
+func canMerge(a, b *jpb.Node) bool {
+	if createdBy(a) == "" || createdBy(a) != createdBy(b) || len(a.Out) == 0 {
+		return false
+	}
+	for _, e := range a.Out {
+		if !e.Join || e.Node != b.Head {
+			return false
+		}
+	}
+	for _, e := range b.In {
+		if !e.Join || e.Node != a.Tail {
+			return false
+		}
+	}
+	return true
+}
+
+// merge is the inverse of SplitNode, it replaces a and b with a single node and returns it.
+func merge(r Repo, a, b *jpb.Node) *jpb.Node {
+	var content [][]byte
+	content = append(content, r.GetContent(a.GetContentHash())...)
+	content = append(content, r.GetContent(b.GetContentHash())...)
+	n := &jpb.Node{
+		Head:    a.Head,
+		Tail:    b.Tail,
+		Content: &jpb.Node_ContentHash{ContentHash: r.PutContent(content)},
+		Count:   a.Count + b.Count,
+		In:      a.In,
+		Out:     b.Out,
+	}
+	r.PutNode(n) // This will overwrite a
+	r.DeleteNode(b.Head)
+	r.DeleteRef(a.Tail)
+	r.PutRef(b.Tail, n.Head)
+	return n
+}
+
+// createdBy returns the commit that created the content of n, or "" if n is a src or snk node.
+func createdBy(n *jpb.Node) string {
+	if n.GetContentHash() == "" || len(n.In) == 0 || !n.In[0].Join {
+		return ""
+	}
+	return n.In[0].Commit
+}
+
+func hasCommitEdge(edges []*jpb.Edge, commit string) bool {
+	for _, e := range edges {
+		if e.Commit == commit {
+			return true
+		}
+	}
+	return false
+}
+
+func withoutCommit(edges []*jpb.Edge, commit string) []*jpb.Edge {
+	var keep []*jpb.Edge
+	for _, e := range edges {
+		if e.Commit != commit {
+			keep = append(keep, e)
+		}
+	}
+	return keep
+}
@@ -0,0 +1,126 @@
+package graph_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/runningwild/jig/graph"
+	jpb "github.com/runningwild/jig/proto"
+	"github.com/runningwild/jig/testutils"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnapply(t *testing.T) {
+	Convey("Unapply", t, func() {
+		r := testutils.MakeFakeRepo()
+		c0 := &jpb.Commit{
+			EdgeRefs: []*jpb.EdgeRef{
+				{
+					Src:    &jpb.NodeRef{Node: "src:foo.txt", Depth: 1},
+					Chunks: stringsToContent("alpha", "bravo", "charlie", "delta", "echo"),
+					Dst:    &jpb.NodeRef{Node: "snk:foo.txt"},
+				},
+			},
+		}
+		So(graph.Apply(r, c0), ShouldBeNil)
+		head := firstRangeNode(r, c0)
+		nodes := listAll(r.ListNodes)
+		So(nodes, ShouldHaveLength, 3)
+
+		// c1 replaces bravo and deletes delta.
+		c1 := &jpb.Commit{
+			Deps: []string{r.HashAlgorithm().HashCommit(c0)},
+			EdgeRefs: []*jpb.EdgeRef{
+				{
+					Src:    &jpb.NodeRef{Node: head, Depth: 1},
+					Chunks: stringsToContent("BRAVO"),
+					Dst:    &jpb.NodeRef{Node: head, Depth: 2},
+				},
+				{
+					Src: &jpb.NodeRef{Node: head, Depth: 3},
+					Dst: &jpb.NodeRef{Node: head, Depth: 4},
+				},
+			},
+		}
+		So(graph.Apply(r, c1), ShouldBeNil)
+		So(snippet{r, allFrontier{}, "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.BRAVO.charlie.echo")
+
+		Convey("removes a commit and merges the nodes it split", func() {
+			So(graph.Unapply(r, r.HashAlgorithm().HashCommit(c1)), ShouldBeNil)
+			So(r.GetCommit(r.HashAlgorithm().HashCommit(c1)), ShouldBeNil)
+			So(r.GetReverseDeps(r.HashAlgorithm().HashCommit(c0)), ShouldBeEmpty)
+			So(listAll(r.ListNodes), ShouldResemble, nodes)
+			So(r.GetNode(head).Count, ShouldEqual, 5)
+			So(graph.Check(r), ShouldBeEmpty)
+			So(snippet{r, allFrontier{}, "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.bravo.charlie.delta.echo")
+
+			Convey("after which it can be applied again", func() {
+				So(graph.Apply(r, c1), ShouldBeNil)
+				So(snippet{r, allFrontier{}, "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.BRAVO.charlie.echo")
+			})
+		})
+
+		Convey("refuses to remove commits that others depend on", func() {
+			err := graph.Unapply(r, r.HashAlgorithm().HashCommit(c0))
+			So(errors.Is(err, graph.ErrHasReverseDeps), ShouldBeTrue)
+			So(r.GetCommit(r.HashAlgorithm().HashCommit(c0)), ShouldNotBeNil)
+		})
+
+		Convey("refuses to remove commits whose content others have edges to", func() {
+			// c2 doesn't declare a dependency on c1 even though it edits the content c1 added.
+			var bravo string
+			for _, e := range r.GetNode(head).Out {
+				if e.Commit == r.HashAlgorithm().HashCommit(c1) {
+					bravo = e.Node
+				}
+			}
+			c2 := &jpb.Commit{
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
+				EdgeRefs: []*jpb.EdgeRef{
+					{
+						Src:    &jpb.NodeRef{Node: bravo, Depth: 1},
+						Chunks: stringsToContent("foxtrot"),
+						Dst:    &jpb.NodeRef{Node: head, Depth: 2},
+					},
+				},
+			}
+			So(graph.Apply(r, c2), ShouldBeNil)
+			err := graph.Unapply(r, r.HashAlgorithm().HashCommit(c1))
+			So(errors.Is(err, graph.ErrCommitInUse), ShouldBeTrue)
+			So(r.GetCommit(r.HashAlgorithm().HashCommit(c1)), ShouldNotBeNil)
+		})
+
+		Convey("keeps splits that other commits still need", func() {
+			c2 := &jpb.Commit{
+				Deps: []string{r.HashAlgorithm().HashCommit(c0)},
+				EdgeRefs: []*jpb.EdgeRef{
+					{
+						Src:    &jpb.NodeRef{Node: head, Depth: 1},
+						Chunks: stringsToContent("foxtrot"),
+						Dst:    &jpb.NodeRef{Node: head, Depth: 2},
+					},
+				},
+			}
+			So(graph.Apply(r, c2), ShouldBeNil)
+			So(graph.Unapply(r, r.HashAlgorithm().HashCommit(c1)), ShouldBeNil)
+			So(graph.Check(r), ShouldBeEmpty)
+			So(snippet{r, allFrontier{}, "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.foxtrot.charlie.delta.echo")
+			So(snippet{r, explicitFrontier(c0), "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.bravo.charlie.delta.echo")
+			So(r.GetNode(head).Count, ShouldEqual, 1)
+		})
+
+		Convey("removes the last commit of a file entirely", func() {
+			So(graph.Unapply(r, r.HashAlgorithm().HashCommit(c1)), ShouldBeNil)
+			So(graph.Unapply(r, r.HashAlgorithm().HashCommit(c0)), ShouldBeNil)
+			So(listAll(r.ListNodes), ShouldBeEmpty)
+			So(listAll(r.ListRefs), ShouldBeEmpty)
+			So(listAll(r.ListCommits), ShouldBeEmpty)
+		})
+	})
+}
+
+func listAll(list func(start string, dst []string) int) []string {
+	buf := make([]string, 1000)
+	return buf[0:list("", buf)]
+}
@@ -0,0 +1,272 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	jpb "github.com/runningwild/jig/proto"
+)
+
+var (
+	ErrAlreadyApplied  = errors.New("commit has already been applied")
+	ErrMissingDep      = errors.New("missing dependency")
+	ErrMissingEndpoint = errors.New("missing src or dst")
+	ErrBadDepth        = errors.New("invalid depth")
+	ErrMissingNode     = errors.New("missing node")
+	ErrDuplicateEdge   = errors.New("duplicate edge")
+	ErrCycle           = errors.New("cycle")
+	ErrUnpairedJoin    = errors.New("unpaired join")
+	ErrBadDeletion     = errors.New("invalid deletion or rename")
+)
+
+// An InvalidCommitError is returned by Apply when a commit is malformed.  Err will wrap one of the
+// Err* values above that describes what is wrong with it.
+type InvalidCommitError struct {
+	// EdgeRef is the index of the offending EdgeRef, or -1 if the problem isn't specific to one.
+	EdgeRef int
+	Err     error
+}
+
+func (e *InvalidCommitError) Error() string {
+	if e.EdgeRef < 0 {
+		return fmt.Sprintf("invalid commit: %v", e.Err)
+	}
+	return fmt.Sprintf("invalid commit: EdgeRef %d: %v", e.EdgeRef, e.Err)
+}
+
+func (e *InvalidCommitError) Unwrap() error {
+	return e.Err
+}
+
+func invalidCommit(edgeRef int, format string, args ...interface{}) error {
+	return &InvalidCommitError{EdgeRef: edgeRef, Err: fmt.Errorf(format, args...)}
+}
+
+// validateCommit verifies that c can be applied to r without leaving r in an inconsistent state.  It
+// doesn't modify r.  Because every NodeRef must refer to a node that already exists (or to a src or
+// snk node that Apply will create), new content nodes can never connect to other new content nodes.
+func validateCommit(r Repo, commitHash string, c *jpb.Commit) error {
+	if r.GetCommit(commitHash) != nil {
+		return invalidCommit(-1, "%w", ErrAlreadyApplied)
+	}
+	for _, dep := range c.Deps {
+		if r.GetCommit(dep) == nil {
+			return invalidCommit(-1, "depends on %q which we don't have: %w", dep, ErrMissingDep)
+		}
+	}
+
+	// Each EdgeRef becomes an edge from the line its Src refers to, to the line its Dst refers to.
+	type line struct {
+		node  string
+		index int32
+	}
+	srcs := make(map[line]int)
+	dsts := make(map[line]int)
+	out := make(map[line][]int)
+	joins := make(map[string]int)
+	lines := make([][2]line, len(c.EdgeRefs))
+	for i, e := range c.EdgeRefs {
+		// New content nodes need both an input and an output edge.
+		if e.Src == nil || e.Dst == nil {
+			return invalidCommit(i, "%w", ErrMissingEndpoint)
+		}
+		if e.Src.Depth < 1 {
+			return invalidCommit(i, "src node at depth %d < 1: %w", e.Src.Depth, ErrBadDepth)
+		}
+		if e.Dst.Depth < 0 {
+			return invalidCommit(i, "dst node at depth %d < 0: %w", e.Dst.Depth, ErrBadDepth)
+		}
+
+		var src, dst line
+		if r.GetNode(e.Src.Node) == nil && strings.HasPrefix(e.Src.Node, "src:") && e.Src.Depth == 1 {
+			// Apply will create this node.
+			src = line{e.Src.Node, 0}
+		} else {
+			n, depth, err := findDepth(r, e.Src.Node, e.Src.Depth)
+			if err != nil {
+				return invalidCommit(i, "src: %w", err)
+			}
+			src = line{n.Head, depth - 1}
+		}
+		if e.Dst.Node == DeletedNode || (strings.HasPrefix(e.Dst.Node, "src:") && e.Dst.Depth == 0) || strings.HasPrefix(e.Src.Node, "snk:") {
+			if err := validateNameEdge(r, e); err != nil {
+				return invalidCommit(i, "%w", err)
+			}
+			if j, ok := srcs[src]; ok {
+				return invalidCommit(i, "has the same src as EdgeRef %d: %w", j, ErrDuplicateEdge)
+			}
+			srcs[src] = i
+			dst = line{e.Dst.Node, 0}
+			if dst.node != DeletedNode {
+				// Every deleted file shares the same deletion node, so those can't be duplicates.
+				if j, ok := dsts[dst]; ok {
+					return invalidCommit(i, "has the same dst as EdgeRef %d: %w", j, ErrDuplicateEdge)
+				}
+				dsts[dst] = i
+			}
+			out[src] = append(out[src], i)
+			lines[i] = [2]line{src, dst}
+			continue
+		}
+		if e.Dst.Depth == 0 {
+			if r.GetNode(e.Dst.Node) == nil && !strings.HasPrefix(e.Dst.Node, "snk:") {
+				return invalidCommit(i, "dst node %q: %w", e.Dst.Node, ErrMissingNode)
+			}
+			dst = line{e.Dst.Node, 0}
+		} else {
+			n, depth, err := findDepth(r, e.Dst.Node, e.Dst.Depth)
+			if err != nil {
+				return invalidCommit(i, "dst: %w", err)
+			}
+			if depth < n.Count {
+				dst = line{n.Head, depth}
+			} else if len(n.Out) > 0 {
+				dst = line{n.Out[0].Node, 0}
+			} else {
+				return invalidCommit(i, "dst node %q has nothing after it: %w", n.Head, ErrBadDepth)
+			}
+		}
+
+		// No two edges from this commit may leave from or arrive at the same place.
+		if j, ok := srcs[src]; ok {
+			return invalidCommit(i, "has the same src as EdgeRef %d: %w", j, ErrDuplicateEdge)
+		}
+		srcs[src] = i
+		if j, ok := dsts[dst]; ok {
+			return invalidCommit(i, "has the same dst as EdgeRef %d: %w", j, ErrDuplicateEdge)
+		}
+		dsts[dst] = i
+		out[src] = append(out[src], i)
+		lines[i] = [2]line{src, dst}
+
+		if e.Src.Join {
+			joins[e.Src.Node]++
+		}
+		if e.Dst.Join {
+			joins[e.Dst.Node]--
+		}
+	}
+
+	// Refs that specify joins must come in pairs: every edge that joins into a node needs an edge
+	// that joins out of it.
+	for i, e := range c.EdgeRefs {
+		for _, ref := range []*jpb.NodeRef{e.Src, e.Dst} {
+			if ref.Join && joins[ref.Node] != 0 {
+				return invalidCommit(i, "join on %q: %w", ref.Node, ErrUnpairedJoin)
+			}
+		}
+	}
+
+	// The edges in this commit can't form a cycle on their own.  Whether they form one along with the
+	// edges already in the file is checked by Apply once they are in place, see checkReads.
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[line]int)
+	var visit func(l line) error
+	visit = func(l line) error {
+		state[l] = visiting
+		for _, i := range out[l] {
+			next := lines[i][1]
+			switch state[next] {
+			case visiting:
+				return invalidCommit(i, "%w", ErrCycle)
+			case unvisited:
+				if err := visit(next); err != nil {
+					return err
+				}
+			}
+		}
+		state[l] = visited
+		return nil
+	}
+	for i := range c.EdgeRefs {
+		if state[lines[i][0]] == unvisited {
+			if err := visit(lines[i][0]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkReads walks the file from each of starts the way a reader that observes f would, and returns
+// an error wrapping ErrCycle if any walk revisits a node, along with the index of the start it came
+// from.  A reader always takes the newest edge it observes out of a node, so once a walk reaches a
+// node that an earlier walk got past without revisiting anything, the rest of it is known to be fine.
+func checkReads(r Repo, f Frontier, starts []string) (int, error) {
+	done := make(map[string]bool)
+	for i, start := range starts {
+		used := make(map[string]bool)
+		var renames []string
+		for n := r.GetNode(start); n != nil && !done[n.Head]; {
+			if used[n.Head] {
+				return i, fmt.Errorf("reading from %q reaches %q twice: %w", start, n.Head, ErrCycle)
+			}
+			used[n.Head] = true
+			e, err := nextEdge(r, f, n, renames)
+			if err != nil {
+				return i, err
+			}
+			if e == nil {
+				break
+			}
+			renames = followRenames(n, e, renames)
+			n = r.GetNode(e.Node)
+		}
+		for head := range used {
+			done[head] = true
+		}
+	}
+	return -1, nil
+}
+
+// validateNameEdge verifies an EdgeRef that changes which files exist rather than their content.
+// These go from a file's src node to the deletion node, from the src node of a file's new name to the
+// src node of its old name, or from the snk node of its old name to the snk node of its new name.
+func validateNameEdge(r Repo, e *jpb.EdgeRef) error {
+	if e.Src.Depth != 1 || e.Dst.Depth != 0 || len(e.Chunks) > 0 || e.Src.Join || e.Dst.Join {
+		return fmt.Errorf("deletions and renames must connect the ends of files directly: %w", ErrBadDeletion)
+	}
+	switch {
+	case e.Dst.Node == DeletedNode:
+		if !strings.HasPrefix(e.Src.Node, "src:") {
+			return fmt.Errorf("only a file's src node can lead to the deletion node: %w", ErrBadDeletion)
+		}
+		if r.GetNode(e.Src.Node) == nil {
+			return fmt.Errorf("cannot delete a file that was never created: %w", ErrBadDeletion)
+		}
+	case strings.HasPrefix(e.Dst.Node, "src:"):
+		if !strings.HasPrefix(e.Src.Node, "src:") {
+			return fmt.Errorf("only a src node can lead to another src node: %w", ErrBadDeletion)
+		}
+		if r.GetNode(e.Dst.Node) == nil {
+			return fmt.Errorf("cannot rename a file that was never created: %w", ErrBadDeletion)
+		}
+	default:
+		if !strings.HasPrefix(e.Dst.Node, "snk:") {
+			return fmt.Errorf("a snk node can only lead to another snk node: %w", ErrBadDeletion)
+		}
+	}
+	return nil
+}
+
+// findDepth follows primary edges from node the same way SplitNode does and returns the node that
+// depth ends up in, along with the remaining depth into that node.
+func findDepth(r Repo, node string, depth int32) (*jpb.Node, int32, error) {
+	var n *jpb.Node
+	for n = r.GetNode(node); n != nil && depth > n.Count && len(n.Out) > 0; n = r.GetNode(n.Out[0].Node) {
+		depth -= n.Count
+	}
+	if n == nil {
+		return nil, 0, fmt.Errorf("node %q: %w", node, ErrMissingNode)
+	}
+	if depth > n.Count {
+		return nil, 0, fmt.Errorf("depth is beyond original node's length: %w", ErrBadDepth)
+	}
+	return n, depth, nil
+}
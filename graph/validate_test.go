@@ -0,0 +1,175 @@
+package graph_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/runningwild/jig/graph"
+	jpb "github.com/runningwild/jig/proto"
+	"github.com/runningwild/jig/testutils"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestApplyValidation(t *testing.T) {
+	Convey("Apply", t, func() {
+		r := testutils.MakeFakeRepo()
+		c0 := &jpb.Commit{
+			EdgeRefs: []*jpb.EdgeRef{
+				{
+					Src:    &jpb.NodeRef{Node: "src:foo.txt", Depth: 1},
+					Chunks: stringsToContent("alpha", "bravo", "charlie", "delta", "echo"),
+					Dst:    &jpb.NodeRef{Node: "snk:foo.txt"},
+				},
+			},
+		}
+		So(graph.Apply(r, c0), ShouldBeNil)
+		head := firstRangeNode(r, c0)
+		deps := []string{r.HashAlgorithm().HashCommit(c0)}
+
+		// shouldReject applies c and verifies that it was rejected because of the specified EdgeRef,
+		// and that the repo was not modified.
+		shouldReject := func(c *jpb.Commit, edgeRef int, target error) {
+			err := graph.Apply(r, c)
+			So(err, ShouldNotBeNil)
+			var ice *graph.InvalidCommitError
+			So(errors.As(err, &ice), ShouldBeTrue)
+			So(ice.EdgeRef, ShouldEqual, edgeRef)
+			So(errors.Is(err, target), ShouldBeTrue)
+			So(graph.Check(r), ShouldBeEmpty)
+			So(r.GetNode(head).Count, ShouldEqual, 5)
+			So(snippet{r, allFrontier{}, "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.bravo.charlie.delta.echo")
+		}
+
+		Convey("rejects commits that were already applied", func() {
+			shouldReject(c0, -1, graph.ErrAlreadyApplied)
+		})
+
+		Convey("rejects commits with missing deps", func() {
+			shouldReject(&jpb.Commit{Deps: []string{"missing"}}, -1, graph.ErrMissingDep)
+		})
+
+		Convey("rejects EdgeRefs without a src or dst", func() {
+			shouldReject(&jpb.Commit{
+				Deps: deps,
+				EdgeRefs: []*jpb.EdgeRef{
+					{Src: &jpb.NodeRef{Node: head, Depth: 1}, Dst: &jpb.NodeRef{Node: head, Depth: 2}},
+					{Src: &jpb.NodeRef{Node: head, Depth: 3}, Chunks: stringsToContent("dangling")},
+				},
+			}, 1, graph.ErrMissingEndpoint)
+		})
+
+		Convey("rejects bad depths", func() {
+			shouldReject(&jpb.Commit{
+				Deps: deps,
+				EdgeRefs: []*jpb.EdgeRef{
+					{Src: &jpb.NodeRef{Node: head, Depth: 0}, Dst: &jpb.NodeRef{Node: head, Depth: 2}},
+				},
+			}, 0, graph.ErrBadDepth)
+			shouldReject(&jpb.Commit{
+				Deps: deps,
+				EdgeRefs: []*jpb.EdgeRef{
+					{Src: &jpb.NodeRef{Node: head, Depth: 1}, Dst: &jpb.NodeRef{Node: head, Depth: 3}},
+					{Src: &jpb.NodeRef{Node: head, Depth: 3}, Dst: &jpb.NodeRef{Node: head, Depth: -1}},
+				},
+			}, 1, graph.ErrBadDepth)
+			shouldReject(&jpb.Commit{
+				Deps: deps,
+				EdgeRefs: []*jpb.EdgeRef{
+					{Src: &jpb.NodeRef{Node: head, Depth: 1}, Dst: &jpb.NodeRef{Node: head, Depth: 3}},
+					{Src: &jpb.NodeRef{Node: head, Depth: 3}, Dst: &jpb.NodeRef{Node: head, Depth: 4}},
+					{Src: &jpb.NodeRef{Node: head, Depth: 20}, Dst: &jpb.NodeRef{Node: "snk:foo.txt"}},
+				},
+			}, 2, graph.ErrBadDepth)
+		})
+
+		Convey("rejects references to nodes that don't exist", func() {
+			shouldReject(&jpb.Commit{
+				Deps: deps,
+				EdgeRefs: []*jpb.EdgeRef{
+					{Src: &jpb.NodeRef{Node: head, Depth: 1}, Dst: &jpb.NodeRef{Node: "missing", Depth: 0}},
+				},
+			}, 0, graph.ErrMissingNode)
+		})
+
+		Convey("rejects two edges from the same place", func() {
+			shouldReject(&jpb.Commit{
+				Deps: deps,
+				EdgeRefs: []*jpb.EdgeRef{
+					{Src: &jpb.NodeRef{Node: head, Depth: 2}, Chunks: stringsToContent("one"), Dst: &jpb.NodeRef{Node: head, Depth: 3}},
+					{Src: &jpb.NodeRef{Node: head, Depth: 2}, Chunks: stringsToContent("two"), Dst: &jpb.NodeRef{Node: head, Depth: 4}},
+				},
+			}, 1, graph.ErrDuplicateEdge)
+		})
+
+		Convey("rejects two edges to the same place", func() {
+			shouldReject(&jpb.Commit{
+				Deps: deps,
+				EdgeRefs: []*jpb.EdgeRef{
+					{Src: &jpb.NodeRef{Node: head, Depth: 1}, Dst: &jpb.NodeRef{Node: head, Depth: 3}},
+					{Src: &jpb.NodeRef{Node: head, Depth: 2}, Dst: &jpb.NodeRef{Node: head, Depth: 3}},
+				},
+			}, 1, graph.ErrDuplicateEdge)
+		})
+
+		Convey("rejects cycles", func() {
+			shouldReject(&jpb.Commit{
+				Deps: deps,
+				EdgeRefs: []*jpb.EdgeRef{
+					{Src: &jpb.NodeRef{Node: head, Depth: 3}, Dst: &jpb.NodeRef{Node: head, Depth: 2}},
+				},
+			}, 0, graph.ErrCycle)
+			shouldReject(&jpb.Commit{
+				Deps: deps,
+				EdgeRefs: []*jpb.EdgeRef{
+					{Src: &jpb.NodeRef{Node: head, Depth: 1}, Dst: &jpb.NodeRef{Node: head, Depth: 3}},
+					{Src: &jpb.NodeRef{Node: head, Depth: 4}, Dst: &jpb.NodeRef{Node: head, Depth: 0}},
+				},
+			}, 1, graph.ErrCycle)
+
+			// This edge doesn't form a cycle on its own, but a reader that takes it from after delta
+			// comes back to delta through the file.
+			shouldReject(&jpb.Commit{
+				Deps: deps,
+				EdgeRefs: []*jpb.EdgeRef{
+					{Src: &jpb.NodeRef{Node: head, Depth: 4}, Dst: &jpb.NodeRef{Node: head, Depth: 1}},
+				},
+			}, 0, graph.ErrCycle)
+		})
+
+		Convey("rejects unpaired joins", func() {
+			shouldReject(&jpb.Commit{
+				Deps: deps,
+				EdgeRefs: []*jpb.EdgeRef{
+					{Src: &jpb.NodeRef{Node: head, Depth: 1}, Dst: &jpb.NodeRef{Node: head, Depth: 3, Join: true}},
+				},
+			}, 0, graph.ErrUnpairedJoin)
+		})
+
+		Convey("accepts valid commits", func() {
+			c1 := &jpb.Commit{
+				Deps: deps,
+				EdgeRefs: []*jpb.EdgeRef{
+					{Src: &jpb.NodeRef{Node: head, Depth: 1}, Chunks: stringsToContent("BRAVO"), Dst: &jpb.NodeRef{Node: head, Depth: 2}},
+					{Src: &jpb.NodeRef{Node: head, Depth: 3}, Dst: &jpb.NodeRef{Node: head, Depth: 4}},
+				},
+			}
+			So(graph.Apply(r, c1), ShouldBeNil)
+			So(snippet{r, allFrontier{}, "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.BRAVO.charlie.echo")
+		})
+
+		Convey("accepts commits that move lines back up the file", func() {
+			c1 := &jpb.Commit{
+				Deps: deps,
+				EdgeRefs: []*jpb.EdgeRef{
+					{Src: &jpb.NodeRef{Node: head, Depth: 1}, Dst: &jpb.NodeRef{Node: head, Depth: 3}},
+					{Src: &jpb.NodeRef{Node: head, Depth: 4}, Dst: &jpb.NodeRef{Node: head, Depth: 1}},
+					{Src: &jpb.NodeRef{Node: head, Depth: 3}, Dst: &jpb.NodeRef{Node: head, Depth: 4}},
+				},
+			}
+			So(graph.Apply(r, c1), ShouldBeNil)
+			So(graph.Check(r), ShouldBeEmpty)
+			So(snippet{r, allFrontier{}, "src:foo.txt", "snk:foo.txt"}, shouldRead, "alpha.delta.bravo.charlie.echo")
+		})
+	})
+}
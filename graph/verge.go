@@ -2,6 +2,7 @@ package graph
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -10,116 +11,266 @@ import (
 )
 
 type Verge struct {
-	r Repo
-	f Frontier
+	r    Repo
+	f    Frontier
+	path string
+
+	// Maps from commit hash to the node hashes at either end of that commit's edges on the verge.  A
+	// commit usually has one edge on the verge, but a commit that moves lines can have several.
+	forward, backward map[string]map[string]bool
 
-	// Maps from commit hash to node hash for each edge on the verge.
-	forward, backward map[string]string
+	// reverse holds the edges that lead back to an earlier part of the file, which is what a commit
+	// that moves lines makes.  The verge never crosses them, so the file can be treated as a DAG.
+	reverse map[edgeKey]bool
 
-	// rdeps is a sloppy representation of a subgraph of the dependecy graph.  We permanently track
-	// any edges that are added to rdeps, but we will add and remove nodes, which really means we
-	// changing which commits are being 'tracked' by the verge.
-	rdeps *simpleGraph
+	// commits holds the commits that have edges on the verge, and deps says which of them depend on
+	// which others.  Clones share deps.
+	commits map[string]bool
+	deps    *fileDeps
 }
 
-func MakeVerge(r Repo, f Frontier, path string) *Verge {
+// MakeVerge returns a Verge at the start of the file at path as of f.
+func MakeVerge(r Repo, f Frontier, path string) (*Verge, error) {
 	v := &Verge{
 		r:        r,
 		f:        f,
-		forward:  make(map[string]string),
-		backward: make(map[string]string),
-		rdeps:    makeSimpleGraph(),
+		path:     path,
+		forward:  make(map[string]map[string]bool),
+		backward: make(map[string]map[string]bool),
+		commits:  make(map[string]bool),
 	}
 	n := r.GetNode("src:" + path)
-	fmt.Printf("src node out edges: %v\n", n.Out)
-	for _, e := range n.Out {
-		v.forward[e.Commit] = e.Node
-		c := r.GetCommit(e.Commit)
-		fmt.Printf("Deps on src: %v\n", c.Deps)
-		v.rdeps.addNode(e.Commit)
-		for _, dep := range c.Deps {
-			v.rdeps.addEdge(dep, e.Commit)
+
+	// If the file was deleted and then created again, only the edges after the deletion are part of
+	// the file as it is now.
+	edges := n.Out
+	for i := len(edges) - 1; i >= 0; i-- {
+		obs, err := f.Observes(edges[i].Commit)
+		if err != nil {
+			return nil, err
+		}
+		if obs && edges[i].Node == DeletedNode {
+			edges = edges[i+1:]
+			break
+		}
+	}
+	for _, e := range edges {
+		obs, err := f.Observes(e.Commit)
+		if err != nil {
+			return nil, err
+		}
+		if !obs {
+			continue
 		}
+		addStrand(v.forward, e.Commit, e.Node)
+		v.commits[e.Commit] = true
+	}
+	reverse, file, err := findReverseEdges(r, f, n, edges)
+	if err != nil {
+		return nil, err
 	}
-	fmt.Printf("RDEPS: %v\n", v.rdeps)
-	return v
+	v.reverse = reverse
+	v.deps = &fileDeps{r: r, file: file, nearest: make(map[string][]string)}
+	return v, nil
 }
 
 func (v *Verge) Clone() *Verge {
 	v2 := &Verge{
 		r:        v.r,
 		f:        v.f,
-		forward:  make(map[string]string),
-		backward: make(map[string]string),
-		rdeps:    v.rdeps.Clone(),
+		path:     v.path,
+		forward:  make(map[string]map[string]bool),
+		backward: make(map[string]map[string]bool),
+		reverse:  v.reverse,
+		commits:  make(map[string]bool),
+		deps:     v.deps,
 	}
-	for commit, node := range v.forward {
-		v2.forward[commit] = node
+	for commit := range v.commits {
+		v2.commits[commit] = true
 	}
-	for commit, node := range v.backward {
-		v2.backward[commit] = node
+	for commit, nodes := range v.forward {
+		for node := range nodes {
+			addStrand(v2.forward, commit, node)
+		}
+	}
+	for commit, nodes := range v.backward {
+		for node := range nodes {
+			addStrand(v2.backward, commit, node)
+		}
 	}
 	return v2
 }
 
-// Advance advances the verge past node.  It panics if node is not a valid verge to advance past.
-func (v *Verge) Advance(node string) {
-	v.move(node, v.forwardMover())
+// addStrand records that an edge from commit ending at node is on the verge.
+func addStrand(edges map[string]map[string]bool, commit, node string) {
+	if edges[commit] == nil {
+		edges[commit] = make(map[string]bool)
+	}
+	edges[commit][node] = true
+}
+
+// An edgeKey identifies an edge by its commit, the tail of the node it leaves and the head of the
+// node it enters.
+type edgeKey struct {
+	commit, tail, head string
+}
+
+// findReverseEdges searches the file whose src node is src depth first, starting with edges, and
+// returns the edges it finds that lead back to a node it is still searching from.  Edges are taken in
+// the order they were added, so these are the edges that lead back to somewhere earlier in the file
+// as it was before their commits.  It also returns every commit with an edge that it takes.
+func findReverseEdges(r Repo, f Frontier, src *jpb.Node, edges []*jpb.Edge) (map[edgeKey]bool, map[string]bool, error) {
+	end := "snk:" + strings.TrimPrefix(src.Head, "src:")
+	reverse := make(map[edgeKey]bool)
+	commits := make(map[string]bool)
+	type frame struct {
+		n     *jpb.Node
+		edges []*jpb.Edge
+	}
+	searching := map[string]bool{src.Head: true}
+	done := make(map[string]bool)
+	stack := []frame{{src, edges}}
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if len(top.edges) == 0 {
+			delete(searching, top.n.Head)
+			done[top.n.Head] = true
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		e := top.edges[0]
+		top.edges = top.edges[1:]
+		if e.Node == DeletedNode {
+			continue
+		}
+		obs, err := f.Observes(e.Commit)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !obs {
+			continue
+		}
+		commits[e.Commit] = true
+		switch {
+		case searching[e.Node]:
+			reverse[edgeKey{e.Commit, top.n.Tail, e.Node}] = true
+		case !done[e.Node]:
+			n := r.GetNode(e.Node)
+			if n == nil {
+				return nil, nil, fmt.Errorf("%q: %w", e.Node, ErrMissingNode)
+			}
+			searching[n.Head] = true
+			var out []*jpb.Edge
+			if n.Head != end {
+				out = n.Out
+			}
+			stack = append(stack, frame{n, out})
+		}
+	}
+	return reverse, commits, nil
+}
+
+// Advance advances the verge past node, which should be one of the nodes that Next returns.
+func (v *Verge) Advance(node string) error {
+	return v.move(node, v.forwardMover())
 }
 
-// Retract retracts the verge past node.  It panics if node is not a valid verge to retract past.
-func (v *Verge) Retract(node string) {
-	v.move(node, v.backwardMover())
+// Retract retracts the verge past node, which should be one of the nodes that Prev returns.
+func (v *Verge) Retract(node string) error {
+	return v.move(node, v.backwardMover())
 }
 
 // move allows us to do Advance and Retract with the same code, all that's required is that we can
 // swap v.forward and v.backward, and that we can swap the in and out edges on all nodes.
-func (v *Verge) move(node string, mov mover) {
+func (v *Verge) move(node string, mov mover) error {
 	n := mov.GetNode(node)
-	fmt.Printf("Moving (%T) past %s\n", mov, n.Head)
-	fmt.Printf("In:  %v\n", mov.GetIn(n))
-	fmt.Printf("Out: %v\n", mov.GetOut(n))
+	if n == nil {
+		return fmt.Errorf("%q: %w", node, ErrMissingNode)
+	}
+	// If the verge followed a rename to get here the file's old name may have been reused, edges
+	// from commits that came after the rename belong to the new file with that name.
+	var renames []string
+	if n.GetSrc() != nil || n.GetSnk() != nil {
+		for _, e := range mov.GetIn(n) {
+			if mov.ForwardEdges()[e.Commit][mov.GetHead(n)] && (strings.HasPrefix(e.Node, "src:") || strings.HasPrefix(e.Node, "snk:")) {
+				renames = append(renames, e.Commit)
+			}
+		}
+	}
+	var passed []string
 	for _, e := range mov.GetIn(n) {
-		// We can ignore this edge if the frontier doesn't observe it, or if it is a join edge,
-		// since in that case that commit will continue through the entire node and the verge will
-		// still be cutting it after it has passed it.
+		// We can ignore this edge if the frontier doesn't observe it, or if it is a reverse edge,
+		// since the verge never puts those on itself.
 		obs, err := v.f.Observes(e.Commit)
 		if err != nil {
-			panic(err)
+			return err
 		}
-		if !obs || e.Join {
+		if !obs || v.reverse[mov.InKey(n, e)] {
 			continue
 		}
 
-		delete(mov.ForwardEdges(), e.Commit)
-		delete(mov.BackwardEdges(), e.Commit)
-		fmt.Printf("removing %s from rdeps\n", e.Commit)
-		v.rdeps.removeNode(e.Commit)
+		// If it is a join edge the commit will continue through the entire node, so the verge will
+		// still be cutting it after it has passed it, just with the edge out of the node instead.
+		delete(mov.ForwardEdges()[e.Commit], mov.GetHead(n))
+		delete(mov.BackwardEdges()[e.Commit], e.Node)
+		if !e.Join {
+			passed = append(passed, e.Commit)
+		}
 	}
 	for _, e := range mov.GetOut(n) {
+		if node == mov.End(v.path) {
+			// If the file was renamed this edge leads to the end of its new name, which isn't part of
+			// this file.
+			break
+		}
+		if e.Node == DeletedNode {
+			// Deletions are never read through, so they never end up on the verge.
+			continue
+		}
 		obs, err := v.f.Observes(e.Commit)
 		if err != nil {
-			panic(err)
+			return err
 		}
-		if !obs {
+		if !obs || v.reverse[mov.OutKey(n, e)] || afterRename(v.r, e.Commit, renames) {
 			continue
 		}
-		mov.ForwardEdges()[e.Commit] = mov.GetHead(mov.GetNode(e.Node))
-		mov.BackwardEdges()[e.Commit] = mov.GetTail(mov.GetNode(node))
-		v.rdeps.addNode(e.Commit)
-		for _, dep := range v.r.GetCommit(e.Commit).Deps {
-			fmt.Printf("adding %s -> %s to rdeps\n", e.Commit, dep)
-			v.rdeps.addEdge(dep, e.Commit)
+		dst := mov.GetNode(e.Node)
+		if dst == nil {
+			return fmt.Errorf("%q: %w", e.Node, ErrMissingNode)
+		}
+		addStrand(mov.ForwardEdges(), e.Commit, mov.GetHead(dst))
+		addStrand(mov.BackwardEdges(), e.Commit, mov.GetTail(n))
+		v.commits[e.Commit] = true
+	}
+	// A commit is only off the verge once the verge has passed the ends of all of its edges.
+	for _, commit := range passed {
+		if len(mov.ForwardEdges()[commit]) == 0 {
+			delete(mov.ForwardEdges(), commit)
+			delete(mov.BackwardEdges(), commit)
+			delete(v.commits, commit)
 		}
 	}
-	if strings.HasPrefix(node, "snk:") && len(v.rdeps.nodes) == 0 {
-		v.backward[""] = node
+	if strings.HasPrefix(node, "snk:") && len(v.commits) == 0 {
+		addStrand(v.backward, "", node)
+	}
+	if strings.HasPrefix(node, "src:") && len(v.commits) == 0 {
+		addStrand(v.forward, "", node)
 	}
-	if strings.HasPrefix(node, "src:") && len(v.rdeps.nodes) == 0 {
-		v.forward[""] = node
+	return nil
+}
+
+// afterRename returns true iff commit depends on any of renames.
+func afterRename(r Repo, commit string, renames []string) bool {
+	if len(renames) == 0 {
+		return false
+	}
+	deps := depClosure(r, []string{commit})
+	for _, rename := range renames {
+		if commit != rename && deps[rename] {
+			return true
+		}
 	}
-	fmt.Printf("Dominators: %v\n", v.rdeps.dominators())
-	fmt.Printf("Rdeps:\n%v\n", v.rdeps)
+	return false
 }
 
 func nodeContent(r Repo, node string) string {
@@ -131,103 +282,61 @@ func nodeContent(r Repo, node string) string {
 	return fmt.Sprintf("(%s) %s (%s)", n.Head, s, n.Tail)
 }
 
-func (v *Verge) state() {
-	var keys []string
-	for key := range v.forward {
-		keys = append(keys, key)
-	}
-	sort.Strings(keys)
-	// fmt.Printf("Current forward state (%d edges):\n", len(keys))
-	for _, key := range keys {
-		// var content string
-		ns := v.forward[key]
-		if r := v.r.GetRef(ns); r != "" {
-			ns = r
-		}
-		n := v.r.GetNode(ns)
-		if n != nil {
-			// content = string(bytes.Join(v.r.GetContent(n.Content), []byte(".")))
-		}
-		// fmt.Printf("Commit %s -> Node %s: %s\n", key, v.forward[key], content)
-	}
-}
-
-func (v *Verge) Conflicts() []string {
-	v.state()
-	visible := make(map[string]bool)
-	for c := range v.forward {
-		obs, err := v.f.Observes(c)
-		if err != nil {
-			panic(err)
-		}
-		if obs {
-			visible[c] = true
-		}
-	}
-	// fmt.Printf("Visible state: %v\n", visible)
+// Conflicts returns the commits on the verge that no other commit on the verge depends on, if there
+// is more than one of them.
+func (v *Verge) Conflicts() ([]string, error) {
 	// Find all commits that are not dominated by at least one other commit.
-	dominators := v.rdeps.dominators()
-	if len(dominators) == 0 && v.forward[""] == "" && v.backward[""] == "" {
-		fmt.Printf("%v\n", v.rdeps.nodes)
-		fmt.Printf("%v\n", v.rdeps.edges)
-		panic("no dominators is impossible")
+	dominators := v.deps.heads(v.commits)
+	if len(dominators) == 0 && len(v.forward[""]) == 0 && len(v.backward[""]) == 0 {
+		return nil, errors.New("no commits are on the verge")
 	}
 	if len(dominators) == 1 {
-		return nil
+		return nil, nil
 	}
-	return dominators
+	return dominators, nil
 }
 
-// TODO: A lot of this depends on the fact that a Verge can never cut two edges from the same commit
-// at the same time (excepting the reverse-edge situation).  This needs to be part of verification.
-// next returns a list of nodes that could be used as the next node the Verge can pass.
-// TODO: handle reverse edges
-func (v *Verge) Next() []string {
+// Next returns a list of nodes that could be used as the next node the Verge can pass.  Reverse
+// edges are ignored, so a commit that moves lines can have an edge on the verge for each place it
+// takes the file through.
+func (v *Verge) Next() ([]string, error) {
 	return v.look(v.forwardMover())
 }
 
-func (v *Verge) Prev() []string {
+// Prev is like Next, but returns the nodes that the Verge can retract past.
+func (v *Verge) Prev() ([]string, error) {
 	return v.look(v.backwardMover())
 }
 
-func (v *Verge) look(mov mover) []string {
+func (v *Verge) look(mov mover) ([]string, error) {
 	// Get a set of all nodes on the out end of all edges on the Verge.
 	dsts := make(map[string]bool)
-	for _, dst := range mov.ForwardEdges() {
-		dsts[dst] = true
+	for _, nodes := range mov.ForwardEdges() {
+		for dst := range nodes {
+			dsts[dst] = true
+		}
 	}
 
-	// var keys []string
-	// for key := range mov.ForwardEdges() {
-	// 	keys = append(keys, key)
-	// }
-	// sort.Strings(keys)
-
-	// fmt.Printf("Look Keys: %v\n", keys)
-
-	// TODO: verify the bold claim made regarding moves in the paragraph below.
-	// Acceptable nodes are ones whose inputs edges are all on the Verge.  The one exception is an
-	// incoming edge that comes from a commit with a forward edge currently on the verge, this is
-	// what happens in a move, and it is safe to advance in that case by just removing the forward
-	// edge from the verge.
+	// Acceptable nodes are ones whose input edges are all on the Verge, except for reverse edges,
+	// which the verge never cuts.
 	var good []string
 	for dst := range dsts {
 		n := mov.GetNode(dst)
+		if n == nil {
+			return nil, fmt.Errorf("%q: %w", dst, ErrMissingNode)
+		}
 		count := 0
 		observable := 0
 		for _, e := range mov.GetIn(n) {
 			obs, err := v.f.Observes(e.Commit)
 			if err != nil {
-				panic(err)
+				return nil, err
 			}
-			if !obs {
+			if !obs || v.reverse[mov.InKey(n, e)] {
 				continue
 			}
 			observable++
-			target := mov.ForwardEdges()[e.Commit]
-			// Why did I ever use rdeps to decide what an appropriate out edge was!?!?
-			// if target == dst || v.rdeps.nodes[e.Commit] {
-			if target == dst {
+			if mov.ForwardEdges()[e.Commit][dst] {
 				count++
 			}
 		}
@@ -236,7 +345,7 @@ func (v *Verge) look(mov mover) []string {
 		}
 		good = append(good, dst)
 	}
-	return good
+	return good, nil
 }
 
 // mover let's us write a function that goes forward through the graph and reuse it to do the same
@@ -247,10 +356,17 @@ type mover interface {
 	GetHead(n *jpb.Node) string
 	GetTail(n *jpb.Node) string
 	GetNode(n string) *jpb.Node
-	Next() []string
-	Prev() []string
-	ForwardEdges() map[string]string
-	BackwardEdges() map[string]string
+	Next() ([]string, error)
+	Prev() ([]string, error)
+	ForwardEdges() map[string]map[string]bool
+	BackwardEdges() map[string]map[string]bool
+
+	// InKey and OutKey identify e, which is one of the edges that GetIn or GetOut returned for n.
+	InKey(n *jpb.Node, e *jpb.Edge) edgeKey
+	OutKey(n *jpb.Node, e *jpb.Edge) edgeKey
+
+	// End returns the node past which the verge can't move when it is on the file at path.
+	End(path string) string
 }
 
 func (v *Verge) forwardMover() mover {
@@ -274,18 +390,27 @@ func (f *forwardMover) GetTail(n *jpb.Node) string {
 func (f *forwardMover) GetNode(n string) *jpb.Node {
 	return f.r.GetNode(n)
 }
-func (f *forwardMover) Next() []string {
+func (f *forwardMover) Next() ([]string, error) {
 	return ((*Verge)(f)).Next()
 }
-func (f *forwardMover) Prev() []string {
+func (f *forwardMover) Prev() ([]string, error) {
 	return ((*Verge)(f)).Prev()
 }
-func (f *forwardMover) ForwardEdges() map[string]string {
+func (f *forwardMover) ForwardEdges() map[string]map[string]bool {
 	return f.forward
 }
-func (f *forwardMover) BackwardEdges() map[string]string {
+func (f *forwardMover) BackwardEdges() map[string]map[string]bool {
 	return f.backward
 }
+func (f *forwardMover) InKey(n *jpb.Node, e *jpb.Edge) edgeKey {
+	return edgeKey{e.Commit, e.Node, n.Head}
+}
+func (f *forwardMover) OutKey(n *jpb.Node, e *jpb.Edge) edgeKey {
+	return edgeKey{e.Commit, n.Tail, e.Node}
+}
+func (f *forwardMover) End(path string) string {
+	return "snk:" + path
+}
 
 func (v *Verge) backwardMover() mover {
 	return (*backwardMover)(v)
@@ -308,54 +433,63 @@ func (b *backwardMover) GetTail(n *jpb.Node) string {
 func (b *backwardMover) GetNode(n string) *jpb.Node {
 	return b.r.GetNode(b.r.GetRef(n))
 }
-func (b *backwardMover) Next() []string {
+func (b *backwardMover) Next() ([]string, error) {
 	return ((*Verge)(b)).Prev()
 }
-func (b *backwardMover) Prev() []string {
+func (b *backwardMover) Prev() ([]string, error) {
 	return ((*Verge)(b)).Next()
 }
-func (b *backwardMover) ForwardEdges() map[string]string {
+func (b *backwardMover) ForwardEdges() map[string]map[string]bool {
 	return b.backward
 }
-func (b *backwardMover) BackwardEdges() map[string]string {
+func (b *backwardMover) BackwardEdges() map[string]map[string]bool {
 	return b.forward
 }
+func (b *backwardMover) InKey(n *jpb.Node, e *jpb.Edge) edgeKey {
+	return edgeKey{e.Commit, n.Tail, e.Node}
+}
+func (b *backwardMover) OutKey(n *jpb.Node, e *jpb.Edge) edgeKey {
+	return edgeKey{e.Commit, e.Node, n.Head}
+}
+func (b *backwardMover) End(path string) string {
+	return "src:" + path
+}
 
-func (v *Verge) AdvanceUntilConflicted() (conflited bool) {
-	for len(v.Conflicts()) == 0 {
-		next := v.Next()
-		if len(next) == 0 {
-			return false
+func (v *Verge) AdvanceUntilConflicted() (conflicted bool, err error) {
+	return v.moveUntilConflicted(v.forwardMover())
+}
+func (v *Verge) RetractUntilConflicted() (conflicted bool, err error) {
+	return v.moveUntilConflicted(v.backwardMover())
+}
+
+// moveUntilConflicted moves the verge until it is in conflict, and returns false if it reaches the
+// end of the file first.
+func (v *Verge) moveUntilConflicted(mov mover) (bool, error) {
+	for {
+		conflicts, err := v.Conflicts()
+		if err != nil {
+			return false, err
 		}
-		fmt.Printf("RDeps: %v\n", v.rdeps)
-		fmt.Printf("%d options for advancing:\n", len(next))
-		x := make([]string, len(next))
-		copy(x, next)
-		sort.Strings(x)
-		for _, n := range x {
-			fmt.Printf("  %s: %s\n", n, nodeContent(v.r, n))
+		if len(conflicts) > 0 {
+			return true, nil
 		}
-		node := next[0]
-		fmt.Printf("Advancing past %s: %s\n", node, nodeContent(v.r, node))
-		v.Advance(node)
-	}
-	return true
-}
-func (v *Verge) RetractUntilConflicted() (conflited bool) {
-	for len(v.Conflicts()) == 0 {
-		prev := v.Prev()
-		if len(prev) == 0 {
-			return false
+		next, err := mov.Next()
+		if err != nil {
+			return false, err
+		}
+		if len(next) == 0 {
+			return false, nil
+		}
+		if err := v.move(next[0], mov); err != nil {
+			return false, err
 		}
-		node := prev[0]
-		v.Retract(node)
 	}
-	return true
 }
-func (v *Verge) AdvanceUntilConverged() (string, map[string]bool) {
+
+func (v *Verge) AdvanceUntilConverged() (string, map[string]bool, error) {
 	return v.moveUntilConverged(v.forwardMover())
 }
-func (v *Verge) RetractUntilConverged() (string, map[string]bool) {
+func (v *Verge) RetractUntilConverged() (string, map[string]bool, error) {
 	return v.moveUntilConverged(v.backwardMover())
 }
 
@@ -367,33 +501,44 @@ func (v *Verge) RetractUntilConverged() (string, map[string]bool) {
 // other side of that node.  Returns the hash of the node at which everything converges.  This is a
 // more simplified version of the method I first devised for tracking edges.  Returns a set of all
 // commits that were involved in a conflict.
-func (v *Verge) moveUntilConverged(mov mover) (string, map[string]bool) {
+func (v *Verge) moveUntilConverged(mov mover) (string, map[string]bool, error) {
 	// track is the set of commits that have conflicted and are still on the verge.
 	track := make(map[string]bool)
 	conflicts := make(map[string]bool)
-	for _, c := range v.Conflicts() {
+	initial, err := v.Conflicts()
+	if err != nil {
+		return "", nil, err
+	}
+	for _, c := range initial {
 		track[c] = true
-		fmt.Printf("Tracking %v\n", c)
-		for _, d := range v.Conflicts() {
+		for _, d := range initial {
 			if c != d {
 				conflicts[c] = true
 				conflicts[d] = true
 			}
 		}
 	}
-	fmt.Printf("Verge: %v\n", v.forward)
-	fmt.Printf("Initial Conflicts: %v\n", v.Conflicts())
 
-	// collapse returns the commits that would be collapsed by moving past n.
-	collapse := func(n *jpb.Node) map[string]bool {
-		remove := make(map[string]bool)
+	// entering returns the tracked commits whose edges on the verge all lead into n.  A commit that
+	// moves lines can have edges elsewhere on the verge, and it hasn't converged until they all have.
+	entering := func(n *jpb.Node) map[string]bool {
+		commits := make(map[string]bool)
 		for _, e := range mov.GetIn(n) {
-			if track[e.Commit] {
-				remove[e.Commit] = true
+			strands := mov.ForwardEdges()[e.Commit]
+			if track[e.Commit] && len(strands) == 1 && strands[mov.GetHead(n)] {
+				commits[e.Commit] = true
 			}
 		}
+		return commits
+	}
+
+	// collapse returns the commits that would be collapsed by moving past n.
+	collapse := func(n *jpb.Node) map[string]bool {
+		remove := entering(n)
 		for _, e := range mov.GetOut(n) {
-			delete(remove, e.Commit)
+			if !v.reverse[mov.OutKey(n, e)] {
+				delete(remove, e.Commit)
+			}
 		}
 		return remove
 	}
@@ -403,11 +548,13 @@ func (v *Verge) moveUntilConverged(mov mover) (string, map[string]bool) {
 		for t := range track {
 			trackList = append(trackList, t)
 		}
-		fmt.Printf("Tracking: %v\n", trackList)
 
-		next := mov.Next()
+		next, err := mov.Next()
+		if err != nil {
+			return "", nil, err
+		}
 		if len(next) == 0 {
-			panic("ran out of ways to advance the verge before everything converged")
+			return "", nil, errors.New("ran out of ways to advance the verge before everything converged")
 		}
 
 		var n *jpb.Node
@@ -416,7 +563,6 @@ func (v *Verge) moveUntilConverged(mov mover) (string, map[string]bool) {
 			m := mov.GetNode(h)
 			if len(collapse(m)) == 0 {
 				n = m
-				fmt.Printf("Found node that didn't collapse anyhting: %q\n", nodeContent(v.r, n.Head))
 				break
 			}
 		}
@@ -427,30 +573,25 @@ func (v *Verge) moveUntilConverged(mov mover) (string, map[string]bool) {
 
 		// It is easier to collapse everything at once because we don't have to worry about a commit
 		// that has an incoming and outgoing edge at a node.  If all tracked commits do that then we
-		// can actually stop here, otherwise we'll have to keep tracking them all.  This is why sat
-		// manually checks which commits it can satisfy rather than just checking len(collapse(n)).
-		sat := 0
-		for _, e := range mov.GetIn(n) {
-			if track[e.Commit] {
-				sat++
-			}
-		}
-		if sat == len(track) {
-			fmt.Printf("Completed because %v can complete %v : %v\n", n, collapse(n), track)
-			return mov.GetHead(n), conflicts
+		// can actually stop here, otherwise we'll have to keep tracking them all.  This is why we
+		// check which commits enter n rather than just checking len(collapse(n)).
+		if len(entering(n)) == len(track) {
+			return mov.GetHead(n), conflicts, nil
 		}
 
 		remove := collapse(n)
 		for c := range remove {
-			fmt.Printf("Collapsing %v\n", c)
 			delete(track, c)
 		}
-		fmt.Printf("Moving past %v\n", n)
-		v.move(mov.GetHead(n), mov)
-		// v.Advance(mov.GetHead(n))
-		for _, c := range v.Conflicts() {
+		if err := v.move(mov.GetHead(n), mov); err != nil {
+			return "", nil, err
+		}
+		current, err := v.Conflicts()
+		if err != nil {
+			return "", nil, err
+		}
+		for _, c := range current {
 			track[c] = true
-			fmt.Printf("Tracking %v\n", c)
 			// for _, d := range v.Conflicts() {
 			// if c != d {
 			conflicts[c] = true
@@ -467,15 +608,26 @@ func (v *Verge) moveUntilConverged(mov mover) (string, map[string]bool) {
 // var bean = 0
 
 func findConflicts(v *Verge) ([]Conflict, error) {
-	if !v.AdvanceUntilConflicted() {
-		return nil, nil
+	conflicted, err := v.AdvanceUntilConflicted()
+	if err != nil || !conflicted {
+		return nil, err
+	}
+	end, commits, err := v.AdvanceUntilConverged()
+	if err != nil {
+		return nil, err
 	}
-	end, commits := v.AdvanceUntilConverged()
 	v2 := v.Clone()
-	if !v2.RetractUntilConflicted() {
+	conflicted, err = v2.RetractUntilConflicted()
+	if err != nil {
+		return nil, err
+	}
+	if !conflicted {
 		return nil, fmt.Errorf("verge is broken")
 	}
-	start, commits2 := v2.RetractUntilConverged()
+	start, commits2, err := v2.RetractUntilConverged()
+	if err != nil {
+		return nil, err
+	}
 	for k, v := range commits {
 		if commits2[k] != v {
 			return nil, fmt.Errorf("conflict detection failed")
@@ -486,7 +638,9 @@ func findConflicts(v *Verge) ([]Conflict, error) {
 			return nil, fmt.Errorf("conflict detection failed")
 		}
 	}
-	v.Advance(end)
+	if err := v.Advance(end); err != nil {
+		return nil, err
+	}
 	conflicts, err := findConflicts(v)
 	if err != nil {
 		return nil, err
@@ -498,8 +652,26 @@ func commitSetToListList(r Repo, set map[string]bool) [][]string {
 	return nil // lists
 }
 
+// FindConflicts returns the conflicts in the file at path as of f.  If the file is deleted the only
+// conflict there can be is a DeleteEditConflict.
 func FindConflicts(r Repo, f Frontier, path string) ([]Conflict, error) {
-	cs, err := findConflicts(MakeVerge(r, f, path))
+	state, err := statFile(r, f, path)
+	if err != nil {
+		return nil, err
+	}
+	if state == FileDeleted {
+		c, err := findDeleteEditConflict(r, f, path)
+		if err != nil || c == nil {
+			return nil, err
+		}
+		return []Conflict{*c}, nil
+	}
+
+	v, err := MakeVerge(r, f, path)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := findConflicts(v)
 	if err != nil {
 		return nil, err
 	}
@@ -508,11 +680,152 @@ func FindConflicts(r Repo, f Frontier, path string) ([]Conflict, error) {
 		if err := cs[i].computeGroups(r); err != nil {
 			return nil, fmt.Errorf("error compute conflicts: %v", err)
 		}
+		if err := cs[i].computeKind(r, f, v.reverse); err != nil {
+			return nil, fmt.Errorf("error computing conflict kind: %v", err)
+		}
 	}
 
 	return cs, nil
 }
 
+// findDeleteEditConflict returns the conflict between the commit that deleted the file at path and
+// any commits that edited it without knowing about the deletion, or nil if there aren't any.  The
+// file must be deleted as of f.
+func findDeleteEditConflict(r Repo, f Frontier, path string) (*Conflict, error) {
+	src := r.GetNode("src:" + path)
+	var deletion string
+	for i := len(src.Out) - 1; i >= 0 && deletion == ""; i-- {
+		obs, err := f.Observes(src.Out[i].Commit)
+		if err != nil {
+			return nil, err
+		}
+		if obs {
+			deletion = src.Out[i].Commit
+		}
+	}
+	// A rename deletes the file at its old name too, but the edits go along with the content.
+	for _, e := range src.In {
+		if e.Commit == deletion {
+			return nil, nil
+		}
+	}
+
+	known := depClosure(r, []string{deletion})
+	concurrent := make(map[string]bool)
+	isConcurrent := func(commit string) bool {
+		c, ok := concurrent[commit]
+		if !ok {
+			c = !known[commit] && !depClosure(r, []string{commit})[deletion]
+			concurrent[commit] = c
+		}
+		return c
+	}
+	commits := make(map[string]bool)
+	seen := map[string]bool{src.Head: true}
+	stack := []*jpb.Node{src}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if n.Head == "snk:"+path {
+			continue
+		}
+		for _, e := range n.Out {
+			if e.Node == DeletedNode {
+				continue
+			}
+			obs, err := f.Observes(e.Commit)
+			if err != nil {
+				return nil, err
+			}
+			if !obs {
+				continue
+			}
+			if isConcurrent(e.Commit) {
+				commits[e.Commit] = true
+			}
+			if !seen[e.Node] {
+				seen[e.Node] = true
+				next := r.GetNode(e.Node)
+				if next == nil {
+					return nil, fmt.Errorf("%q: %w", e.Node, ErrMissingNode)
+				}
+				stack = append(stack, next)
+			}
+		}
+	}
+	if len(commits) == 0 {
+		return nil, nil
+	}
+	commits[deletion] = true
+	c := &Conflict{Kind: DeleteEditConflict, Start: src.Tail, End: "snk:" + path, Commits: commits}
+	if err := c.computeGroups(r); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// computeKind works out what kind of conflict c is, other than a DeleteEditConflict.  reverse holds
+// the reverse edges in the file.
+func (c *Conflict) computeKind(r Repo, f Frontier, reverse map[edgeKey]bool) error {
+	c.Kind = EditConflict
+
+	// Only commits that move lines make reverse edges.
+	movers := make(map[string]bool)
+	for e := range reverse {
+		movers[e.commit] = true
+	}
+	moves := 0
+	for _, group := range c.Groups {
+		for _, commit := range group {
+			if movers[commit] {
+				moves++
+				break
+			}
+		}
+	}
+	if moves > 1 {
+		c.Kind = MoveConflict
+		return nil
+	}
+
+	base, err := ReadVersion(r, &removeFromFrontier{f: f, remove: c.Commits}, c.Start, c.End, &ReadMetadata{})
+	if err != nil {
+		return err
+	}
+	deletes, edits := 0, 0
+	for _, group := range c.Groups {
+		add := make(map[string]bool)
+		for _, commit := range group {
+			add[commit] = true
+		}
+		lines, err := ReadVersion(r, &addToFrontier{f: &removeFromFrontier{f: f, remove: c.Commits}, add: add}, c.Start, c.End, &ReadMetadata{})
+		if err != nil {
+			return err
+		}
+		if onlyDeletes(base, lines) {
+			deletes++
+		} else {
+			edits++
+		}
+	}
+	if deletes > 0 && edits > 0 {
+		c.Kind = ZombieConflict
+	}
+	return nil
+}
+
+// onlyDeletes returns true iff b is what is left of a after some of its lines are removed.
+func onlyDeletes(a, b [][]byte) bool {
+	if len(b) >= len(a) {
+		return false
+	}
+	n := 0
+	for _, run := range commonLines(a, b) {
+		n += run.n
+	}
+	return n == len(b)
+}
+
 func (c *Conflict) computeGroups(r Repo) error {
 	var commitHashes []string
 	for commitHash := range c.Commits {
@@ -550,15 +863,12 @@ func (c *Conflict) computeGroups(r Repo) error {
 	// tcs[i] will be nil if it hasn't been filled out yet, and a non-nil, empty slice if it has.
 	tcs := make([][]int, len(commitHashes))
 	necks := make([]bool, len(tcs))
-	fmt.Printf("Compute TC for %v\n", relDepsInts)
 	for i := range tcs {
 		computeTC(i, relDepsInts, tcs)
 		for j := range tcs[i] {
 			necks[tcs[i][j]] = true
 		}
 	}
-	fmt.Printf("Computed TCs: %v\n", tcs)
-	fmt.Printf("Computed Necks: %v\n", necks)
 	c.Groups = nil
 	for i := range necks {
 		if necks[i] {
@@ -572,7 +882,6 @@ func (c *Conflict) computeGroups(r Repo) error {
 		sort.Strings(group)
 		c.Groups = append(c.Groups, group)
 	}
-	fmt.Printf("Computed Groups: %v\n", c.Groups)
 	return nil
 }
 
@@ -603,101 +912,127 @@ func computeTC(index int, deps [][]int, tcs [][]int) {
 // A and B don't conflict with eachother.  It should be sufficient to just group together chains of commits.
 // Oddly enough I think Union Find is the best way to do this.
 type Conflict struct {
+	Kind    ConflictKind
 	Start   string
 	End     string
 	Groups  [][]string // each element is a list of commits that agree on one version of the conflict
 	Commits map[string]bool
 }
 
-type simpleGraph struct {
-	edges map[string]map[string]bool
-	nodes map[string]bool
-}
+// A ConflictKind says what sort of changes are in conflict.
+type ConflictKind int
 
-func makeSimpleGraph() *simpleGraph {
-	return &simpleGraph{
-		edges: make(map[string]map[string]bool),
-		nodes: make(map[string]bool),
-	}
-}
+const (
+	// EditConflict is when concurrent commits change the same lines in different ways.
+	EditConflict ConflictKind = iota
 
-func (sg simpleGraph) Clone() *simpleGraph {
-	sg2 := simpleGraph{
-		edges: make(map[string]map[string]bool),
-		nodes: make(map[string]bool),
-	}
-	for src, edges := range sg.edges {
-		if sg2.edges[src] == nil {
-			sg2.edges[src] = make(map[string]bool)
-		}
-		for dst := range edges {
-			sg2.edges[src][dst] = true
-		}
-	}
-	for node := range sg.nodes {
-		sg2.nodes[node] = true
-	}
-	return &sg2
-}
+	// MoveConflict is when concurrent commits move the same lines to different places.
+	MoveConflict
 
-func (sg simpleGraph) String() string {
-	var nodes []string
-	for n := range sg.nodes {
-		nodes = append(nodes, n)
-	}
-	sort.Strings(nodes)
-	var ret string
-	ret += fmt.Sprintf("Nodes: %v\n", nodes)
+	// ZombieConflict is when a commit deletes lines that a concurrent commit edits, so that if the
+	// edit were kept the lines would come back from the dead.
+	ZombieConflict
 
-	var s []string
-	for k := range sg.edges {
-		s = append(s, k)
-	}
-	sort.Strings(s)
-	for _, k := range s {
-		ret += fmt.Sprintf("%s -> ", k)
-		var t []string
-		for j := range sg.edges[k] {
-			t = append(t, j)
-		}
-		sort.Strings(t)
-		ret += fmt.Sprintf("%v\n", t)
+	// DeleteEditConflict is when a commit deletes a file that a concurrent commit edits.  The
+	// conflict covers the whole file, from its src node to its snk node.
+	DeleteEditConflict
+)
+
+func (k ConflictKind) String() string {
+	switch k {
+	case EditConflict:
+		return "edit"
+	case MoveConflict:
+		return "move"
+	case ZombieConflict:
+		return "zombie"
+	case DeleteEditConflict:
+		return "delete-edit"
 	}
-	return ret
+	return fmt.Sprintf("ConflictKind(%d)", int(k))
 }
 
-func (sg *simpleGraph) addNode(n string) {
-	sg.nodes[n] = true
+// fileDeps works out which of the commits with edges in a file depend on which others.  It only
+// keeps track of how those commits depend on each other, so it doesn't have to search the whole
+// history every time it is asked.
+type fileDeps struct {
+	r Repo
+
+	// file holds the commits with edges in the file, and nearest maps a commit to the commits in file
+	// that it depends on without going through another commit in file.
+	file    map[string]bool
+	nearest map[string][]string
 }
 
-func (sg *simpleGraph) addEdge(src, dst string) {
-	// fmt.Printf("AddEdge %s -> %s\n", src, dst)
-	if _, ok := sg.edges[src]; !ok {
-		sg.edges[src] = make(map[string]bool)
+// heads returns the commits in commits that no other commit in commits depends on.
+func (d *fileDeps) heads(commits map[string]bool) []string {
+	for c := range commits {
+		if !d.file[c] {
+			// The commits already in nearest might depend on c without it being listed.
+			d.file[c] = true
+			d.nearest = make(map[string][]string)
+		}
 	}
-	sg.edges[src][dst] = true
-	if sg.edges[dst] == nil {
-		sg.edges[dst] = make(map[string]bool)
+	reached := make(map[string]bool)
+	var stack []string
+	for c := range commits {
+		stack = append(stack, d.near(c)...)
 	}
-}
-func (sg *simpleGraph) removeNode(n string) {
-	// fmt.Printf("RemoveNode %s\n", n)
-	delete(sg.nodes, n)
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if reached[c] {
+			continue
+		}
+		reached[c] = true
+		stack = append(stack, d.near(c)...)
+	}
+	var heads []string
+	for c := range commits {
+		if !reached[c] {
+			heads = append(heads, c)
+		}
+	}
+	sort.Strings(heads)
+	return heads
 }
 
-func (sg *simpleGraph) dominators() []string {
-	var ds []string
-	for node := range sg.nodes {
-		count := 0
-		for dep := range sg.edges[node] {
-			if sg.nodes[dep] {
-				count++
-				break
+// near returns the commits in d.file that commit depends on without going through another one.
+func (d *fileDeps) near(commit string) []string {
+	stack := []string{commit}
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		if _, ok := d.nearest[c]; ok {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		deps := d.r.GetCommit(c).GetDeps()
+		waiting := false
+		for _, dep := range deps {
+			if _, ok := d.nearest[dep]; !ok && !d.file[dep] {
+				stack = append(stack, dep)
+				waiting = true
 			}
 		}
-		if count == 0 {
-			ds = append(ds, node)
+		if waiting {
+			continue
+		}
+		seen := make(map[string]bool)
+		near := []string{}
+		for _, dep := range deps {
+			found := []string{dep}
+			if !d.file[dep] {
+				found = d.nearest[dep]
+			}
+			for _, n := range found {
+				if !seen[n] {
+					seen[n] = true
+					near = append(near, n)
+				}
+			}
 		}
+		d.nearest[c] = near
+		stack = stack[:len(stack)-1]
 	}
-	return ds
+	return d.nearest[commit]
 }
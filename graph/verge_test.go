@@ -0,0 +1,200 @@
+package graph_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/runningwild/jig/graph"
+	jpb "github.com/runningwild/jig/proto"
+	"github.com/runningwild/jig/testutils"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestConflictKinds(t *testing.T) {
+	Convey("FindConflicts tells apart the different kinds of conflicts", t, func() {
+		r := testutils.MakeFakeRepo()
+		c0, err := graph.ChangeFiles(r, allFrontier{}, nil, []graph.FileChange{
+			{Path: "a.txt", Content: stringsToContent("alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf")},
+		})
+		So(err, ShouldBeNil)
+		So(graph.Apply(r, c0), ShouldBeNil)
+		h0 := r.HashAlgorithm().HashCommit(c0)
+		f0 := explicitFrontierStrings(h0)
+		var ranges []graph.ReadRange
+		_, err = graph.ReadVersion(r, f0, "src:a.txt", "snk:a.txt", &graph.ReadMetadata{Ranges: &ranges})
+		So(err, ShouldBeNil)
+		head := ranges[0].Node
+
+		// move returns a commit that moves "alpha" so that it comes right after line n.  ChangeFiles
+		// would delete it and add it again, this keeps the line and adds edges that point back up the
+		// file.
+		move := func(n int32) string {
+			ref := func(node string, depth int32) *jpb.NodeRef { return &jpb.NodeRef{Node: node, Depth: depth} }
+			c := &jpb.Commit{Deps: []string{h0}, EdgeRefs: []*jpb.EdgeRef{
+				{Src: ref("src:a.txt", 1), Dst: ref(head, 1)},
+				{Src: ref(head, n), Dst: ref(head, 0)},
+				{Src: ref(head, 1), Dst: ref(head, n)},
+			}}
+			So(graph.Apply(r, c), ShouldBeNil)
+			return r.HashAlgorithm().HashCommit(c)
+		}
+		read := func(commits ...string) *graph.FileVersion {
+			v, err := graph.ReadFile(r, explicitFrontierStrings(append(commits, h0)...), "a.txt", nil)
+			So(err, ShouldBeNil)
+			return v
+		}
+		shouldHaveKinds := func(v *graph.FileVersion, kinds ...graph.ConflictKind) {
+			So(v.State, ShouldEqual, graph.FileConflicted)
+			var got []graph.ConflictKind
+			for _, c := range v.Conflicts {
+				got = append(got, c.Kind)
+			}
+			So(got, ShouldResemble, kinds)
+		}
+
+		Convey("a move reads like any other change", func() {
+			m := move(4)
+			v := read(m)
+			So(v.State, ShouldEqual, graph.FilePresent)
+			So(contentToString(v.Lines), ShouldEqual, "bravo.charlie.delta.alpha.echo.foxtrot.golf")
+
+			v = read(m, editFile(r, f0, nil, "a.txt", "alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "GOLF"))
+			So(v.State, ShouldEqual, graph.FilePresent)
+			So(contentToString(v.Lines), ShouldEqual, "bravo.charlie.delta.alpha.echo.foxtrot.GOLF")
+		})
+
+		Convey("moving the same lines to two places is a move conflict", func() {
+			shouldHaveKinds(read(move(4), move(6)), graph.MoveConflict)
+		})
+
+		Convey("a move into lines that were edited is an edit conflict", func() {
+			shouldHaveKinds(read(move(6), editFile(r, f0, nil, "a.txt", "alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "GOLF")), graph.EditConflict)
+		})
+
+		Convey("editing lines that were deleted is a zombie conflict", func() {
+			deleted := editFile(r, f0, nil, "a.txt", "alpha", "foxtrot", "golf")
+			shouldHaveKinds(read(deleted, editFile(r, f0, nil, "a.txt", "alpha", "bravo", "CHARLIE", "delta", "echo", "foxtrot", "golf")), graph.ZombieConflict)
+			shouldHaveKinds(read(deleted, editFile(r, f0, nil, "a.txt", "alpha", "bravo", "charlie", "new", "delta", "echo", "foxtrot", "golf")), graph.ZombieConflict)
+		})
+
+		Convey("two edits of the same lines are an edit conflict", func() {
+			ours := editFile(r, f0, nil, "a.txt", "alpha", "BRAVO", "charlie", "delta", "echo", "foxtrot", "golf")
+			theirs := editFile(r, f0, nil, "a.txt", "alpha", "bravo!", "charlie", "delta", "echo", "foxtrot", "golf")
+			v := read(ours, theirs)
+			shouldHaveKinds(v, graph.EditConflict)
+			So(v.Conflicts[0].Kind.String(), ShouldEqual, "edit")
+		})
+
+		Convey("deleting a file that was edited is a delete/edit conflict", func() {
+			del, err := graph.ChangeFiles(r, f0, nil, []graph.FileChange{{Path: "a.txt", Delete: true}})
+			So(err, ShouldBeNil)
+			So(graph.Apply(r, del), ShouldBeNil)
+			hd := r.HashAlgorithm().HashCommit(del)
+			he := editFile(r, f0, nil, "a.txt", "alpha", "bravo", "CHARLIE", "delta", "echo", "foxtrot", "golf")
+			f := explicitFrontierStrings(h0, hd, he)
+
+			v := read(hd, he)
+			shouldHaveKinds(v, graph.DeleteEditConflict)
+			So(v.Conflicts[0].Commits, ShouldResemble, map[string]bool{hd: true, he: true})
+			So(v.Lines, ShouldBeEmpty)
+
+			c := v.Conflicts[0]
+			vs, err := graph.ReadVersions(r, f, c.Start, c.End, c.Groups, []byte("."))
+			So(err, ShouldBeNil)
+			So(vs, ShouldHaveLength, 2)
+			deleted := 0
+			for _, version := range vs {
+				if version.Deleted {
+					deleted++
+					So(version.Commits, ShouldResemble, map[string]bool{hd: true})
+				} else {
+					So(string(version.Data), ShouldContainSubstring, "CHARLIE")
+				}
+			}
+			So(deleted, ShouldEqual, 1)
+
+			data, err := graph.HumanReadable(r, f, "a.txt", v.Conflicts, []byte("\n"), false)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldStartWith, "<<<<<<< delete-edit\n")
+			So(string(data), ShouldContainSubstring, "deleted")
+
+			Convey("which can be resolved by editing the file", func() {
+				c, err := graph.ChangeFiles(r, f, nil, []graph.FileChange{
+					{Path: "a.txt", Edit: true, Content: stringsToContent("alpha", "CHARLIE")},
+				})
+				So(err, ShouldBeNil)
+				So(graph.Apply(r, c), ShouldBeNil)
+				v := read(hd, he, r.HashAlgorithm().HashCommit(c))
+				So(v.State, ShouldEqual, graph.FilePresent)
+				So(contentToString(v.Lines), ShouldEqual, "alpha.CHARLIE")
+			})
+
+			Convey("or by deleting it", func() {
+				c, err := graph.ChangeFiles(r, f, nil, []graph.FileChange{{Path: "a.txt", Delete: true}})
+				So(err, ShouldBeNil)
+				So(graph.Apply(r, c), ShouldBeNil)
+				v := read(hd, he, r.HashAlgorithm().HashCommit(c))
+				So(v.State, ShouldEqual, graph.FileDeleted)
+				So(v.Conflicts, ShouldBeEmpty)
+			})
+
+			Convey("unless the deletion knows about the edit", func() {
+				c, err := graph.ChangeFiles(r, explicitFrontierStrings(h0, he), nil, []graph.FileChange{{Path: "a.txt", Delete: true}})
+				So(err, ShouldBeNil)
+				So(graph.Apply(r, c), ShouldBeNil)
+				v := read(he, r.HashAlgorithm().HashCommit(c))
+				So(v.State, ShouldEqual, graph.FileDeleted)
+				So(v.Conflicts, ShouldBeEmpty)
+			})
+		})
+
+		Convey("ListConflicts finds files that were deleted while they were edited", func() {
+			del, err := graph.ChangeFiles(r, f0, nil, []graph.FileChange{{Path: "a.txt", Delete: true}})
+			So(err, ShouldBeNil)
+			So(graph.Apply(r, del), ShouldBeNil)
+			he := editFile(r, f0, nil, "a.txt", "alpha", "bravo", "CHARLIE", "delta", "echo", "foxtrot", "golf")
+			f := explicitFrontierStrings(h0, r.HashAlgorithm().HashCommit(del), he)
+			files, err := graph.ListFiles(r, f, "")
+			So(err, ShouldBeNil)
+			So(files, ShouldBeEmpty)
+			reports, err := graph.ListConflicts(r, f, "", false)
+			So(err, ShouldBeNil)
+			So(reports, ShouldHaveLength, 1)
+			So(reports[0].Path, ShouldEqual, "a.txt")
+			So(reports[0].Conflicts[0].Kind, ShouldEqual, "delete-edit")
+			So(reports[0].Conflicts[0].Groups, ShouldHaveLength, 2)
+		})
+	})
+}
+
+func TestSequentialEdits(t *testing.T) {
+	Convey("FindConflicts finds nothing in a file that was only ever edited one commit after another", t, func() {
+		r := testutils.MakeFakeRepo()
+		c, err := graph.ChangeFiles(r, allFrontier{}, nil, []graph.FileChange{{Path: "a.txt", Content: stringsToContent("a")}})
+		So(err, ShouldBeNil)
+		So(graph.Apply(r, c), ShouldBeNil)
+
+		// Lines are drawn from a small alphabet so that the diffs have plenty of ways to line up.
+		rng := rand.New(rand.NewSource(1))
+		prev := "a"
+		for i := 0; i < 100; i++ {
+			lines := make([]string, rng.Intn(8))
+			for j := range lines {
+				lines[j] = string(rune('a' + rng.Intn(4)))
+			}
+			if contentToString(stringsToContent(lines...)) == prev {
+				// An edit that changes nothing has nothing to commit.
+				continue
+			}
+			prev = contentToString(stringsToContent(lines...))
+			editFile(r, allFrontier{}, nil, "a.txt", lines...)
+			conflicts, err := graph.FindConflicts(r, allFrontier{}, "a.txt")
+			So(err, ShouldBeNil)
+			So(conflicts, ShouldBeEmpty)
+			v, err := graph.ReadFile(r, allFrontier{}, "a.txt", nil)
+			So(err, ShouldBeNil)
+			So(contentToString(v.Lines), ShouldEqual, prev)
+		}
+	})
+}
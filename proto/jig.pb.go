@@ -13,6 +13,7 @@ It has these top-level messages:
 	Node
 	Edge
 	Commit
+	CommitMetadata
 	EdgeRef
 	Src
 	Snk
@@ -349,6 +350,10 @@ func (m *Edge) GetJoin() bool {
 type Commit struct {
 	Deps     []string   `protobuf:"bytes,1,rep,name=deps" json:"deps,omitempty"`
 	EdgeRefs []*EdgeRef `protobuf:"bytes,2,rep,name=edge_refs" json:"edge_refs,omitempty"`
+	// Optional information about who made this commit and why.  If present it is part of the
+	// commit's hash, so two people making the same change produce two distinct commits, and the
+	// metadata can't be altered without changing the commit's identity.
+	Metadata *CommitMetadata `protobuf:"bytes,3,opt,name=metadata" json:"metadata,omitempty"`
 }
 
 func (m *Commit) Reset()                    { *m = Commit{} }
@@ -370,6 +375,63 @@ func (m *Commit) GetEdgeRefs() []*EdgeRef {
 	return nil
 }
 
+func (m *Commit) GetMetadata() *CommitMetadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+type CommitMetadata struct {
+	Author    string `protobuf:"bytes,1,opt,name=author" json:"author,omitempty"`
+	Committer string `protobuf:"bytes,2,opt,name=committer" json:"committer,omitempty"`
+	// Seconds since the Unix epoch.
+	Timestamp int64  `protobuf:"varint,3,opt,name=timestamp" json:"timestamp,omitempty"`
+	Message   string `protobuf:"bytes,4,opt,name=message" json:"message,omitempty"`
+	// Arbitrary key/value pairs for anything that doesn't fit in the fields above.
+	Headers map[string]string `protobuf:"bytes,5,rep,name=headers" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *CommitMetadata) Reset()                    { *m = CommitMetadata{} }
+func (m *CommitMetadata) String() string            { return proto.CompactTextString(m) }
+func (*CommitMetadata) ProtoMessage()               {}
+func (*CommitMetadata) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *CommitMetadata) GetAuthor() string {
+	if m != nil {
+		return m.Author
+	}
+	return ""
+}
+
+func (m *CommitMetadata) GetCommitter() string {
+	if m != nil {
+		return m.Committer
+	}
+	return ""
+}
+
+func (m *CommitMetadata) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func (m *CommitMetadata) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *CommitMetadata) GetHeaders() map[string]string {
+	if m != nil {
+		return m.Headers
+	}
+	return nil
+}
+
 type EdgeRef struct {
 	Src *NodeRef `protobuf:"bytes,1,opt,name=src" json:"src,omitempty"`
 	Dst *NodeRef `protobuf:"bytes,2,opt,name=dst" json:"dst,omitempty"`
@@ -381,7 +443,7 @@ type EdgeRef struct {
 func (m *EdgeRef) Reset()                    { *m = EdgeRef{} }
 func (m *EdgeRef) String() string            { return proto.CompactTextString(m) }
 func (*EdgeRef) ProtoMessage()               {}
-func (*EdgeRef) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (*EdgeRef) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
 
 func (m *EdgeRef) GetSrc() *NodeRef {
 	if m != nil {
@@ -410,7 +472,7 @@ type Src struct {
 func (m *Src) Reset()                    { *m = Src{} }
 func (m *Src) String() string            { return proto.CompactTextString(m) }
 func (*Src) ProtoMessage()               {}
-func (*Src) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+func (*Src) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
 
 type Snk struct {
 }
@@ -418,7 +480,7 @@ type Snk struct {
 func (m *Snk) Reset()                    { *m = Snk{} }
 func (m *Snk) String() string            { return proto.CompactTextString(m) }
 func (*Snk) ProtoMessage()               {}
-func (*Snk) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+func (*Snk) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
 
 type NodeRef struct {
 	// Typical hash of the node that this NodeRef refers to.  This may also refer to nodes that are
@@ -436,7 +498,7 @@ type NodeRef struct {
 func (m *NodeRef) Reset()                    { *m = NodeRef{} }
 func (m *NodeRef) String() string            { return proto.CompactTextString(m) }
 func (*NodeRef) ProtoMessage()               {}
-func (*NodeRef) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+func (*NodeRef) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
 
 func (m *NodeRef) GetNode() string {
 	if m != nil {
@@ -467,7 +529,7 @@ type StoredContent struct {
 func (m *StoredContent) Reset()                    { *m = StoredContent{} }
 func (m *StoredContent) String() string            { return proto.CompactTextString(m) }
 func (*StoredContent) ProtoMessage()               {}
-func (*StoredContent) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+func (*StoredContent) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
 
 func (m *StoredContent) GetContent() [][]byte {
 	if m != nil {
@@ -482,6 +544,7 @@ func init() {
 	proto.RegisterType((*Node)(nil), "jig.Node")
 	proto.RegisterType((*Edge)(nil), "jig.Edge")
 	proto.RegisterType((*Commit)(nil), "jig.Commit")
+	proto.RegisterType((*CommitMetadata)(nil), "jig.CommitMetadata")
 	proto.RegisterType((*EdgeRef)(nil), "jig.EdgeRef")
 	proto.RegisterType((*Src)(nil), "jig.Src")
 	proto.RegisterType((*Snk)(nil), "jig.Snk")
@@ -492,31 +555,38 @@ func init() {
 func init() { proto.RegisterFile("github.com/runningwild/jig/proto/jig.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 416 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x5c, 0x92, 0xc1, 0x8e, 0xda, 0x30,
-	0x10, 0x86, 0x09, 0xb6, 0x81, 0x0c, 0xa6, 0xad, 0x2c, 0x95, 0xba, 0x52, 0xa5, 0x8d, 0x72, 0x42,
-	0x3d, 0x80, 0xb4, 0xad, 0xd4, 0x7b, 0x57, 0x95, 0x38, 0xb5, 0x12, 0x3c, 0xc0, 0x8a, 0x8d, 0x4d,
-	0x62, 0x58, 0x6c, 0x14, 0x3b, 0xea, 0xbd, 0xef, 0xd4, 0xf7, 0x5b, 0x79, 0x1c, 0xd8, 0x15, 0xa7,
-	0x8c, 0xfd, 0xff, 0x99, 0xfc, 0xf3, 0x4d, 0xe0, 0x6b, 0x6d, 0x42, 0xd3, 0x3d, 0x2d, 0x2b, 0x77,
-	0x5a, 0xb5, 0x9d, 0xb5, 0xc6, 0xd6, 0x7f, 0xcd, 0xb3, 0x5a, 0x1d, 0x4c, 0xbd, 0x3a, 0xb7, 0x2e,
-	0xb8, 0x58, 0x2d, 0xb1, 0x12, 0xe4, 0x60, 0xea, 0xf2, 0x5f, 0x06, 0x74, 0xa3, 0xcf, 0x4e, 0x7c,
-	0x81, 0x71, 0xe5, 0x4e, 0x27, 0x13, 0xbc, 0xcc, 0x0a, 0xb2, 0x98, 0xde, 0x4f, 0x97, 0xd1, 0xfa,
-	0x80, 0x77, 0x42, 0x02, 0xd3, 0xaa, 0xd6, 0x5e, 0x0e, 0x51, 0xcb, 0x51, 0xfb, 0xa5, 0x6a, 0x1d,
-	0x15, 0xeb, 0x94, 0xf6, 0x92, 0xbc, 0x51, 0x7e, 0x3b, 0xa5, 0xc5, 0x07, 0x98, 0x54, 0xce, 0x06,
-	0x6d, 0x83, 0x97, 0xb4, 0x20, 0x0b, 0x2e, 0xe6, 0x40, 0x5b, 0xbd, 0xf7, 0x92, 0xa1, 0x75, 0x82,
-	0xd6, 0x8d, 0xde, 0x97, 0x77, 0x40, 0x36, 0x7a, 0x2f, 0xa6, 0x40, 0x7c, 0x5b, 0xc9, 0xac, 0xc8,
-	0x16, 0x79, 0x3c, 0x28, 0x1f, 0xe4, 0x30, 0x1e, 0xca, 0xff, 0x19, 0x50, 0xec, 0xc9, 0x81, 0x36,
-	0x7a, 0xa7, 0x7a, 0x0f, 0x07, 0x1a, 0x76, 0xe6, 0x39, 0x99, 0xc4, 0xa7, 0xf4, 0x3a, 0x29, 0xb2,
-	0x6b, 0xf3, 0x6d, 0x5b, 0xad, 0x07, 0x28, 0xd8, 0xa3, 0xa4, 0x6f, 0x05, 0x7b, 0x5c, 0x0f, 0xc4,
-	0x1c, 0x78, 0x9f, 0xf0, 0xb1, 0xd9, 0xf9, 0x46, 0xb2, 0xd8, 0x67, 0x3d, 0x10, 0x33, 0x60, 0x95,
-	0xeb, 0x6c, 0x90, 0xa3, 0x22, 0x5b, 0x30, 0xf1, 0x11, 0x86, 0xc6, 0xca, 0xf1, 0xed, 0xe4, 0x73,
-	0x20, 0xae, 0x0b, 0x72, 0x72, 0x73, 0xff, 0x33, 0x8f, 0x24, 0xb1, 0x6b, 0x79, 0x0f, 0x14, 0xad,
-	0xef, 0x60, 0x94, 0xe0, 0xbe, 0x06, 0x8f, 0xd0, 0xfa, 0xe0, 0x1c, 0xe8, 0xc1, 0x19, 0x8b, 0xc9,
-	0x27, 0xe5, 0x0f, 0x18, 0xf5, 0xd0, 0x39, 0x50, 0xa5, 0xcf, 0x69, 0x1f, 0xb9, 0xb8, 0x83, 0x3c,
-	0xae, 0xe0, 0x11, 0x09, 0xa6, 0x35, 0xf0, 0xeb, 0x47, 0x23, 0xc5, 0x3f, 0x30, 0xee, 0x4b, 0xf1,
-	0xf9, 0x95, 0xe4, 0xc5, 0x15, 0xf1, 0xf5, 0xd2, 0x85, 0xeb, 0xad, 0x14, 0x53, 0x36, 0x9d, 0x3d,
-	0xa6, 0x5d, 0xf2, 0x92, 0x01, 0xd9, 0xb6, 0x15, 0x3e, 0xec, 0xb1, 0xfc, 0x0e, 0xe3, 0x8b, 0xf1,
-	0x12, 0x3f, 0x0d, 0x33, 0x03, 0xa6, 0xf4, 0x39, 0x34, 0xd8, 0x93, 0xdd, 0x4c, 0x53, 0xc0, 0x6c,
-	0x1b, 0x5c, 0xab, 0xd5, 0x43, 0x42, 0x22, 0xde, 0x5f, 0xe9, 0xe0, 0x5c, 0xfc, 0x69, 0x84, 0x7f,
-	0xe3, 0xb7, 0x97, 0x00, 0x00, 0x00, 0xff, 0xff, 0x3b, 0x72, 0x7b, 0x4a, 0xbb, 0x02, 0x00, 0x00,
+	// 521 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x53, 0xc1, 0x6f, 0xd3, 0x3e,
+	0x18, 0x5d, 0x9a, 0xa4, 0x69, 0xbe, 0xa5, 0xdb, 0x6f, 0xfe, 0x89, 0x62, 0x10, 0xd2, 0xa2, 0x48,
+	0x48, 0x15, 0x87, 0x56, 0x0c, 0x0e, 0x88, 0x23, 0xd3, 0xa4, 0x5e, 0x00, 0xa9, 0xbd, 0x33, 0x65,
+	0xf1, 0xd7, 0x24, 0x6d, 0x63, 0x57, 0xb6, 0x03, 0xda, 0x95, 0xff, 0x89, 0x0b, 0x7f, 0x1d, 0xb2,
+	0xe3, 0x74, 0xa5, 0xe2, 0x54, 0xdb, 0xef, 0xf5, 0xf9, 0xf9, 0xbd, 0x2f, 0xf0, 0xa6, 0xac, 0x75,
+	0xd5, 0x3e, 0xcc, 0x0a, 0xd1, 0xcc, 0x65, 0xcb, 0x79, 0xcd, 0xcb, 0x1f, 0xf5, 0x8e, 0xcd, 0x37,
+	0x75, 0x39, 0xdf, 0x4b, 0xa1, 0x85, 0x59, 0xcd, 0xec, 0x8a, 0xf8, 0x9b, 0xba, 0xcc, 0x7e, 0x7a,
+	0x10, 0x2c, 0x71, 0x2f, 0xc8, 0x2b, 0x88, 0x0a, 0xd1, 0x34, 0xb5, 0x56, 0xd4, 0x4b, 0xfd, 0xe9,
+	0xf9, 0xcd, 0xf9, 0xcc, 0x50, 0x6f, 0xed, 0x19, 0xa1, 0x10, 0x22, 0x2b, 0x51, 0xd1, 0x81, 0xc5,
+	0x62, 0x8b, 0xdd, 0xb1, 0x12, 0x0d, 0xc2, 0x05, 0x43, 0x45, 0xfd, 0x23, 0xe4, 0x8b, 0x60, 0x48,
+	0xfe, 0x83, 0x51, 0x21, 0xb8, 0x46, 0xae, 0x15, 0x0d, 0x52, 0x7f, 0x9a, 0x90, 0x09, 0x04, 0x12,
+	0xd7, 0x8a, 0x86, 0x96, 0x3a, 0xb2, 0xd4, 0x25, 0xae, 0xb3, 0x6b, 0xf0, 0x97, 0xb8, 0x26, 0xe7,
+	0xe0, 0x2b, 0x59, 0x50, 0x2f, 0xf5, 0xa6, 0xb1, 0xd9, 0x30, 0xa5, 0xe9, 0xc0, 0x6c, 0xb2, 0x5f,
+	0x1e, 0x04, 0x56, 0x33, 0x81, 0xa0, 0xc2, 0x9c, 0x39, 0x4e, 0x02, 0x81, 0xce, 0xeb, 0x5d, 0x47,
+	0x22, 0xcf, 0xbb, 0xbf, 0xfb, 0xa9, 0x77, 0x10, 0x5f, 0xc9, 0x62, 0x71, 0x66, 0x01, 0xbe, 0xa5,
+	0xc1, 0x31, 0xc0, 0xb7, 0x8b, 0x33, 0x32, 0x81, 0xc4, 0x39, 0xbc, 0xaf, 0x72, 0x55, 0xd1, 0xd0,
+	0xe8, 0x2c, 0xce, 0xc8, 0x18, 0xc2, 0x42, 0xb4, 0x5c, 0xd3, 0x61, 0xea, 0x4d, 0x43, 0xf2, 0x0c,
+	0x06, 0x35, 0xa7, 0xd1, 0xe9, 0xcb, 0x27, 0xe0, 0x8b, 0x56, 0xd3, 0xd1, 0xc9, 0xf9, 0xa7, 0x18,
+	0x22, 0xa7, 0x9a, 0xdd, 0x40, 0x60, 0xa9, 0x17, 0x30, 0xec, 0xc2, 0x7d, 0x32, 0x6e, 0x42, 0x73,
+	0xc6, 0x13, 0x08, 0x36, 0xa2, 0xe6, 0xd6, 0xf9, 0x28, 0xfb, 0x06, 0x43, 0x17, 0x7a, 0x02, 0x01,
+	0xc3, 0x7d, 0xd7, 0x47, 0x4c, 0xae, 0x21, 0x36, 0x15, 0xdc, 0xdb, 0x04, 0xbb, 0x1a, 0x92, 0xc3,
+	0xa5, 0x26, 0xbe, 0xd7, 0x30, 0x6a, 0x50, 0xe7, 0x2c, 0xd7, 0xb9, 0x0b, 0xe1, 0xff, 0xa3, 0x0a,
+	0x3f, 0x3b, 0x28, 0xfb, 0xed, 0xc1, 0xc5, 0xdf, 0x47, 0xc6, 0x5e, 0xde, 0xea, 0x4a, 0x48, 0x67,
+	0xef, 0x0a, 0xe2, 0xce, 0xae, 0x46, 0xe9, 0x3c, 0x5e, 0x41, 0xac, 0xeb, 0x06, 0x95, 0xce, 0x9b,
+	0xbd, 0x55, 0xf7, 0xc9, 0x25, 0x44, 0x0d, 0x2a, 0x95, 0x97, 0x68, 0xa3, 0x8d, 0xc9, 0x5b, 0x88,
+	0x4c, 0x39, 0x28, 0xfb, 0x86, 0xd3, 0x7f, 0xdc, 0x3f, 0x5b, 0x74, 0x94, 0x3b, 0xae, 0xe5, 0xe3,
+	0xcb, 0x19, 0x24, 0xc7, 0x7b, 0xd3, 0xfa, 0x16, 0x1f, 0x9d, 0x8d, 0x31, 0x84, 0xdf, 0xf3, 0x5d,
+	0xeb, 0x62, 0xfa, 0x38, 0xf8, 0xe0, 0x65, 0x5f, 0x21, 0xea, 0x9f, 0xfb, 0xe2, 0x69, 0x5a, 0xfa,
+	0x24, 0xcc, 0x88, 0x38, 0xa8, 0x9f, 0x9d, 0x53, 0xc8, 0x34, 0x51, 0xb5, 0x7c, 0xdb, 0xcd, 0x6b,
+	0x92, 0x85, 0xe0, 0xaf, 0x64, 0x61, 0x7f, 0xf8, 0x36, 0x7b, 0x0f, 0x51, 0x4f, 0xec, 0x2b, 0x3a,
+	0x58, 0x61, 0xb8, 0xd7, 0x95, 0xd5, 0x0c, 0x4f, 0x1a, 0x4b, 0x61, 0xbc, 0xd2, 0x42, 0x22, 0xbb,
+	0xed, 0x6a, 0x27, 0x97, 0x87, 0x09, 0xb0, 0xdd, 0x25, 0x0f, 0x43, 0xfb, 0xc5, 0xbd, 0xfb, 0x33,
+	0x00, 0x54, 0x1d, 0x12, 0x25, 0x9f, 0x03, 0x00, 0x00,
 }
@@ -2,6 +2,7 @@ package testutils
 
 import (
 	"fmt"
+	"github.com/golang/protobuf/proto"
 	"github.com/runningwild/jig/graph"
 	jpb "github.com/runningwild/jig/proto"
 	"sort"
@@ -13,15 +14,27 @@ type fakeRepo struct {
 	commits     map[string]*jpb.Commit
 	contents    map[string][][]byte
 	reverseDeps map[string][]string
+	alg         graph.HashAlgorithm
+
+	// snapshot is a copy of the repo taken when the outermost transaction started, it is restored
+	// if the transaction is aborted.
+	snapshot *fakeRepo
+	depth    int
+	aborted  bool
 }
 
 func MakeFakeRepo() graph.Repo {
+	return MakeFakeRepoWithHashAlgorithm(graph.DefaultHashAlgorithm)
+}
+
+func MakeFakeRepoWithHashAlgorithm(alg graph.HashAlgorithm) graph.Repo {
 	return &fakeRepo{
 		refs:        make(map[string]string),
 		nodes:       make(map[string]*jpb.Node),
 		commits:     make(map[string]*jpb.Commit),
 		contents:    make(map[string][][]byte),
 		reverseDeps: make(map[string][]string),
+		alg:         alg,
 	}
 }
 
@@ -37,8 +50,14 @@ func (r *fakeRepo) GetContent(contentHash string) [][]byte {
 func (r *fakeRepo) GetCommit(commitHash string) *jpb.Commit {
 	return r.commits[commitHash]
 }
+func (r *fakeRepo) GetCommitMetadata(commitHash string) *jpb.CommitMetadata {
+	return r.commits[commitHash].GetMetadata()
+}
 func (r *fakeRepo) GetReverseDeps(commitHash string) []string {
-	panic("not implemented")
+	return r.reverseDeps[commitHash]
+}
+func (r *fakeRepo) HashAlgorithm() graph.HashAlgorithm {
+	return r.alg
 }
 
 func (r *fakeRepo) ListRefs(start string, refs []string) (n int) {
@@ -50,21 +69,21 @@ func (r *fakeRepo) ListRefs(start string, refs []string) (n int) {
 }
 func (r *fakeRepo) ListNodes(start string, nodes []string) (n int) {
 	var keys []string
-	for key := range r.refs {
+	for key := range r.nodes {
 		keys = append(keys, key)
 	}
 	return r.fillWithKeys(keys, start, nodes)
 }
 func (r *fakeRepo) ListContents(start string, contents []string) (n int) {
 	var keys []string
-	for key := range r.refs {
+	for key := range r.contents {
 		keys = append(keys, key)
 	}
 	return r.fillWithKeys(keys, start, contents)
 }
 func (r *fakeRepo) ListCommits(start string, commits []string) (n int) {
 	var keys []string
-	for key := range r.refs {
+	for key := range r.commits {
 		keys = append(keys, key)
 	}
 	return r.fillWithKeys(keys, start, commits)
@@ -81,21 +100,78 @@ func (r *fakeRepo) fillWithKeys(keys []string, start string, dst []string) (n in
 }
 
 func (r *fakeRepo) StartTransaction() {
-	fmt.Printf("Transaction started...\n")
+	if r.depth == 0 {
+		r.snapshot = r.clone()
+		r.aborted = false
+	}
+	r.depth++
 }
 func (r *fakeRepo) EndTransaction() error {
-	fmt.Printf("Transaction completed\n")
+	if r.depth == 0 {
+		return fmt.Errorf("no transaction in progress")
+	}
+	r.depth--
+	if r.aborted {
+		if r.depth == 0 {
+			r.restore()
+		}
+		return graph.ErrTransactionAborted
+	}
+	if r.depth == 0 {
+		r.snapshot = nil
+	}
 	return nil
 }
+func (r *fakeRepo) AbortTransaction() {
+	if r.depth == 0 {
+		return
+	}
+	r.depth--
+	r.aborted = true
+	if r.depth == 0 {
+		r.restore()
+	}
+}
+
+// clone makes a deep copy of everything in the repo.  Nodes and commits have to be copied too since
+// callers are free to modify the ones they get from the repo.
+func (r *fakeRepo) clone() *fakeRepo {
+	c := MakeFakeRepoWithHashAlgorithm(r.alg).(*fakeRepo)
+	for k, v := range r.refs {
+		c.refs[k] = v
+	}
+	for k, v := range r.nodes {
+		c.nodes[k] = proto.Clone(v).(*jpb.Node)
+	}
+	for k, v := range r.commits {
+		c.commits[k] = proto.Clone(v).(*jpb.Commit)
+	}
+	for k, v := range r.contents {
+		c.contents[k] = v
+	}
+	for k, v := range r.reverseDeps {
+		c.reverseDeps[k] = append([]string(nil), v...)
+	}
+	return c
+}
+
+func (r *fakeRepo) restore() {
+	s := r.snapshot
+	r.refs, r.nodes, r.commits, r.contents, r.reverseDeps = s.refs, s.nodes, s.commits, s.contents, s.reverseDeps
+	r.snapshot = nil
+}
 
 func (r *fakeRepo) PutRef(ptr, val string) {
 	r.refs[ptr] = val
 }
+func (r *fakeRepo) DeleteRef(ptr string) {
+	delete(r.refs, ptr)
+}
 func (r *fakeRepo) PutNode(n *jpb.Node) {
 	r.nodes[n.Head] = n
 }
 func (r *fakeRepo) DeleteNode(nodeHash string) {
-	panic("not implemented")
+	delete(r.nodes, nodeHash)
 }
 func (r *fakeRepo) PutContent(content [][]byte) string {
 	contentCopy := make([][]byte, len(content))
@@ -103,21 +179,33 @@ func (r *fakeRepo) PutContent(content [][]byte) string {
 		contentCopy[i] = make([]byte, len(content[i]))
 		copy(contentCopy[i], content[i])
 	}
-	if graph.HashContent(contentCopy) != graph.HashContent(content) {
+	if r.alg.HashContent(contentCopy) != r.alg.HashContent(content) {
 		panic("EXPLODE")
 	}
-	r.contents[graph.HashContent(contentCopy)] = contentCopy
-	return graph.HashContent(contentCopy)
+	r.contents[r.alg.HashContent(contentCopy)] = contentCopy
+	return r.alg.HashContent(contentCopy)
 }
 func (r *fakeRepo) DeleteContent(contentHash string) {
-	panic("not implemented")
+	delete(r.contents, contentHash)
 }
 func (r *fakeRepo) PutCommit(c *jpb.Commit) {
-	r.commits[graph.HashCommit(c)] = c
+	r.commits[r.alg.HashCommit(c)] = c
+}
+func (r *fakeRepo) DeleteCommit(commitHash string) {
+	delete(r.commits, commitHash)
 }
 func (r *fakeRepo) PutReverseDep(newCommit, oldCommit string) {
 	r.reverseDeps[oldCommit] = append(r.reverseDeps[oldCommit], newCommit)
 }
 func (r *fakeRepo) DeleteReverseDep(newCommit, oldCommit string) {
-	panic("not implemented")
+	rdeps := r.reverseDeps[oldCommit]
+	for i := range rdeps {
+		if rdeps[i] == newCommit {
+			r.reverseDeps[oldCommit] = append(rdeps[0:i:i], rdeps[i+1:]...)
+			break
+		}
+	}
+	if len(r.reverseDeps[oldCommit]) == 0 {
+		delete(r.reverseDeps, oldCommit)
+	}
 }
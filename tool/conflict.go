@@ -58,14 +58,14 @@ func main() {
 	c2 := Diffmachine(r, explicitFrontier(c0), "sample.txt", stringsToContent(strings.Split("a.b.alpha.bravo.CHARLIE.DELTA.echo.foxtrot.y.z.", ".")...))
 	for _, c := range []*jpb.Commit{c1, c2} {
 		if err := graph.Apply(r, c); err != nil {
-			panic(fmt.Errorf("error applying %s: %v", graph.HashCommit(c), err))
+			panic(fmt.Errorf("error applying %s: %v", r.HashAlgorithm().HashCommit(c), err))
 		}
 	}
 	c3 := Diffmachine(r, explicitFrontier(c0, c2), "sample.txt", stringsToContent(strings.Split("a.b.alpha.bravo.CHARLIE.DELTA.ECHO.foxtrot.y.z.", ".")...))
-	fmt.Printf("Commit c3(%s) depends on %v\n", graph.HashCommit(c3), c3.Deps)
+	fmt.Printf("Commit c3(%s) depends on %v\n", r.HashAlgorithm().HashCommit(c3), c3.Deps)
 	for _, c := range []*jpb.Commit{c3} {
 		if err := graph.Apply(r, c); err != nil {
-			panic(fmt.Errorf("error applying %s: %v", graph.HashCommit(c), err))
+			panic(fmt.Errorf("error applying %s: %v", r.HashAlgorithm().HashCommit(c), err))
 		}
 	}
 
@@ -93,14 +93,14 @@ func main() {
 	}
 
 	fmt.Printf("Conflicts: %v\n", conflictedCommits)
-	// vs, err := graph.ReadVersions(r, explicitFrontier(c0, c1, c2, c3), nil, "src:sample.txt", "snk:sample.txt", conflictedCommits, []byte("."))
+	// vs, err := graph.ReadVersions(r, explicitFrontier(c0, c1, c2, c3), "src:sample.txt", "snk:sample.txt", conflictedCommits, []byte("."))
 	// if err != nil {
 	// 	panic(err)
 	// }
 	// for _, v := range vs {
 	// 	fmt.Printf("%v: %s\n", v.Commits, v.Data)
 	// }
-	output, err := graph.HumanReadable(r, explicitFrontier(c0, c1, c2, c3), nil, "sample.txt", conflictsList, []byte{'\n'})
+	output, err := graph.HumanReadable(r, explicitFrontier(c0, c1, c2, c3), "sample.txt", conflictsList, []byte{'\n'}, false)
 	if err != nil {
 		panic(err)
 	}
@@ -309,7 +309,7 @@ func (allFrontier) Observes(string) bool { return true }
 func explicitFrontier(commits ...*jpb.Commit) simpleFrontier {
 	s := make(simpleFrontier)
 	for _, c := range commits {
-		s[graph.HashCommit(c)] = true
+		s[graph.DefaultHashAlgorithm.HashCommit(c)] = true
 	}
 	return s
 }
@@ -54,7 +54,7 @@ func main() {
 	}
 	fmt.Printf("*********************************************************************************************************\n")
 
-	allDeps := []string{graph.HashCommit(c0)}
+	allDeps := []string{r.HashAlgorithm().HashCommit(c0)}
 	prevLines, err := graph.ReadVersion(r, allFrontier{}, "src:sample.txt", "snk:sample.txt", &graph.ReadMetadata{})
 	if err != nil {
 		panic(err)
@@ -76,7 +76,7 @@ func main() {
 		c1 := diffmachine(r, allFrontier{}, "sample.txt", stringsToContent(lines...))
 		commits = append(commits, c1)
 		// c1.Deps = append(c1.Deps, allDeps...)
-		allDeps = append(allDeps, graph.HashCommit(c1))
+		allDeps = append(allDeps, r.HashAlgorithm().HashCommit(c1))
 
 		if prev[0] == lines[0] {
 			// Verify that we didn't get an edge from the src node when we didn't need one.
@@ -119,7 +119,7 @@ func main() {
 	}
 	fmt.Printf("All Commits:\n")
 	for _, c := range commits {
-		fmt.Printf("%s: %v\n", graph.HashCommit(c), c.Deps)
+		fmt.Printf("%s: %v\n", r.HashAlgorithm().HashCommit(c), c.Deps)
 	}
 }
 
@@ -265,10 +265,8 @@ func diffmachine(r graph.Repo, f graph.Frontier, path string, lines1 [][]byte) *
 		c.EdgeRefs = append(c.EdgeRefs, curEdge)
 	}
 
-	// NEXT: need this to work with conflicts.  There are two major issues that need to be addressed:
-	// 1. LCS doesn't work with conflicted files, so we'll need a different or maybe hybrid approach.
-	// 2. ReadVersion assumes the file is not in conflict, and we'll need to include both versions in
-	//    the commit's deps to ensure that it actually resolves the conflict.
+	// This doesn't work with conflicted files, graph.ChangeFiles does.  It diffs around each conflict
+	// and includes every conflicting commit in the deps so that the conflict stays resolved.
 	depSet := make(map[string]bool)
 	for _, e := range c.EdgeRefs {
 		if e.Src.Node == e.Dst.Node {
@@ -325,7 +323,7 @@ func (allFrontier) Observes(string) bool { return true }
 func explicitFrontier(commits ...*jpb.Commit) simpleFrontier {
 	s := make(simpleFrontier)
 	for _, c := range commits {
-		s[graph.HashCommit(c)] = true
+		s[graph.DefaultHashAlgorithm.HashCommit(c)] = true
 	}
 	return s
 }